@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/configs"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/database/migrations"
+)
+
+const usage = `usage: migrate [-config dir] up|down|status|baseline|to <version>
+
+  up            apply every pending migration
+  down          roll back the most recently applied migration
+  status        list migrations and whether they are applied
+  baseline      adopt a database created by AutoMigrate before migrations
+                existed: mark the migrations creating its users and products
+                tables as applied, then run up
+  to <version>  migrate up or down to the given version (0 rolls back all)
+`
+
+func main() {
+	configPath := flag.String("config", ".", "directory containing the .env file")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := configs.LoadConfig(*configPath)
+	if err != nil {
+		fail(err)
+	}
+	db, err := database.NewConnection(database.ConnectionConfig{
+		Driver:          cfg.DBDriver,
+		Host:            cfg.DBHost,
+		Port:            cfg.DBPort,
+		User:            cfg.DBUser,
+		Pass:            cfg.DBPass,
+		Name:            cfg.DBName,
+		SSLMode:         cfg.DBSSLMode,
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.DBConnMaxLifetime) * time.Minute,
+	})
+	if err != nil {
+		fail(err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fail(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "baseline":
+		err = migrator.Baseline()
+	case "to":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		version, convErr := strconv.Atoi(flag.Arg(1))
+		if convErr != nil {
+			fail(fmt.Errorf("invalid version %q", flag.Arg(1)))
+		}
+		err = migrator.To(version)
+	case "status":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if err := printStatus(migrator); err != nil {
+		fail(err)
+	}
+}
+
+func printStatus(migrator *migrations.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		applied := "pending"
+		if s.Applied {
+			applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, applied)
+	}
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
+}
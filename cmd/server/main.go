@@ -1,20 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/ThalesLoreto/product-api/configs"
 	_ "github.com/ThalesLoreto/product-api/docs"
 
 	"github.com/ThalesLoreto/product-api/internal/entity"
 	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/database/migrations"
+	"github.com/ThalesLoreto/product-api/internal/infra/jobs"
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
 	"github.com/ThalesLoreto/product-api/internal/infra/webserver/handlers"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/jwtauth/v5"
-	httpSwagger "github.com/swaggo/http-swagger/v2"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 )
 
 // @title Product API
@@ -34,40 +37,228 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey APIKeyHeader
+// @in header
+// @name X-API-Key
 func main() {
 	cfg, err := configs.LoadConfig(".")
 	if err != nil {
 		panic(err)
 	}
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	db, err := database.NewConnection(database.ConnectionConfig{
+		Driver:          cfg.DBDriver,
+		Host:            cfg.DBHost,
+		Port:            cfg.DBPort,
+		User:            cfg.DBUser,
+		Pass:            cfg.DBPass,
+		Name:            cfg.DBName,
+		SSLMode:         cfg.DBSSLMode,
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.DBConnMaxLifetime) * time.Minute,
+	})
+	if err != nil {
+		panic(err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.DBAutoMigrate {
+		if err := migrator.Up(); err != nil {
+			panic(err)
+		}
+	}
+	pending, err := migrator.Pending()
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&entity.User{}, &entity.Product{})
+	if len(pending) > 0 {
+		panic(fmt.Sprintf("database has %d pending migrations, run `migrate up` first", len(pending)))
+	}
 
-	productDB := database.NewProduct(db)
+	// The handlers confine the repositories to the organization of each
+	// request. Only the login and the other lookups of users by their
+	// credentials, and the purge jobs, work across organizations.
+	productDB := database.NewProductUnscoped(db)
 	productHandler := handlers.NewProductHandler(productDB)
+	productHandler.RequireIfMatch = cfg.RequireIfMatch
+	if cfg.PurgeInterval > 0 {
+		go jobs.PurgeDeleted(
+			context.Background(),
+			productDB,
+			time.Duration(cfg.ProductRetention)*24*time.Hour,
+			time.Duration(cfg.PurgeInterval)*time.Minute,
+		)
+	}
 
-	userDB := database.NewUser(db)
-	userHandler := handlers.NewUserHandler(userDB, cfg.TokenAuth, cfg.JwtExpiresIn)
-
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Route("/products", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(cfg.TokenAuth))
-		r.Use(jwtauth.Authenticator(cfg.TokenAuth))
-		r.Post("/", productHandler.CreateProduct)
-		r.Get("/{id}", productHandler.GetProduct)
-		r.Get("/", productHandler.GetAllProducts)
-		r.Put("/{id}", productHandler.UpdateProduct)
-		r.Delete("/{id}", productHandler.DeleteProduct)
+	productSearch, err := database.NewProductSearchUnscoped(db)
+	if err != nil {
+		panic(err)
+	}
+	searchHandler := handlers.NewSearchHandler(productSearch)
+
+	stockDB := database.NewStockUnscoped(db)
+	stockHandler := handlers.NewStockHandler(stockDB, productDB)
+
+	categoryDB := database.NewCategoryUnscoped(db)
+	categoryHandler := handlers.NewCategoryHandler(categoryDB)
+
+	tokenDB := database.NewToken(db)
+	if cfg.PurgeInterval > 0 {
+		go jobs.PurgeDeleted(context.Background(), tokenDB, 0, time.Duration(cfg.PurgeInterval)*time.Minute)
+	}
+
+	passwordPolicy := entity.DefaultPasswordPolicy
+	if cfg.PasswordMinLength > 0 {
+		passwordPolicy.MinLength = cfg.PasswordMinLength
+	}
+	passwordPolicy.RequireUpper = cfg.PasswordRequireUpper
+	passwordPolicy.RequireLower = cfg.PasswordRequireLower
+	passwordPolicy.RequireDigit = cfg.PasswordRequireDigit
+	passwordPolicy.RequireSymbol = cfg.PasswordRequireSymbol
+	entity.SetPasswordPolicy(passwordPolicy)
+
+	userDB := database.NewUserUnscoped(db)
+	if cfg.AdminEmail != "" {
+		if err := promoteAdmin(userDB, cfg.AdminEmail); err != nil {
+			panic(err)
+		}
+	}
+	mailer, err := mail.NewMailer(mail.Config{
+		Driver: cfg.MailDriver,
+		From:   cfg.MailFrom,
+		Host:   cfg.SMTPHost,
+		Port:   cfg.SMTPPort,
+		User:   cfg.SMTPUser,
+		Pass:   cfg.SMTPPass,
+		Dir:    cfg.MailDir,
 	})
+	if err != nil {
+		panic(err)
+	}
+	tokenAuth, err := newKeySet(cfg.JwtKeys, cfg.JwtSecret, time.Duration(cfg.JwtExpiresIn)*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	userHandler := handlers.NewUserHandler(userDB, tokenDB, tokenAuth, cfg.JwtExpiresIn, cfg.JwtRefreshExpiresIn)
+	userHandler.Mailer = mailer
+	userHandler.ResetExpiresIn = cfg.PasswordResetExpires
+	userHandler.ResetURL = cfg.PasswordResetURL
+	// The verification tokens are signed with the JWT secret unless they
+	// have their own, which the signature keeps apart from access tokens.
+	verificationSecret, err := tokenSecret("EMAIL_VERIFICATION_SECRET", cfg.VerificationSecret, cfg.JwtKeys, cfg.JwtSecret)
+	if err != nil {
+		panic(err)
+	}
+	verifier := &handlers.EmailVerifier{
+		UserDB:         userDB,
+		Mailer:         mailer,
+		Secret:         verificationSecret,
+		TTL:            time.Duration(cfg.VerificationExpiresIn) * time.Hour,
+		ResendInterval: time.Duration(cfg.VerificationResendIn) * time.Minute,
+		URL:            cfg.VerificationURL,
+	}
+	userHandler.Verifier = verifier
+	userHandler.RequireVerifiedEmail = cfg.VerificationRequired
+	loginThrottle := database.NewLoginThrottle(db)
+	lockout := entity.LockoutPolicy{
+		Lockout:    time.Duration(cfg.LoginLockout) * time.Minute,
+		MaxLockout: time.Duration(cfg.LoginMaxLockout) * time.Minute,
+		Window:     time.Duration(cfg.LoginFailureWindow) * time.Minute,
+	}
+	userHandler.Throttle = loginThrottle
+	userHandler.Audit = database.NewAudit(db)
+	userHandler.AccountLockout = lockout
+	userHandler.AccountLockout.MaxFailures = cfg.LoginMaxFailures
+	userHandler.ClientLockout = lockout
+	userHandler.ClientLockout.MaxFailures = cfg.LoginIPMaxFailures
+	// Password reset requests are held off like failed logins, once there
+	// are too many in a row.
+	userHandler.ResetAccountLockout = lockout
+	userHandler.ResetAccountLockout.MaxFailures = cfg.PasswordResetMax
+	userHandler.ResetClientLockout = lockout
+	userHandler.ResetClientLockout.MaxFailures = cfg.PasswordResetIPMax
+	// The challenge tokens are signed like the verification tokens.
+	mfaSecret, err := tokenSecret("MFA_SECRET", cfg.MFASecret, cfg.JwtKeys, cfg.JwtSecret)
+	if err != nil {
+		panic(err)
+	}
+	userHandler.MFA = database.NewMFA(db)
+	userHandler.MFAIssuer = cfg.MFAIssuer
+	userHandler.MFASecret = mfaSecret
+	userHandler.MFAExpiresIn = time.Duration(cfg.MFAExpiresIn) * time.Minute
+	if cfg.PurgeInterval > 0 {
+		go jobs.PurgeDeleted(context.Background(), loginThrottle, lockout.Window, time.Duration(cfg.PurgeInterval)*time.Minute)
+	}
+
+	organizationDB := database.NewOrganization(db)
+	organizationHandler := handlers.NewOrganizationHandler(organizationDB, userDB)
+	organizationHandler.Verifier = verifier
+
+	apiKeyDB := database.NewAPIKey(db)
+	r := newRouter(tokenAuth, tokenDB, apiKeyDB, userDB, routes{
+		products:      productHandler,
+		search:        searchHandler,
+		stock:         stockHandler,
+		categories:    categoryHandler,
+		users:         userHandler,
+		organizations: organizationHandler,
+		keys:          handlers.NewKeyHandler(tokenAuth),
+		apiKeys:       handlers.NewAPIKeyHandler(apiKeyDB),
+	})
+	log.Fatal(http.ListenAndServe(":"+cfg.WebServerPort, r))
+}
 
-	r.Post("/users", userHandler.CreateUser)
-	r.Post("/users/login", userHandler.Login)
+// newKeySet returns the keys signing access tokens: those of the PEM files
+// of spec if any, see keys.Load, and the HMAC secret otherwise, which other
+// services cannot verify the tokens without. Along with the PEM files, the
+// secret still verifies the tokens it signed for tokenTTL after the start,
+// so that setting spec does not log everyone out.
+func newKeySet(spec, secret string, tokenTTL time.Duration) (*keys.Set, error) {
+	if spec == "" && secret == "" {
+		return nil, errors.New("JWT_SECRET is required unless JWT_KEYS is set")
+	}
+	if spec != "" {
+		if secret == "" {
+			return keys.Load(spec, tokenTTL)
+		}
+		return keys.Load(spec, tokenTTL, keys.NewLegacyHMAC([]byte(secret), time.Now().Add(tokenTTL)))
+	}
+	return keys.NewSet(tokenTTL, keys.NewHMAC([]byte(secret)))
+}
 
-	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:3000/swagger/doc.json"),
-	))
-	http.ListenAndServe(":3000", r)
+// tokenSecret returns the secret of the setting name, which signs tokens
+// other than access tokens, falling back to the JWT secret. Along with
+// JWT_KEYS the JWT secret at most verifies older access tokens, so the
+// setting is required. An empty secret would let anyone sign tokens.
+func tokenSecret(name, secret, jwtKeys, jwtSecret string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+	if jwtKeys != "" {
+		return nil, fmt.Errorf("%s is required along with JWT_KEYS", name)
+	}
+	if jwtSecret == "" {
+		return nil, fmt.Errorf("%s or JWT_SECRET is required", name)
+	}
+	return []byte(jwtSecret), nil
+}
+
+// promoteAdmin makes the user with the email an admin, so that there is
+// someone to grant roles to the users who register. The user may register
+// after the server starts, in which case it is promoted on the next start.
+func promoteAdmin(userDB *database.User, email string) error {
+	user, err := userDB.FindByEmail(email)
+	if errors.Is(err, database.ErrNotFound) {
+		log.Printf("admin %s is not registered yet", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role == entity.RoleAdmin {
+		return nil
+	}
+	return userDB.UpdateRole(user.ID.String(), entity.RoleAdmin)
 }
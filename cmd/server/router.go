@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/handlers"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/middlewares"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	httpSwagger "github.com/swaggo/http-swagger/v2"
+)
+
+type routes struct {
+	products      *handlers.ProductHandler
+	search        *handlers.SearchHandler
+	stock         *handlers.StockHandler
+	categories    *handlers.CategoryHandler
+	users         *handlers.UserHandler
+	organizations *handlers.OrganizationHandler
+	keys          *handlers.KeyHandler
+	apiKeys       *handlers.APIKeyHandler
+}
+
+// newRouter mounts the handlers. Every authenticated user may read the
+// catalog, editors may also change it and only admins may manage users.
+// Products may further only be modified by their owner or an admin, and
+// every handler confines itself to the organization of the access token.
+// Programs may also call /products with an API key, within its scopes.
+func newRouter(tokenAuth *keys.Set, tokenDB database.TokenInterface, apiKeyDB database.APIKeyInterface, userDB database.UserInterface, h routes) http.Handler {
+	authenticated := chi.Chain(
+		middlewares.Verifier(tokenAuth),
+		middlewares.Authenticator(tokenAuth),
+		middlewares.Denylist(tokenDB),
+	)
+	editor := middlewares.RequireRole(entity.RoleEditor)
+	admin := middlewares.RequireRole(entity.RoleAdmin)
+	read := middlewares.RequireScope(entity.ScopeProductsRead)
+	write := middlewares.RequireScope(entity.ScopeProductsWrite)
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Route("/products", func(r chi.Router) {
+		r.Use(
+			middlewares.Verifier(tokenAuth),
+			middlewares.APIKey(apiKeyDB, userDB),
+			middlewares.Authenticator(tokenAuth),
+			middlewares.Denylist(tokenDB),
+		)
+		r.With(read).Get("/search", h.search.SearchProducts)
+		r.With(read).Get("/{id}", h.products.GetProduct)
+		r.With(read).Get("/", h.products.GetAllProducts)
+		r.With(read).Get("/{id}/stock", h.stock.GetStock)
+		r.With(read).Get("/{id}/stock/movements", h.stock.GetStockMovements)
+		r.With(editor, write).Post("/", h.products.CreateProduct)
+		r.With(editor, write).Put("/{id}", h.products.UpdateProduct)
+		r.With(editor, write).Patch("/{id}", h.products.PatchProduct)
+		r.With(editor, write).Delete("/{id}", h.products.DeleteProduct)
+		r.With(editor, write).Post("/{id}/restore", h.products.RestoreProduct)
+		r.With(editor, write).Post("/{id}/stock/movements", h.stock.CreateStockMovement)
+	})
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(authenticated...)
+		r.Get("/{id}", h.categories.GetCategory)
+		r.Get("/", h.categories.GetAllCategories)
+		r.With(editor).Post("/", h.categories.CreateCategory)
+		r.With(editor).Put("/{id}", h.categories.UpdateCategory)
+		r.With(editor).Delete("/{id}", h.categories.DeleteCategory)
+	})
+
+	r.Post("/users", h.users.CreateUser)
+	r.Post("/users/login", h.users.Login)
+	r.Post("/users/login/mfa", h.users.LoginMFA)
+	r.Post("/users/token/refresh", h.users.RefreshToken)
+	r.Post("/users/password/forgot", h.users.ForgotPassword)
+	r.Post("/users/password/reset", h.users.ResetPassword)
+	r.Get("/users/verify", h.users.VerifyEmail)
+	r.Post("/users/verify/resend", h.users.ResendVerification)
+	r.Group(func(r chi.Router) {
+		r.Use(authenticated...)
+		r.Post("/users/logout", h.users.Logout)
+		r.Get("/users/me", h.users.GetMe)
+		r.Put("/users/me", h.users.UpdateMe)
+		r.Post("/users/me/password", h.users.ChangePassword)
+		r.Post("/users/me/mfa/totp", h.users.EnrollTOTP)
+		r.Post("/users/me/mfa/totp/confirm", h.users.ConfirmTOTP)
+		r.Post("/users/me/api-keys", h.apiKeys.CreateAPIKey)
+		r.Get("/users/me/api-keys", h.apiKeys.GetAPIKeys)
+		r.Delete("/users/me/api-keys/{id}", h.apiKeys.RevokeAPIKey)
+		r.Get("/users/me/products", h.products.GetMyProducts)
+		r.With(admin).Put("/users/{id}/role", h.users.UpdateUserRole)
+	})
+
+	r.Post("/organizations", h.organizations.CreateOrganization)
+	r.Group(func(r chi.Router) {
+		r.Use(authenticated...)
+		r.Get("/organizations/{id}", h.organizations.GetOrganization)
+		r.With(admin).Post("/organizations/{id}/users", h.organizations.CreateMember)
+	})
+
+	r.Get("/.well-known/jwks.json", h.keys.GetJWKS)
+
+	r.Get("/swagger/*", httpSwagger.Handler(
+		httpSwagger.URL("http://localhost:3000/swagger/doc.json"),
+	))
+	return r
+}
@@ -0,0 +1,1281 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/database/migrations"
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/handlers"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/middlewares"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	handler   http.Handler
+	tokenAuth *keys.Set
+	mailer    *mail.MemoryMailer
+	users     *handlers.UserHandler
+	productID string
+	userID    string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	db, err := database.NewConnection(database.ConnectionConfig{Driver: database.DriverMemory})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("could not load migrations: %v", err)
+	}
+	// The search index needs SQLite with FTS5, which go-sqlite3 only has
+	// with the sqlite_fts5 build tag the Makefile sets.
+	err = migrator.Up()
+	if errors.Is(err, migrations.ErrNoFTS5) {
+		t.Skipf("SQLite lacks FTS5, run the tests with -tags sqlite_fts5: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	userDB := database.NewUserUnscoped(db)
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	if err := userDB.Create(user); err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	productDB := database.NewProductUnscoped(db)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.OwnerID = &user.ID
+	if err := productDB.Create(product); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+	productSearch, err := database.NewProductSearchUnscoped(db)
+	if err != nil {
+		t.Fatalf("could not create search index: %v", err)
+	}
+	tokenAuth := newTestKeys(t)
+	tokenDB := database.NewToken(db)
+	mailer := &mail.MemoryMailer{}
+	userHandler := handlers.NewUserHandler(userDB, tokenDB, tokenAuth, 5, 60)
+	userHandler.Mailer = mailer
+	userHandler.ResetExpiresIn = 30
+	userHandler.ResetURL = "http://localhost/reset"
+	verifier := &handlers.EmailVerifier{
+		UserDB:         userDB,
+		Mailer:         mailer,
+		Secret:         []byte("verification secret"),
+		TTL:            time.Hour,
+		ResendInterval: time.Minute,
+		URL:            "http://localhost/users/verify",
+	}
+	userHandler.Verifier = verifier
+	userHandler.Throttle = database.NewLoginThrottle(db)
+	userHandler.Audit = database.NewAudit(db)
+	lockout := entity.LockoutPolicy{Lockout: time.Minute, MaxLockout: 4 * time.Minute, Window: time.Hour}
+	userHandler.AccountLockout = lockout
+	userHandler.AccountLockout.MaxFailures = 3
+	userHandler.ClientLockout = lockout
+	userHandler.ClientLockout.MaxFailures = 6
+	userHandler.MFA = database.NewMFA(db)
+	userHandler.MFAIssuer = "Product API"
+	userHandler.MFASecret = []byte("mfa secret")
+	userHandler.MFAExpiresIn = time.Minute
+	organizationHandler := handlers.NewOrganizationHandler(database.NewOrganization(db), userDB)
+	organizationHandler.Verifier = verifier
+	apiKeyDB := database.NewAPIKey(db)
+	return &testServer{
+		handler: newRouter(tokenAuth, tokenDB, apiKeyDB, userDB, routes{
+			products:      handlers.NewProductHandler(productDB),
+			search:        handlers.NewSearchHandler(productSearch),
+			stock:         handlers.NewStockHandler(database.NewStockUnscoped(db), productDB),
+			categories:    handlers.NewCategoryHandler(database.NewCategoryUnscoped(db)),
+			users:         userHandler,
+			organizations: organizationHandler,
+			keys:          handlers.NewKeyHandler(tokenAuth),
+			apiKeys:       handlers.NewAPIKeyHandler(apiKeyDB),
+		}),
+		tokenAuth: tokenAuth,
+		mailer:    mailer,
+		users:     userHandler,
+		productID: product.ID.String(),
+		userID:    user.ID.String(),
+	}
+}
+
+// newTestKeys returns a set of an ES256 key, like the keys loaded from PEM
+// files.
+func newTestKeys(t *testing.T) *keys.Set {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+	key, err := keys.ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), time.Time{})
+	if err != nil {
+		t.Fatalf("could not parse key: %v", err)
+	}
+	set, err := keys.NewSet(5*time.Minute, key)
+	if err != nil {
+		t.Fatalf("could not create key set: %v", err)
+	}
+	return set
+}
+
+// token returns an access token of the user owning the test product, with
+// the role.
+func (s *testServer) token(t *testing.T, role entity.Role) string {
+	return s.tokenFor(t, s.userID, role)
+}
+
+func (s *testServer) tokenFor(t *testing.T, userID string, role entity.Role) string {
+	_, token, err := s.tokenAuth.Encode(map[string]interface{}{
+		"sub":    userID,
+		"role":   string(role),
+		"tenant": entity.DefaultOrganizationID.String(),
+		"jti":    pkgEntity.NewID().String(),
+		"exp":    jwtauth.ExpireIn(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("could not encode token: %v", err)
+	}
+	return token
+}
+
+func (s *testServer) do(method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouter_Authorization(t *testing.T) {
+	s := newTestServer(t)
+	product := "/products/" + s.productID
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		required entity.Role
+	}{
+		{http.MethodGet, "/products", "", entity.RoleViewer},
+		{http.MethodGet, "/products/search?q=product", "", entity.RoleViewer},
+		{http.MethodGet, product, "", entity.RoleViewer},
+		{http.MethodGet, product + "/stock", "", entity.RoleViewer},
+		{http.MethodGet, product + "/stock/movements", "", entity.RoleViewer},
+		{http.MethodPost, "/products", `{"name":"Product 2","price":{"amount":10,"currency":"USD"}}`, entity.RoleEditor},
+		{http.MethodPut, product, `{"name":"Product 1","price":{"amount":20,"currency":"USD"}}`, entity.RoleEditor},
+		{http.MethodPatch, product, `{"name":"Product 1"}`, entity.RoleEditor},
+		{http.MethodPost, product + "/stock/movements", `{"type":"receipt","quantity":1}`, entity.RoleEditor},
+		{http.MethodDelete, product, "", entity.RoleEditor},
+		{http.MethodPost, product + "/restore", "", entity.RoleEditor},
+		{http.MethodGet, "/categories", "", entity.RoleViewer},
+		{http.MethodGet, "/categories/" + pkgEntity.NewID().String(), "", entity.RoleViewer},
+		{http.MethodPost, "/categories", `{"name":"Electronics"}`, entity.RoleEditor},
+		{http.MethodPut, "/categories/" + pkgEntity.NewID().String(), `{"name":"Electronics"}`, entity.RoleEditor},
+		{http.MethodDelete, "/categories/" + pkgEntity.NewID().String(), "", entity.RoleEditor},
+		{http.MethodGet, "/users/me/products", "", entity.RoleViewer},
+		{http.MethodPut, "/users/" + s.userID + "/role", `{"role":"viewer"}`, entity.RoleAdmin},
+		{http.MethodGet, "/organizations/" + entity.DefaultOrganizationID.String(), "", entity.RoleViewer},
+		{http.MethodPost, "/organizations/" + entity.DefaultOrganizationID.String() + "/users", `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`, entity.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.body, "")
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, "without token")
+
+			for _, role := range []entity.Role{entity.RoleViewer, entity.RoleEditor, entity.RoleAdmin} {
+				rec := s.do(tt.method, tt.path, tt.body, s.token(t, role))
+				if role.Includes(tt.required) {
+					assert.NotEqual(t, http.StatusForbidden, rec.Code, role)
+					assert.NotEqual(t, http.StatusUnauthorized, rec.Code, role)
+				} else {
+					assert.Equal(t, http.StatusForbidden, rec.Code, role)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_TokenWithoutRoleIsViewer(t *testing.T) {
+	s := newTestServer(t)
+	_, token, _ := s.tokenAuth.Encode(map[string]interface{}{
+		"sub":    s.userID,
+		"tenant": entity.DefaultOrganizationID.String(),
+		"exp":    jwtauth.ExpireIn(time.Minute),
+	})
+	assert.Equal(t, http.StatusOK, s.do(http.MethodGet, "/products", "", token).Code)
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodDelete, "/products/"+s.productID, "", token).Code)
+}
+
+func TestRouter_LoginEmbedsRole(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.token(t, entity.RoleAdmin)
+	rec := s.do(http.MethodPut, "/users/"+s.userID+"/role", `{"role":"editor"}`, admin)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = s.do(http.MethodPut, "/users/"+s.userID+"/role", `{"role":"root"}`, admin)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	accessToken := s.accessToken(t, rec)
+	token, err := s.tokenAuth.Decode(accessToken)
+	assert.NoError(t, err)
+	role, _ := token.Get("role")
+	assert.Equal(t, "editor", role)
+	tenant, _ := token.Get("tenant")
+	assert.Equal(t, entity.DefaultOrganizationID.String(), tenant)
+
+	rec = s.do(http.MethodPost, "/products", `{"name":"Product 2","price":{"amount":10,"currency":"USD"}}`, accessToken)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func (s *testServer) accessToken(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var output struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&output); err != nil {
+		t.Fatalf("could not decode tokens: %v", err)
+	}
+	return output.AccessToken
+}
+
+func TestRouter_Ownership(t *testing.T) {
+	s := newTestServer(t)
+	product := "/products/" + s.productID
+	other := s.tokenFor(t, pkgEntity.NewID().String(), entity.RoleEditor)
+	update := `{"name":"Product 1","price":{"amount":20,"currency":"USD"}}`
+
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodPut, product, update, other).Code)
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodDelete, product, "", other).Code)
+	receipt := `{"type":"receipt","quantity":1}`
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodPost, product+"/stock/movements", receipt, other).Code)
+	assert.Equal(t, http.StatusCreated, s.do(http.MethodPost, product+"/stock/movements", receipt, s.token(t, entity.RoleEditor)).Code)
+	assert.Equal(t, http.StatusCreated, s.do(http.MethodPost, product+"/stock/movements", receipt, s.tokenFor(t, pkgEntity.NewID().String(), entity.RoleAdmin)).Code)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPut, product, update, s.token(t, entity.RoleEditor)).Code)
+	admin := s.tokenFor(t, pkgEntity.NewID().String(), entity.RoleAdmin)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodDelete, product, "", admin).Code)
+	assert.Equal(t, http.StatusForbidden, s.do(http.MethodPost, product+"/restore", "", other).Code)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPost, product+"/restore", "", s.token(t, entity.RoleEditor)).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodDelete, "/products/"+pkgEntity.NewID().String(), "", other).Code)
+
+	rec := s.do(http.MethodPost, "/products", `{"name":"Product 2","price":{"amount":10,"currency":"USD"}}`, other)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var page struct {
+		Items []entity.Product `json:"items"`
+		Total int64            `json:"total"`
+	}
+	rec = s.do(http.MethodGet, "/users/me/products", "", other)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "Product 2", page.Items[0].Name)
+	assert.NotNil(t, page.Items[0].OwnerID)
+
+	rec = s.do(http.MethodGet, "/users/me/products", "", s.token(t, entity.RoleViewer))
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, s.productID, page.Items[0].ID.String())
+}
+
+func TestRouter_IncludeDeletedIsForAdmins(t *testing.T) {
+	s := newTestServer(t)
+	product := "/products/" + s.productID
+	admin := s.token(t, entity.RoleAdmin)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodDelete, product, "", admin).Code)
+
+	for _, path := range []string{product, "/products", "/users/me/products"} {
+		for _, role := range []entity.Role{entity.RoleViewer, entity.RoleEditor} {
+			rec := s.do(http.MethodGet, path+"?include_deleted=true", "", s.token(t, role))
+			assert.Equal(t, http.StatusForbidden, rec.Code, "%s as %s", path, role)
+		}
+		assert.Equal(t, http.StatusOK, s.do(http.MethodGet, path+"?include_deleted=true", "", admin).Code, path)
+	}
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, product, "", admin).Code)
+}
+
+func TestRouter_PatchAndReplaceProduct(t *testing.T) {
+	s := newTestServer(t)
+	product := "/products/" + s.productID
+	editor := s.token(t, entity.RoleEditor)
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, product, strings.NewReader(body))
+		req.Header.Set("Content-Type", handlers.MergePatchContentType)
+		req.Header.Set("Authorization", "Bearer "+editor)
+		rec := httptest.NewRecorder()
+		s.handler.ServeHTTP(rec, req)
+		return rec
+	}
+	stored := func() entity.Product {
+		var p entity.Product
+		rec := s.do(http.MethodGet, product, "", editor)
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+		return p
+	}
+	rec := s.do(http.MethodPost, "/categories", `{"name":"Electronics"}`, editor)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var category entity.Category
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&category))
+
+	rec = s.do(http.MethodPut, product, `{"name":"Phone","price":{"amount":500,"currency":"eur"},"category_ids":["`+category.ID.String()+`"]}`, editor)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	p := stored()
+	assert.Equal(t, "Phone", p.Name)
+	assert.Equal(t, pkgEntity.Money{Amount: 500, Currency: "EUR"}, p.Price)
+	assert.Len(t, p.Categories, 1)
+
+	rec = patch(`{"price":{"amount":750}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	p = stored()
+	assert.Equal(t, "Phone", p.Name)
+	assert.Equal(t, pkgEntity.Money{Amount: 750, Currency: "EUR"}, p.Price)
+	assert.Len(t, p.Categories, 1, "fields absent from the patch are kept")
+
+	assert.Equal(t, http.StatusBadRequest, patch(`{"price":{"amount":-5}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, patch(`{"name":""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPut, product, `{"name":"Phone","price":{"amount":0,"currency":"EUR"}}`, editor).Code)
+	assert.Equal(t, int64(750), stored().Price.Amount, "invalid updates are not stored")
+
+	assert.Equal(t, http.StatusOK, patch(`{"category_ids":null}`).Code)
+	assert.Empty(t, stored().Categories)
+
+	rec = s.do(http.MethodPatch, product, `{"name":"Tablet"}`, editor)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestRouter_TenantIsolation(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodPost, "/organizations", `{"name":"Retail","admin":{"name":"Ann","email":"ann@retail.com","password":"s3cure-pass"}}`, "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var organization entity.Organization
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&organization))
+	retail := "/organizations/" + organization.ID.String()
+
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"ann@retail.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	ann := s.accessToken(t, rec)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodGet, retail, "", ann).Code)
+	rec = s.do(http.MethodPost, retail+"/users", `{"name":"Bob","email":"bob@retail.com","password":"s3cure-pass"}`, ann)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	rec = s.do(http.MethodPost, "/products", `{"name":"Retail Product","price":{"amount":10,"currency":"USD"}}`, ann)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var page struct {
+		Items []entity.Product `json:"items"`
+		Total int64            `json:"total"`
+	}
+	rec = s.do(http.MethodGet, "/products", "", ann)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "Retail Product", page.Items[0].Name)
+	assert.Equal(t, organization.ID, page.Items[0].OrganizationID)
+	retailProduct := "/products/" + page.Items[0].ID.String()
+
+	// The default organization sees none of it, and Retail none of the
+	// default organization, even with admin rights.
+	admin := s.token(t, entity.RoleAdmin)
+	rec = s.do(http.MethodGet, "/products", "", admin)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "Product 1", page.Items[0].Name)
+	var results []entity.ProductSearchResult
+	rec = s.do(http.MethodGet, "/products/search?q=product", "", admin)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Len(t, results, 1)
+	assert.Equal(t, "Product 1", results[0].Product.Name)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, retailProduct, "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodDelete, retailProduct, "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, retailProduct+"/stock", "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPost, retailProduct+"/stock/movements", `{"type":"receipt","quantity":1}`, admin).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, retail, "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPost, retail+"/users", `{"name":"Eve","email":"eve@j.com","password":"s3cure-pass"}`, admin).Code)
+
+	product := "/products/" + s.productID
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, product, "", ann).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPut, product, `{"name":"Mine"}`, ann).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPut, "/users/"+s.userID+"/role", `{"role":"admin"}`, ann).Code)
+	rec = s.do(http.MethodGet, "/products/search?q=product", "", ann)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&results))
+	assert.Len(t, results, 1)
+	assert.Equal(t, "Retail Product", results[0].Product.Name)
+
+	var category entity.Category
+	rec = s.do(http.MethodPost, "/categories", `{"name":"Books"}`, admin)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&category))
+	categoryPath := "/categories/" + category.ID.String()
+	var categories []entity.Category
+	rec = s.do(http.MethodGet, "/categories", "", ann)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&categories))
+	assert.Empty(t, categories)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, categoryPath, "", ann).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPut, categoryPath, `{"name":"Stolen"}`, ann).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodDelete, categoryPath, "", ann).Code)
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, "/categories", `{"name":"Novels","parent_id":"`+category.ID.String()+`"}`, ann).Code)
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, "/products", `{"name":"Linked","price":{"amount":10,"currency":"USD"},"category_ids":["`+category.ID.String()+`"]}`, ann).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, "/products?category="+category.ID.String(), "", ann).Code)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodGet, categoryPath, "", admin).Code)
+}
+
+func TestRouter_TokenWithoutTenant(t *testing.T) {
+	s := newTestServer(t)
+	_, token, _ := s.tokenAuth.Encode(map[string]interface{}{
+		"sub":  s.userID,
+		"role": string(entity.RoleAdmin),
+		"exp":  jwtauth.ExpireIn(time.Minute),
+	})
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/products", "", token).Code)
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/products/search?q=product", "", token).Code)
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/products/"+s.productID+"/stock", "", token).Code)
+}
+
+func TestRouter_ProblemDetails(t *testing.T) {
+	s := newTestServer(t)
+	editor := s.token(t, entity.RoleEditor)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+		field  string
+	}{
+		{"missing token", http.MethodGet, "/products", "", "", http.StatusUnauthorized, ""},
+		{"forbidden", http.MethodPost, "/products", "{}", s.token(t, entity.RoleViewer), http.StatusForbidden, ""},
+		{"not found", http.MethodGet, "/products/" + pkgEntity.NewID().String(), "", editor, http.StatusNotFound, ""},
+		{"malformed body", http.MethodPost, "/products", "{", editor, http.StatusBadRequest, ""},
+		{"invalid field", http.MethodPost, "/products", `{"name":"","price":{"amount":100,"currency":"USD"}}`, editor, http.StatusBadRequest, "name"},
+		{"invalid query parameter", http.MethodGet, "/products?price_min=cheap", "", editor, http.StatusBadRequest, "price_min"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.body, tt.token)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+			var p problem.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, strings.Split(tt.path, "?")[0], p.Instance)
+			if tt.field != "" && assert.Len(t, p.Errors, 1) {
+				assert.Equal(t, tt.field, p.Errors[0].Field)
+			}
+		})
+	}
+}
+
+func TestRouter_UserValidation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.token(t, entity.RoleAdmin)
+	members := "/organizations/" + entity.DefaultOrganizationID.String() + "/users"
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		token  string
+		status int
+		fields []string
+	}{
+		{"created", "/users", `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`, "", http.StatusCreated, nil},
+		{"invalid fields", "/users", `{"name":"","email":"jane","password":"short"}`, "", http.StatusBadRequest, []string{"name", "email", "password"}},
+		{"duplicate email", "/users", `{"name":"Johnny","email":"J@J.com","password":"s3cure-pass"}`, "", http.StatusConflict, []string{"email"}},
+		{"duplicate member", members, `{"name":"Johnny","email":"j@j.com","password":"s3cure-pass"}`, admin, http.StatusConflict, []string{"email"}},
+		{"invalid admin", "/organizations", `{"name":"Retail","admin":{"name":"Ann","email":"ann","password":"s3cure-pass"}}`, "", http.StatusBadRequest, []string{"admin.email"}},
+		{"duplicate admin", "/organizations", `{"name":"Retail","admin":{"name":"Ann","email":"j@j.com","password":"s3cure-pass"}}`, "", http.StatusConflict, []string{"admin.email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, tt.path, tt.body, tt.token)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.fields == nil {
+				return
+			}
+			var p problem.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			fields := make([]string, len(p.Errors))
+			for i, e := range p.Errors {
+				fields[i] = e.Field
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestRouter_Me(t *testing.T) {
+	s := newTestServer(t)
+	token := s.token(t, entity.RoleViewer)
+
+	rec := s.do(http.MethodGet, "/users/me", "", token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var me dto.UserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&me))
+	assert.Equal(t, dto.UserOutput{
+		ID:             s.userID,
+		Name:           "John",
+		Email:          "j@j.com",
+		Role:           entity.RoleViewer,
+		OrganizationID: entity.DefaultOrganizationID.String(),
+	}, me)
+
+	rec = s.do(http.MethodPut, "/users/me", `{"name":" Johnny ","email":"Johnny@J.com"}`, token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&me))
+	assert.Equal(t, "Johnny", me.Name)
+	assert.Equal(t, "johnny@j.com", me.Email)
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"johnny@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = s.do(http.MethodPut, "/users/me", `{"name":"","email":"johnny"}`, token)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, http.StatusCreated, s.do(http.MethodPost, "/users", `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`, "").Code)
+	rec = s.do(http.MethodPut, "/users/me", `{"name":"Johnny","email":"jane@j.com"}`, token)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/users/me", "", "").Code)
+	unknown := s.tokenFor(t, pkgEntity.NewID().String(), entity.RoleViewer)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, "/users/me", "", unknown).Code)
+}
+
+// TestRouter_NoPasswordInResponses calls every route of the router, so that
+// a route added without a request here fails the test, and checks that no
+// response carries a password, hashed or not, nor a field named after one.
+func TestRouter_NoPasswordInResponses(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.token(t, entity.RoleAdmin)
+	product := "/products/" + s.productID
+	organization := "/organizations/" + entity.DefaultOrganizationID.String()
+
+	rec := s.do(http.MethodPost, "/categories", `{"name":"Books"}`, admin)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var category struct {
+		ID string `json:"id"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&category))
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var tokens dto.LoginUserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&tokens))
+	rec = s.do(http.MethodPost, "/users", `{"name":"Kim","email":"kim@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var kim dto.UserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&kim))
+	verificationToken := s.mailedToken(t, "http://localhost/users/verify?token=")
+	resetToken := s.resetToken(t, "j@j.com")
+	apiKey := s.createAPIKey(t, admin, entity.ScopeProductsRead)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/users", `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`},
+		{http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`},
+		{http.MethodPost, "/users/token/refresh", `{"refresh_token":"` + tokens.RefreshToken + `"}`},
+		{http.MethodGet, "/users/me", ""},
+		{http.MethodPut, "/users/me", `{"name":"John","email":"j@j.com"}`},
+		{http.MethodGet, "/users/me/products", ""},
+		{http.MethodGet, "/users/verify?token=" + url.QueryEscape(verificationToken), ""},
+		{http.MethodPost, "/users/verify/resend", `{"email":"jane@j.com"}`},
+		// An unknown email, as a new reset token would void resetToken.
+		{http.MethodPost, "/users/password/forgot", `{"email":"nobody@j.com"}`},
+		{http.MethodPost, "/users/password/reset", `{"token":"` + resetToken + `","password":"an0ther-pass"}`},
+		{http.MethodPost, "/users/me/password", `{"current_password":"an0ther-pass","new_password":"s3cure-pass"}`},
+		{http.MethodPost, "/users/me/api-keys", `{"name":"Batch","scopes":["products:read"]}`},
+		{http.MethodGet, "/users/me/api-keys", ""},
+		{http.MethodDelete, "/users/me/api-keys/" + apiKey.ID, ""},
+		{http.MethodPut, "/users/" + s.userID + "/role", `{"role":"admin"}`},
+		{http.MethodPost, "/organizations", `{"name":"Retail","admin":{"name":"Ann","email":"ann@retail.com","password":"s3cure-pass"}}`},
+		{http.MethodGet, organization, ""},
+		{http.MethodPost, organization + "/users", `{"name":"Bob","email":"bob@j.com","password":"s3cure-pass"}`},
+		{http.MethodGet, "/products", ""},
+		{http.MethodGet, "/products/search?q=product", ""},
+		{http.MethodPost, "/products", `{"name":"Product 2","price":{"amount":10,"currency":"USD"}}`},
+		{http.MethodGet, product, ""},
+		{http.MethodPut, product, `{"name":"Product 1","price":{"amount":20,"currency":"USD"}}`},
+		{http.MethodPatch, product, `{"name":"Product 1"}`},
+		{http.MethodGet, product + "/stock", ""},
+		{http.MethodPost, product + "/stock/movements", `{"type":"receipt","quantity":1}`},
+		{http.MethodGet, product + "/stock/movements", ""},
+		{http.MethodDelete, product, ""},
+		{http.MethodPost, product + "/restore", ""},
+		{http.MethodGet, "/categories", ""},
+		{http.MethodPost, "/categories", `{"name":"Music"}`},
+		{http.MethodGet, "/categories/" + category.ID, ""},
+		{http.MethodPut, "/categories/" + category.ID, `{"name":"Novels"}`},
+		{http.MethodDelete, "/categories/" + category.ID, ""},
+		{http.MethodGet, "/.well-known/jwks.json", ""},
+		{http.MethodGet, "/swagger/doc.json", ""},
+		{http.MethodPost, "/users/logout", ""},
+	}
+	router := s.handler.(chi.Router)
+	requested := map[string]bool{}
+	request := func(method, path, body, token string) *httptest.ResponseRecorder {
+		rctx := chi.NewRouteContext()
+		if !router.Match(rctx, method, strings.SplitN(path, "?", 2)[0]) {
+			t.Errorf("%s %s matches no route", method, path)
+			return nil
+		}
+		requested[method+" "+strings.TrimSuffix(rctx.RoutePattern(), "/")] = true
+
+		httpReq := httptest.NewRequest(method, path, strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if method == http.MethodPatch {
+			httpReq.Header.Set("Content-Type", handlers.MergePatchContentType)
+		}
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.handler.ServeHTTP(rec, httpReq)
+		if strings.HasPrefix(path, "/swagger/") {
+			// The API documentation describes the inputs holding passwords.
+			return rec
+		}
+		if rec.Code >= http.StatusBadRequest {
+			t.Errorf("%s %s: status %d: %s", method, path, rec.Code, rec.Body)
+		}
+		responseBody := rec.Body.String()
+		assert.NotContains(t, responseBody, "s3cure-pass", "%s %s", method, path)
+		assert.NotContains(t, responseBody, "$2a$", "%s %s", method, path)
+		var decoded interface{}
+		if json.Unmarshal(rec.Body.Bytes(), &decoded) == nil {
+			assert.False(t, hasPasswordField(decoded), "%s %s: %s", method, path, responseBody)
+		}
+		return rec
+	}
+	for _, req := range requests {
+		request(req.method, req.path, req.body, admin)
+	}
+
+	// Kim logs in with a second factor, whose secret is only known once
+	// enrolled.
+	kimToken := s.tokenFor(t, kim.ID, entity.RoleViewer)
+	var enrollment dto.TOTPEnrollmentOutput
+	rec = request(http.MethodPost, "/users/me/mfa/totp", "", kimToken)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&enrollment))
+	request(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"`+totpCode(t, enrollment.Secret, 0)+`"}`, kimToken)
+	var challenge dto.MFAChallengeOutput
+	rec = request(http.MethodPost, "/users/login", `{"email":"kim@j.com","password":"s3cure-pass"}`, "")
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&challenge))
+	request(http.MethodPost, "/users/login/mfa", `{"mfa_token":"`+challenge.MFAToken+`","code":"`+totpCode(t, enrollment.Secret, 1)+`"}`, "")
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !requested[method+" "+strings.TrimSuffix(route, "/")] {
+			t.Errorf("%s %s is not requested", method, route)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestRouter_APIKeys(t *testing.T) {
+	s := newTestServer(t)
+	john := s.token(t, entity.RoleViewer)
+	readKey := s.createAPIKey(t, john, entity.ScopeProductsRead)
+	writeKey := s.createAPIKey(t, john, entity.ScopeProductsRead, entity.ScopeProductsWrite)
+	assert.True(t, strings.HasPrefix(readKey.Key, readKey.Prefix+"_"))
+	newProduct := `{"name":"Product 2","price":{"amount":10,"currency":"USD"}}`
+
+	assert.Equal(t, http.StatusOK, s.doWithKey(http.MethodGet, "/products", "", readKey.Key).Code)
+	assert.Equal(t, http.StatusOK, s.doWithKey(http.MethodGet, "/products/"+s.productID, "", readKey.Key).Code)
+	assert.Equal(t, http.StatusForbidden, s.doWithKey(http.MethodPost, "/products", newProduct, readKey.Key).Code, "the key lacks products:write")
+	assert.Equal(t, http.StatusForbidden, s.doWithKey(http.MethodPost, "/products", newProduct, writeKey.Key).Code, "keys act within the role of their user")
+	rec := s.do(http.MethodPut, "/users/"+s.userID+"/role", `{"role":"editor"}`, s.token(t, entity.RoleAdmin))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, http.StatusCreated, s.doWithKey(http.MethodPost, "/products", newProduct, writeKey.Key).Code)
+
+	// API keys only open the product routes.
+	assert.Equal(t, http.StatusUnauthorized, s.doWithKey(http.MethodGet, "/users/me", "", readKey.Key).Code)
+	assert.Equal(t, http.StatusUnauthorized, s.doWithKey(http.MethodGet, "/users/me/api-keys", "", readKey.Key).Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(middlewares.APIKeyHeader, readKey.Key)
+	req.Header.Set("Authorization", "Bearer "+john)
+	rec = httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = s.doWithKey(http.MethodGet, "/products", "", readKey.Prefix+"_invalid")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.TypeInvalidToken)
+
+	rec = s.do(http.MethodGet, "/users/me/api-keys", "", john)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), readKey.Key)
+	var keys []dto.APIKeyOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&keys))
+	assert.Len(t, keys, 2)
+	for _, key := range keys {
+		assert.NotNil(t, key.LastUsedAt, "%s was used", key.Name)
+	}
+
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodDelete, "/users/me/api-keys/"+readKey.ID, "", s.tokenFor(t, pkgEntity.NewID().String(), entity.RoleAdmin)).Code,
+		"only the user revokes their keys")
+	assert.Equal(t, http.StatusNoContent, s.do(http.MethodDelete, "/users/me/api-keys/"+readKey.ID, "", john).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodDelete, "/users/me/api-keys/"+readKey.ID, "", john).Code)
+	assert.Equal(t, http.StatusUnauthorized, s.doWithKey(http.MethodGet, "/products", "", readKey.Key).Code)
+	assert.Equal(t, http.StatusOK, s.doWithKey(http.MethodGet, "/products", "", writeKey.Key).Code)
+}
+
+func TestRouter_CreateAPIKeyValidation(t *testing.T) {
+	s := newTestServer(t)
+	john := s.token(t, entity.RoleViewer)
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"no name", `{"scopes":["products:read"]}`, "name"},
+		{"no scopes", `{"name":"Batch","scopes":[]}`, "scopes"},
+		{"unknown scope", `{"name":"Batch","scopes":["users:write"]}`, "scopes"},
+		{"expired", `{"name":"Batch","scopes":["products:read"],"expires_at":"2000-01-01T00:00:00Z"}`, "expires_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/users/me/api-keys", tt.body, john)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"field":"`+tt.field+`"`)
+		})
+	}
+}
+
+// createAPIKey creates an API key with the scopes for the user of the
+// access token.
+func (s *testServer) createAPIKey(t *testing.T, token string, scopes ...entity.Scope) dto.CreatedAPIKeyOutput {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = `"` + string(scope) + `"`
+	}
+	rec := s.do(http.MethodPost, "/users/me/api-keys", `{"name":"Batch","scopes":[`+strings.Join(names, ",")+`]}`, token)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("could not create API key: status %d: %s", rec.Code, rec.Body)
+	}
+	var output dto.CreatedAPIKeyOutput
+	if err := json.NewDecoder(rec.Body).Decode(&output); err != nil {
+		t.Fatalf("could not decode API key: %v", err)
+	}
+	return output
+}
+
+func (s *testServer) doWithKey(method, path, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middlewares.APIKeyHeader, key)
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func hasPasswordField(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			if strings.Contains(strings.ToLower(name), "password") || hasPasswordField(value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if hasPasswordField(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resetToken asks for a password reset of the user with the email and
+// returns the token mailed.
+func (s *testServer) resetToken(t *testing.T, email string) string {
+	sent := len(s.mailer.Messages())
+	rec := s.do(http.MethodPost, "/users/password/forgot", `{"email":"`+email+`"}`, "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	// The mail is sent after the response.
+	mailed := func() bool { return len(s.mailer.Messages()) > sent }
+	if !assert.Eventually(t, mailed, time.Second, time.Millisecond) {
+		t.Fatalf("no password reset mail was sent to %s", email)
+	}
+	messages := s.mailer.Messages()
+	assert.Len(t, messages, sent+1)
+	assert.Equal(t, entity.NormalizeEmail(email), messages[len(messages)-1].To)
+	return s.mailedToken(t, "http://localhost/reset?token=")
+}
+
+// mailedToken returns the token of the link starting with prefix in the
+// last mail sent.
+func (s *testServer) mailedToken(t *testing.T, prefix string) string {
+	messages := s.mailer.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	body := messages[len(messages)-1].Body
+	_, link, found := strings.Cut(body, prefix)
+	if !found {
+		t.Fatalf("the mail has no link to %s: %s", prefix, body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(link)[0])
+	if err != nil {
+		t.Fatalf("could not unescape the token: %v", err)
+	}
+	return token
+}
+
+func TestRouter_ChangePassword(t *testing.T) {
+	s := newTestServer(t)
+	login := `{"email":"j@j.com","password":"s3cure-pass"}`
+	rec := s.do(http.MethodPost, "/users/login", login, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var current dto.LoginUserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&current))
+	rec = s.do(http.MethodPost, "/users/login", login, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var other dto.LoginUserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&other))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}{
+		{"wrong current password", `{"current_password":"wrong-pass","new_password":"an0ther-pass"}`, http.StatusBadRequest, []string{"current_password"}},
+		{"weak new password", `{"current_password":"s3cure-pass","new_password":"short"}`, http.StatusBadRequest, []string{"new_password"}},
+		{"changed", `{"current_password":"s3cure-pass","new_password":"an0ther-pass"}`, http.StatusNoContent, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/users/me/password", tt.body, current.AccessToken)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.fields == nil {
+				return
+			}
+			var p problem.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			assert.Equal(t, tt.fields[0], p.Errors[0].Field)
+		})
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodPost, "/users/login", login, "").Code)
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"an0ther-pass"}`, "").Code)
+	// The login that changed the password goes on, the others are revoked.
+	refresh := func(token string) int {
+		return s.do(http.MethodPost, "/users/token/refresh", `{"refresh_token":"`+token+`"}`, "").Code
+	}
+	assert.Equal(t, http.StatusOK, refresh(current.RefreshToken))
+	assert.Equal(t, http.StatusUnauthorized, refresh(other.RefreshToken))
+}
+
+func TestRouter_ResetPassword(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var session dto.LoginUserOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&session))
+
+	// Unknown emails get the same answer, without a mail.
+	rec = s.do(http.MethodPost, "/users/password/forgot", `{"email":"nobody@j.com"}`, "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, s.mailer.Messages())
+
+	stale := s.resetToken(t, "J@J.com")
+	token := s.resetToken(t, "j@j.com")
+	reset := func(token, password string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/users/password/reset", `{"token":"`+token+`","password":"`+password+`"}`, "")
+	}
+	rec = reset("not-a-token", "an0ther-pass")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"token"`)
+	rec = reset(token, "short")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"password"`)
+
+	assert.Equal(t, http.StatusNoContent, reset(token, "an0ther-pass").Code)
+	assert.Equal(t, http.StatusBadRequest, reset(token, "yet-an0ther-pass").Code)
+	assert.Equal(t, http.StatusBadRequest, reset(stale, "yet-an0ther-pass").Code)
+
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"an0ther-pass"}`, "").Code)
+	rec = s.do(http.MethodPost, "/users/token/refresh", `{"refresh_token":"`+session.RefreshToken+`"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRouter_ForgotPasswordIsThrottled(t *testing.T) {
+	s := newTestServer(t)
+	policy := entity.LockoutPolicy{MaxFailures: 2, Lockout: time.Minute, Window: time.Hour}
+	s.users.ResetAccountLockout = policy
+	s.users.ResetClientLockout = policy
+	s.users.ResetClientLockout.MaxFailures = 4
+	forgot := func(email string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/users/password/forgot", `{"email":"`+email+`"}`, "")
+	}
+
+	// Known and unknown emails are held off alike.
+	for _, email := range []string{"j@j.com", "nobody@j.com"} {
+		assert.Equal(t, http.StatusAccepted, forgot(email).Code)
+		assert.Equal(t, http.StatusAccepted, forgot(email).Code)
+		rec := forgot(email)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, email)
+		assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+		assert.Contains(t, rec.Body.String(), problem.TypeResetThrottled)
+	}
+	// So is the client once it asked for too many emails.
+	assert.Equal(t, http.StatusTooManyRequests, forgot("jane@j.com").Code)
+	// Failed logins are counted apart.
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "").Code)
+}
+
+func TestRouter_EmailVerification(t *testing.T) {
+	s := newTestServer(t)
+	s.users.RequireVerifiedEmail = true
+	verifyLink := "http://localhost/users/verify?token="
+	login := `{"email":"jane@j.com","password":"s3cure-pass"}`
+
+	rec := s.do(http.MethodPost, "/users", `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"email_verified":false`)
+	assert.Len(t, s.mailer.Messages(), 1)
+	token := s.mailedToken(t, verifyLink)
+
+	rec = s.do(http.MethodPost, "/users/login", login, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.TypeEmailNotVerified)
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"jane@j.com","password":"wrong-pass"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the password is checked first")
+
+	// Resends are throttled, and the same whether or not the user exists.
+	for _, email := range []string{"jane@j.com", "nobody@j.com"} {
+		rec = s.do(http.MethodPost, "/users/verify/resend", `{"email":"`+email+`"}`, "")
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	}
+	assert.Len(t, s.mailer.Messages(), 1)
+
+	verify := func(token string) int {
+		return s.do(http.MethodGet, "/users/verify?token="+url.QueryEscape(token), "", "").Code
+	}
+	assert.Equal(t, http.StatusBadRequest, verify("forged."+token))
+	assert.Equal(t, http.StatusNoContent, verify(token))
+	assert.Equal(t, http.StatusNoContent, verify(token))
+
+	rec = s.do(http.MethodPost, "/users/login", login, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	accessToken := s.accessToken(t, rec)
+	rec = s.do(http.MethodGet, "/users/me", "", accessToken)
+	assert.Contains(t, rec.Body.String(), `"email_verified":true`)
+
+	// A new email has to be verified again, and voids the earlier tokens.
+	rec = s.do(http.MethodPut, "/users/me", `{"name":"Jane","email":"jane@example.com"}`, accessToken)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"email_verified":false`)
+	assert.Len(t, s.mailer.Messages(), 2)
+	assert.Equal(t, "jane@example.com", s.mailer.Messages()[1].To)
+	assert.Equal(t, http.StatusBadRequest, verify(token))
+	assert.Equal(t, http.StatusNoContent, verify(s.mailedToken(t, verifyLink)))
+}
+
+func (s *testServer) loginFrom(ip, email, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/users/login", strings.NewReader(`{"email":"`+email+`","password":"`+password+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":40000"
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouter_LoginDoesNotTellUnknownEmails(t *testing.T) {
+	s := newTestServer(t)
+	unknown := s.loginFrom("192.0.2.1", "nobody@j.com", "s3cure-pass")
+	wrong := s.loginFrom("192.0.2.2", "j@j.com", "wrong-pass")
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, wrong.Code, unknown.Code)
+	assert.Equal(t, wrong.Body.String(), unknown.Body.String())
+}
+
+func TestRouter_LoginLocksOutAccounts(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, s.loginFrom("192.0.2.1", "j@j.com", "wrong-pass").Code)
+	}
+	// The account is locked out whichever the client and the password.
+	rec := s.loginFrom("192.0.2.2", "J@J.com", "s3cure-pass")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), problem.TypeLoginLocked)
+
+	// Unknown emails are locked out alike.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, s.loginFrom("192.0.2.3", "nobody@j.com", "wrong-pass").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, s.loginFrom("192.0.2.3", "nobody@j.com", "wrong-pass").Code)
+
+	entries, err := s.users.Audit.FindBySubject("j@j.com")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, entity.AuditLoginLocked, entries[0].Action)
+		assert.Equal(t, "192.0.2.1", entries[0].IP)
+		assert.Equal(t, s.userID, entries[0].UserID.String())
+	}
+}
+
+func TestRouter_LoginLocksOutClients(t *testing.T) {
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, s.do(http.MethodPost, "/users", `{"name":"Kim","email":"kim@j.com","password":"s3cure-pass"}`, "").Code)
+	for i := 0; i < 6; i++ {
+		email := fmt.Sprintf("user%d@j.com", i)
+		assert.Equal(t, http.StatusUnauthorized, s.loginFrom("198.51.100.7", email, "wrong-pass").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, s.loginFrom("198.51.100.7", "kim@j.com", "s3cure-pass").Code)
+	assert.Equal(t, http.StatusOK, s.loginFrom("198.51.100.8", "kim@j.com", "s3cure-pass").Code)
+
+	entries, err := s.users.Audit.FindBySubject("198.51.100.7")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestRouter_LoginResetsAccountFailures(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, s.loginFrom("192.0.2.1", "j@j.com", "wrong-pass").Code)
+	}
+	assert.Equal(t, http.StatusOK, s.loginFrom("192.0.2.1", "j@j.com", "s3cure-pass").Code)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, s.loginFrom("192.0.2.1", "j@j.com", "wrong-pass").Code)
+	}
+	assert.Equal(t, http.StatusOK, s.loginFrom("192.0.2.1", "j@j.com", "s3cure-pass").Code)
+}
+
+// totpCode returns the code of the secret the number of time steps from
+// now.
+func totpCode(t *testing.T, secret string, steps int) string {
+	code, err := entity.TOTPCode(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	if err != nil {
+		t.Fatalf("could not generate TOTP code: %v", err)
+	}
+	return code
+}
+
+// enableTOTP enrolls and confirms an authenticator app for the user owning
+// the test product, with the code of the current time step, and returns its
+// secret and recovery codes.
+func (s *testServer) enableTOTP(t *testing.T) (string, []string) {
+	token := s.token(t, entity.RoleViewer)
+	rec := s.do(http.MethodPost, "/users/me/mfa/totp", "", token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var enrollment dto.TOTPEnrollmentOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&enrollment))
+	rec = s.do(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"`+totpCode(t, enrollment.Secret, 0)+`"}`, token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var recovery dto.RecoveryCodesOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&recovery))
+	return enrollment.Secret, recovery.RecoveryCodes
+}
+
+// mfaChallenge logs the user owning the test product in and returns the
+// challenge token answered.
+func (s *testServer) mfaChallenge(t *testing.T) string {
+	rec := s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var challenge map[string]string
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&challenge))
+	assert.NotContains(t, challenge, "access_token")
+	return challenge["mfa_token"]
+}
+
+func (s *testServer) loginMFA(challenge, field, code string) *httptest.ResponseRecorder {
+	return s.do(http.MethodPost, "/users/login/mfa", `{"mfa_token":"`+challenge+`","`+field+`":"`+code+`"}`, "")
+}
+
+func TestRouter_TOTPEnrollment(t *testing.T) {
+	s := newTestServer(t)
+	token := s.token(t, entity.RoleViewer)
+	rec := s.do(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"123456"}`, token)
+	assert.Equal(t, http.StatusConflict, rec.Code, "nothing is enrolled yet")
+
+	rec = s.do(http.MethodPost, "/users/me/mfa/totp", "", token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var enrollment dto.TOTPEnrollmentOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&enrollment))
+	uri, err := url.Parse(enrollment.ProvisioningURI)
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "/Product API:j@j.com", uri.Path)
+	assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+
+	// Logins only require a code once the enrollment is confirmed.
+	assert.Equal(t, http.StatusOK, s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "").Code)
+	rec = s.do(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"`+totpCode(t, enrollment.Secret, 5)+`"}`, token)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"code"`)
+
+	rec = s.do(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"`+totpCode(t, enrollment.Secret, 0)+`"}`, token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var recovery dto.RecoveryCodesOutput
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&recovery))
+	assert.Len(t, recovery.RecoveryCodes, entity.RecoveryCodeCount)
+
+	var me dto.UserOutput
+	assert.NoError(t, json.NewDecoder(s.do(http.MethodGet, "/users/me", "", token).Body).Decode(&me))
+	assert.True(t, me.MFAEnabled)
+	assert.NotContains(t, s.do(http.MethodGet, "/users/me", "", token).Body.String(), enrollment.Secret)
+
+	// The secret cannot be replaced with an access token alone.
+	assert.Equal(t, http.StatusConflict, s.do(http.MethodPost, "/users/me/mfa/totp", "", token).Code)
+	rec = s.do(http.MethodPost, "/users/me/mfa/totp/confirm", `{"code":"`+totpCode(t, enrollment.Secret, 1)+`"}`, token)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestRouter_LoginWithTOTP(t *testing.T) {
+	s := newTestServer(t)
+	secret, _ := s.enableTOTP(t)
+	challenge := s.mfaChallenge(t)
+
+	// The codes of the step confirmed and of earlier ones are used up.
+	rec := s.loginMFA(challenge, "code", totpCode(t, secret, -1))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"code"`)
+
+	code := totpCode(t, secret, 1)
+	rec = s.loginMFA(challenge, "code", code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token, err := s.tokenAuth.Decode(s.accessToken(t, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, s.userID, token.Subject())
+
+	rec = s.loginMFA(s.mfaChallenge(t), "code", code)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "codes are single-use")
+
+	rec = s.loginMFA("forged", "code", totpCode(t, secret, -1))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.TypeInvalidToken)
+}
+
+func TestRouter_LoginWithRecoveryCode(t *testing.T) {
+	s := newTestServer(t)
+	_, codes := s.enableTOTP(t)
+
+	rec := s.loginMFA(s.mfaChallenge(t), "recovery_code", strings.ToUpper(codes[0]))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = s.loginMFA(s.mfaChallenge(t), "recovery_code", codes[0])
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"recovery_code"`)
+	assert.Equal(t, http.StatusOK, s.loginMFA(s.mfaChallenge(t), "recovery_code", codes[1]).Code)
+}
+
+func TestRouter_LoginMFALocksOut(t *testing.T) {
+	s := newTestServer(t)
+	secret, _ := s.enableTOTP(t)
+	challenge := s.mfaChallenge(t)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusBadRequest, s.loginMFA(challenge, "code", "000000").Code)
+	}
+	rec := s.loginMFA(challenge, "code", totpCode(t, secret, 1))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	// The password alone does not lift the lockout either.
+	assert.Equal(t, http.StatusTooManyRequests, s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "").Code)
+}
+
+func TestNewKeySet_KeepsLegacySecret(t *testing.T) {
+	private, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("could not write key: %v", err)
+	}
+	legacy, err := newKeySet("", "secret", time.Hour)
+	assert.NoError(t, err)
+	_, token, _ := legacy.Encode(map[string]interface{}{"sub": "john"})
+
+	// Tokens signed with JWT_SECRET survive the move to JWT_KEYS.
+	set, err := newKeySet(path, "secret", time.Hour)
+	assert.NoError(t, err)
+	_, err = set.Decode(token)
+	assert.NoError(t, err)
+	public, _ := set.PublicKeys()
+	assert.Equal(t, 1, public.Len())
+
+	set, err = newKeySet(path, "", time.Hour)
+	assert.NoError(t, err)
+	_, err = set.Decode(token)
+	assert.Error(t, err)
+}
+
+func TestNewKeySet_RequiresSecret(t *testing.T) {
+	_, err := newKeySet("", "", time.Hour)
+	assert.Error(t, err)
+}
+
+func TestTokenSecret(t *testing.T) {
+	secret, err := tokenSecret("MFA_SECRET", "", "", "jwt-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("jwt-secret"), secret)
+	secret, err = tokenSecret("MFA_SECRET", "mfa-secret", "key.pem", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("mfa-secret"), secret)
+
+	// Nothing falls back to the JWT secret kept to verify older tokens.
+	_, err = tokenSecret("MFA_SECRET", "", "key.pem", "jwt-secret")
+	assert.ErrorContains(t, err, "MFA_SECRET")
+	_, err = tokenSecret("MFA_SECRET", "", "key.pem", "")
+	assert.Error(t, err)
+	_, err = tokenSecret("MFA_SECRET", "", "", "")
+	assert.Error(t, err)
+}
+
+func TestRouter_JWKS(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodGet, "/.well-known/jwks.json", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/jwk-set+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+	assert.NotContains(t, rec.Body.String(), `"d"`, "private keys are never published")
+	published, err := jwk.Parse(rec.Body.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published.Len())
+
+	// Other services verify access tokens with the published keys alone.
+	rec = s.do(http.MethodPost, "/users/login", `{"email":"j@j.com","password":"s3cure-pass"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token, err := jwt.Parse([]byte(s.accessToken(t, rec)), jwt.WithKeySet(published))
+	assert.NoError(t, err)
+	assert.Equal(t, s.userID, token.Subject())
+
+	// Tokens signed with a shared secret are rejected.
+	_, forged, _ := jwtauth.New("HS256", []byte("secret"), nil).Encode(map[string]interface{}{
+		"sub":    s.userID,
+		"role":   string(entity.RoleAdmin),
+		"tenant": entity.DefaultOrganizationID.String(),
+		"exp":    jwtauth.ExpireIn(time.Minute),
+	})
+	assert.Equal(t, http.StatusUnauthorized, s.do(http.MethodGet, "/users/me", "", forged).Code)
+}
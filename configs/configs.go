@@ -1,30 +1,70 @@
 package configs
 
 import (
-	"github.com/go-chi/jwtauth/v5"
+	"path/filepath"
+
 	"github.com/spf13/viper"
 )
 
 var cfg *conf
 
 type conf struct {
-	DBDriver      string           `mapstructure:"DB_DRIVER"`
-	DBHost        string           `mapstructure:"DB_HOST"`
-	DBPort        string           `mapstructure:"DB_PORT"`
-	DBUser        string           `mapstructure:"DB_USER"`
-	DBPass        string           `mapstructure:"DB_PASS"`
-	DBName        string           `mapstructure:"DB_NAME"`
-	WebServerPort string           `mapstructure:"WEB_SERVER_PORT"`
-	JwtSecret     string           `mapstructure:"JWT_SECRET"`
-	JwtExpiresIn  int              `mapstructure:"JWT_EXPIRES_IN"`
-	TokenAuth     *jwtauth.JWTAuth `mapstructure:"-"`
+	DBDriver              string `mapstructure:"DB_DRIVER"`
+	DBHost                string `mapstructure:"DB_HOST"`
+	DBPort                string `mapstructure:"DB_PORT"`
+	DBUser                string `mapstructure:"DB_USER"`
+	DBPass                string `mapstructure:"DB_PASS"`
+	DBName                string `mapstructure:"DB_NAME"`
+	DBSSLMode             string `mapstructure:"DB_SSL_MODE"`
+	DBMaxOpenConns        int    `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns        int    `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime     int    `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBAutoMigrate         bool   `mapstructure:"DB_AUTO_MIGRATE"`
+	ProductRetention      int    `mapstructure:"PRODUCT_RETENTION_DAYS"`
+	PurgeInterval         int    `mapstructure:"PURGE_INTERVAL"`
+	RequireIfMatch        bool   `mapstructure:"REQUIRE_IF_MATCH"`
+	WebServerPort         string `mapstructure:"WEB_SERVER_PORT"`
+	JwtSecret             string `mapstructure:"JWT_SECRET"`
+	JwtKeys               string `mapstructure:"JWT_KEYS"`
+	JwtExpiresIn          int    `mapstructure:"JWT_EXPIRES_IN"`
+	JwtRefreshExpiresIn   int    `mapstructure:"JWT_REFRESH_EXPIRES_IN"`
+	AdminEmail            string `mapstructure:"ADMIN_EMAIL"`
+	PasswordMinLength     int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool   `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool   `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool   `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool   `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	MailDriver            string `mapstructure:"MAIL_DRIVER"`
+	MailFrom              string `mapstructure:"MAIL_FROM"`
+	MailDir               string `mapstructure:"MAIL_DIR"`
+	SMTPHost              string `mapstructure:"SMTP_HOST"`
+	SMTPPort              string `mapstructure:"SMTP_PORT"`
+	SMTPUser              string `mapstructure:"SMTP_USER"`
+	SMTPPass              string `mapstructure:"SMTP_PASS"`
+	PasswordResetExpires  int    `mapstructure:"PASSWORD_RESET_EXPIRES_IN"`
+	PasswordResetURL      string `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetMax      int    `mapstructure:"PASSWORD_RESET_MAX_REQUESTS"`
+	PasswordResetIPMax    int    `mapstructure:"PASSWORD_RESET_IP_MAX_REQUESTS"`
+	VerificationRequired  bool   `mapstructure:"EMAIL_VERIFICATION_REQUIRED"`
+	VerificationSecret    string `mapstructure:"EMAIL_VERIFICATION_SECRET"`
+	VerificationExpiresIn int    `mapstructure:"EMAIL_VERIFICATION_EXPIRES_IN"`
+	VerificationResendIn  int    `mapstructure:"EMAIL_VERIFICATION_RESEND_INTERVAL"`
+	VerificationURL       string `mapstructure:"EMAIL_VERIFICATION_URL"`
+	LoginMaxFailures      int    `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures    int    `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+	LoginLockout          int    `mapstructure:"LOGIN_LOCKOUT"`
+	LoginMaxLockout       int    `mapstructure:"LOGIN_MAX_LOCKOUT"`
+	LoginFailureWindow    int    `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	MFAIssuer             string `mapstructure:"MFA_ISSUER"`
+	MFASecret             string `mapstructure:"MFA_SECRET"`
+	MFAExpiresIn          int    `mapstructure:"MFA_CHALLENGE_EXPIRES_IN"`
 }
 
 func LoadConfig(path string) (*conf, error) {
 	viper.SetConfigName("app_config")
 	viper.SetConfigType("env")
 	viper.AddConfigPath(path)
-	viper.SetConfigFile(".env")
+	viper.SetConfigFile(filepath.Join(path, ".env"))
 	viper.AutomaticEnv()
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -32,6 +72,5 @@ func LoadConfig(path string) (*conf, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
-	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JwtSecret), nil)
 	return cfg, nil
 }
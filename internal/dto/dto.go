@@ -1,13 +1,24 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
 type CreateProductInput struct {
-	Name  string `json:"name"`
-	Price int    `json:"price"`
+	Name        string          `json:"name"`
+	Price       pkgEntity.Money `json:"price"`
+	CategoryIDs []string        `json:"category_ids"`
 }
 
+// UpdateProductInput holds every modifiable field of a product: a PUT
+// replaces them all and a PATCH is merged into them.
 type UpdateProductInput struct {
-	Name  string `json:"name"`
-	Price int    `json:"price"`
+	Name        string          `json:"name"`
+	Price       pkgEntity.Money `json:"price"`
+	CategoryIDs []string        `json:"category_ids"`
 }
 
 type CreateUserInput struct {
@@ -16,11 +27,178 @@ type CreateUserInput struct {
 	Password string `json:"password"`
 }
 
+// UpdateUserInput holds the fields users may change in their own profile.
+type UpdateUserInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordInput holds the token mailed on ForgotPasswordInput and the
+// new password.
+type ResetPasswordInput struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email"`
+}
+
+// UserOutput is the representation of a user in responses, which leaves
+// the password hash out.
+type UserOutput struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Email          string      `json:"email"`
+	Role           entity.Role `json:"role"`
+	OrganizationID string      `json:"organization_id"`
+	EmailVerified  bool        `json:"email_verified"`
+	MFAEnabled     bool        `json:"mfa_enabled"`
+}
+
+func NewUserOutput(u *entity.User) UserOutput {
+	return UserOutput{
+		ID:             u.ID.String(),
+		Name:           u.Name,
+		Email:          u.Email,
+		Role:           u.Role,
+		OrganizationID: u.OrganizationID.String(),
+		EmailVerified:  u.EmailVerified(),
+		MFAEnabled:     u.TOTPEnabled(),
+	}
+}
+
+type CreateOrganizationInput struct {
+	Name  string          `json:"name"`
+	Admin CreateUserInput `json:"admin"`
+}
+
 type LoginUserInput struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
 type LoginUserOutput struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// MFAChallengeOutput answers the login of a user with a second factor, to
+// be given back along with a code in MFALoginInput.
+type MFAChallengeOutput struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+// MFALoginInput completes a login with either a TOTP code or a recovery
+// code.
+type MFALoginInput struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TOTPEnrollmentOutput holds the secret to enroll in an authenticator app,
+// either typed in or scanned as a QR code of the provisioning URI.
+type TOTPEnrollmentOutput struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type ConfirmTOTPInput struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesOutput holds recovery codes in plain text, which are only
+// ever shown once.
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// CreateAPIKeyInput names an API key and lists its scopes, among
+// products:read and products:write. Keys without expires_at never expire.
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyOutput describes an API key by its prefix: the key itself is only
+// shown once, in CreatedAPIKeyOutput.
+type APIKeyOutput struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func NewAPIKeyOutput(k *entity.APIKey) APIKeyOutput {
+	scopes := []string{}
+	for _, scope := range k.ScopeList() {
+		scopes = append(scopes, string(scope))
+	}
+	return APIKeyOutput{
+		ID:         k.ID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// CreatedAPIKeyOutput holds a new API key in plain text, which is never
+// shown again.
+type CreatedAPIKeyOutput struct {
+	APIKeyOutput
+	Key string `json:"key"`
+}
+
+type UpdateUserRoleInput struct {
+	Role string `json:"role"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type CreateCategoryInput struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+type UpdateCategoryInput struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+type CategoryTreeOutput struct {
+	ID       string               `json:"id"`
+	Name     string               `json:"name"`
+	Children []CategoryTreeOutput `json:"children"`
+}
+
+type CreateStockMovementInput struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note"`
+}
+
+type ProductPageOutput struct {
+	Items      []entity.Product `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	PrevCursor string           `json:"prev_cursor,omitempty"`
+	Total      int64            `json:"total"`
 }
@@ -0,0 +1,134 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("Invalid API Key")
+	ErrScopesRequired = errors.New("Scopes are required")
+	ErrInvalidScope   = errors.New("Invalid Scope")
+	ErrInvalidExpiry  = errors.New("Expiry must be in the future")
+)
+
+// Scope grants an API key access to part of the API. Keys only ever act
+// within the role of their user: a products:write key of a viewer cannot
+// change the catalog either.
+type Scope string
+
+const (
+	ScopeProductsRead  Scope = "products:read"
+	ScopeProductsWrite Scope = "products:write"
+)
+
+var scopes = map[Scope]bool{
+	ScopeProductsRead:  true,
+	ScopeProductsWrite: true,
+}
+
+// APIKeyPrefix starts every API key, so that leaked keys are easy to spot.
+const APIKeyPrefix = "pk_"
+
+// APIKey lets programs call the API on behalf of a user, with the scopes
+// it grants, without the password of the user. Only the hash of the key is
+// stored, along with its first characters, which tell keys apart.
+type APIKey struct {
+	ID         entity.ID  `json:"id"`
+	UserID     entity.ID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey returns an API key of the user with the scopes, which never
+// expires if expiresAt is nil, along with its plain text value. The error
+// joins every validation error.
+func NewAPIKey(userID entity.ID, name string, scopes []Scope, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	var errs []error
+	if name == "" {
+		errs = append(errs, ErrNameRequired)
+	}
+	if err := validateScopes(scopes); err != nil {
+		errs = append(errs, err)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		errs = append(errs, ErrInvalidExpiry)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, "", err
+	}
+	prefix, err := newAPIKeyPrefix()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key := prefix + "_" + secret
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return &APIKey{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   HashToken(key),
+		Scopes:    strings.Join(names, " "),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}, key, nil
+}
+
+func validateScopes(list []Scope) error {
+	if len(list) == 0 {
+		return ErrScopesRequired
+	}
+	for _, scope := range list {
+		if !scopes[scope] {
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+// newAPIKeyPrefix returns APIKeyPrefix followed by 8 random characters.
+func newAPIKeyPrefix() (string, error) {
+	random, err := randomString("abcdefghijklmnopqrstuvwxyz0123456789", 8)
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + random, nil
+}
+
+// ScopeList returns the scopes the key grants.
+func (k *APIKey) ScopeList() []Scope {
+	var list []Scope
+	for _, scope := range strings.Fields(k.Scopes) {
+		list = append(list, Scope(scope))
+	}
+	return list
+}
+
+// Validate reports whether the key can still be used.
+func (k *APIKey) Validate() error {
+	if k.RevokedAt != nil {
+		return ErrInvalidAPIKey
+	}
+	if k.ExpiresAt != nil && !time.Now().Before(*k.ExpiresAt) {
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
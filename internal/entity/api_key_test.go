@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	userID := entity.NewID()
+	key, plain, err := NewAPIKey(userID, " Batch ", []Scope{ScopeProductsRead, ScopeProductsWrite}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, key.UserID)
+	assert.Equal(t, "Batch", key.Name)
+	assert.Regexp(t, `^pk_[a-z0-9]{8}$`, key.Prefix)
+	assert.True(t, strings.HasPrefix(plain, key.Prefix+"_"))
+	assert.Equal(t, HashToken(plain), key.KeyHash)
+	assert.Equal(t, []Scope{ScopeProductsRead, ScopeProductsWrite}, key.ScopeList())
+	assert.NoError(t, key.Validate())
+
+	_, other, _ := NewAPIKey(userID, "Batch", []Scope{ScopeProductsRead}, nil)
+	assert.NotEqual(t, plain, other)
+}
+
+func TestNewAPIKey_Validation(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	_, _, err := NewAPIKey(entity.NewID(), "", nil, &past)
+	assert.ErrorIs(t, err, ErrNameRequired)
+	assert.ErrorIs(t, err, ErrScopesRequired)
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+
+	_, _, err = NewAPIKey(entity.NewID(), "Batch", []Scope{"users:write"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func TestAPIKey_Validate(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	key, _, _ := NewAPIKey(entity.NewID(), "Batch", []Scope{ScopeProductsRead}, &future)
+	assert.NoError(t, key.Validate())
+
+	past := time.Now().Add(-time.Minute)
+	key.ExpiresAt = &past
+	assert.ErrorIs(t, key.Validate(), ErrInvalidAPIKey)
+
+	key.ExpiresAt = nil
+	now := time.Now()
+	key.RevokedAt = &now
+	assert.ErrorIs(t, key.Validate(), ErrInvalidAPIKey)
+}
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// Audited actions.
+const (
+	AuditLoginLocked = "login.locked"
+)
+
+// AuditEntry records a security event: what happened, to which subject, such
+// as an email or an IP address, and from which client.
+type AuditEntry struct {
+	ID        entity.ID  `json:"id"`
+	Action    string     `json:"action"`
+	Subject   string     `json:"subject"`
+	UserID    *entity.ID `json:"user_id"`
+	IP        string     `json:"ip"`
+	Detail    string     `json:"detail"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewAuditEntry(action, subject, ip, detail string) *AuditEntry {
+	return &AuditEntry{
+		ID:        entity.NewID(),
+		Action:    action,
+		Subject:   subject,
+		IP:        ip,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+}
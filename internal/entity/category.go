@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+var ErrInvalidParent = errors.New("Invalid Parent")
+
+type Category struct {
+	ID       entity.ID  `json:"id"`
+	Name     string     `json:"name"`
+	ParentID *entity.ID `json:"parent_id"`
+	// OrganizationID is the tenant the category belongs to.
+	OrganizationID entity.ID `json:"organization_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func NewCategory(name string, parentID *entity.ID) (*Category, error) {
+	c := &Category{
+		ID:        entity.NewID(),
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: time.Now(),
+		// The repository of a tenant moves the category into it.
+		OrganizationID: DefaultOrganizationID,
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Category) Validate() error {
+	if c.ID.String() == "" {
+		return ErrIdRequired
+	}
+
+	if _, err := entity.ParseID(c.ID.String()); err != nil {
+		return ErrInvalidID
+	}
+
+	if c.Name == "" {
+		return ErrNameRequired
+	}
+
+	if c.ParentID != nil && *c.ParentID == c.ID {
+		return ErrInvalidParent
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCategory(t *testing.T) {
+	parent, err := NewCategory("Electronics", nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, parent)
+	assert.NotEmpty(t, parent.ID)
+	assert.Nil(t, parent.ParentID)
+
+	child, err := NewCategory("Phones", &parent.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, parent.ID, *child.ParentID)
+}
+
+func TestCategoryWhenNameIsEmpty(t *testing.T) {
+	category, err := NewCategory("", nil)
+	assert.Nil(t, category)
+	assert.Equal(t, ErrNameRequired, err)
+}
+
+func TestCategoryWhenParentIsItself(t *testing.T) {
+	category, _ := NewCategory("Electronics", nil)
+	category.ParentID = &category.ID
+	assert.Equal(t, ErrInvalidParent, category.Validate())
+}
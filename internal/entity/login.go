@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrLoginLocked = errors.New("Too Many Failed Logins")
+
+// LockoutPolicy locks logins out after MaxFailures failures in a row, for
+// Lockout at first and twice as long on each further failure, up to
+// MaxLockout, if greater. Failures are forgotten once none happened for
+// Window.
+type LockoutPolicy struct {
+	MaxFailures int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+	Window      time.Duration
+}
+
+// LockoutFor returns how long logins are locked out after the number of
+// failures in a row, zero while below MaxFailures.
+func (p LockoutPolicy) LockoutFor(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+	maxLockout := p.MaxLockout
+	if maxLockout < p.Lockout {
+		maxLockout = p.Lockout
+	}
+	lockout := p.Lockout
+	for i := p.MaxFailures; i < failures && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > maxLockout {
+		lockout = maxLockout
+	}
+	return lockout
+}
+
+// LoginFailures counts the failed logins in a row of a subject, which is
+// either an email or the IP address of a client.
+type LoginFailures struct {
+	Subject       string `gorm:"primaryKey"`
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// RetryAfter returns how long logins of the subject remain locked out.
+func (f *LoginFailures) RetryAfter(now time.Time) time.Duration {
+	if f.LockedUntil == nil || !now.Before(*f.LockedUntil) {
+		return 0
+	}
+	return f.LockedUntil.Sub(now)
+}
+
+var (
+	unknownUserOnce sync.Once
+	unknownUserHash []byte
+)
+
+// CompareUnknownUserPassword takes as long as User.ComparePassword, and
+// always fails, so that logins of unknown emails cannot be told apart from
+// those of users by their duration.
+func CompareUnknownUserPassword(password string) error {
+	unknownUserOnce.Do(func() {
+		unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+	})
+	if err := bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password)); err != nil {
+		return err
+	}
+	return bcrypt.ErrMismatchedHashAndPassword
+}
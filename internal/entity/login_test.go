@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy_LockoutFor(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, Lockout: time.Minute, MaxLockout: 10 * time.Minute}
+	tests := []struct {
+		failures int
+		lockout  time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.lockout, policy.LockoutFor(tt.failures), "%d failures", tt.failures)
+	}
+	assert.Zero(t, LockoutPolicy{}.LockoutFor(100), "no policy locks nothing")
+	fixed := LockoutPolicy{MaxFailures: 3, Lockout: time.Minute}
+	assert.Equal(t, time.Minute, fixed.LockoutFor(100), "the lockout only grows up to MaxLockout")
+}
+
+func TestLoginFailures_RetryAfter(t *testing.T) {
+	now := time.Now()
+	f := &LoginFailures{Subject: "j@j.com", Failures: 1}
+	assert.Zero(t, f.RetryAfter(now))
+	lockedUntil := now.Add(time.Minute)
+	f.LockedUntil = &lockedUntil
+	assert.Equal(t, time.Minute, f.RetryAfter(now))
+	assert.Zero(t, f.RetryAfter(lockedUntil))
+}
+
+func TestCompareUnknownUserPassword(t *testing.T) {
+	assert.Error(t, CompareUnknownUserPassword("unknown user"))
+	assert.Error(t, CompareUnknownUserPassword("s3cure-pass"))
+}
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+var ErrInvalidMFAToken = errors.New("Invalid MFA Token")
+
+// RecoveryCodeCount is how many recovery codes a user gets on enrolling
+// TOTP.
+const RecoveryCodeCount = 10
+
+// RecoveryCode lets a user who lost their authenticator app log in once in
+// place of a TOTP code. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// recoveryAlphabet leaves out the letters and digits that read alike.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewRecoveryCodes returns n recovery codes of the user along with their
+// plain text values, formatted as xxxxx-xxxxx.
+func NewRecoveryCodes(userID entity.ID, n int) ([]RecoveryCode, []string, error) {
+	codes := make([]RecoveryCode, n)
+	plain := make([]string, n)
+	for i := range codes {
+		code, err := randomString(recoveryAlphabet, 10)
+		if err != nil {
+			return nil, nil, err
+		}
+		plain[i] = code[:5] + "-" + code[5:]
+		codes[i] = RecoveryCode{
+			ID:        entity.NewID(),
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(plain[i]),
+			CreatedAt: time.Now(),
+		}
+	}
+	return codes, plain, nil
+}
+
+// HashRecoveryCode returns the hash a recovery code is stored as. Codes
+// are compared whatever their case, spaces and dashes, as users type them.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return HashToken(code)
+}
+
+const mfaChallengePurpose = "mfa-challenge"
+
+// NewMFAChallenge returns the token a user who gave their password
+// exchanges, along with a TOTP or recovery code, for an access token.
+func NewMFAChallenge(u *User, secret []byte, ttl time.Duration) string {
+	return newSignedToken(mfaChallengePurpose, []string{u.ID.String()}, secret, ttl)
+}
+
+// ParseMFAChallenge returns the ID of the user a token of NewMFAChallenge
+// was issued to, if it is genuine and unexpired.
+func ParseMFAChallenge(token string, secret []byte) (entity.ID, error) {
+	fields, err := parseSignedToken(mfaChallengePurpose, token, secret, 1)
+	if err != nil {
+		return entity.ID{}, ErrInvalidMFAToken
+	}
+	id, err := entity.ParseID(fields[0])
+	if err != nil {
+		return entity.ID{}, ErrInvalidMFAToken
+	}
+	return id, nil
+}
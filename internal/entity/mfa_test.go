@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "s3cure-pass")
+	codes, plain, err := NewRecoveryCodes(user.ID, RecoveryCodeCount)
+	assert.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	assert.Len(t, plain, RecoveryCodeCount)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, plain[i])
+		assert.Equal(t, user.ID, code.UserID)
+		assert.Equal(t, HashRecoveryCode(plain[i]), code.CodeHash)
+		assert.NotContains(t, code.CodeHash, plain[i])
+		assert.False(t, seen[plain[i]])
+		seen[plain[i]] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	assert.Equal(t, HashRecoveryCode("abcde-fghjk"), HashRecoveryCode(" ABCDE fghjk"))
+	assert.Equal(t, HashRecoveryCode("abcde-fghjk"), HashRecoveryCode("abcdefghjk"))
+	assert.NotEqual(t, HashRecoveryCode("abcde-fghjk"), HashRecoveryCode("abcde-fghjm"))
+}
+
+func TestMFAChallenge(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "s3cure-pass")
+	secret := []byte("secret")
+	token := NewMFAChallenge(user, secret, time.Minute)
+
+	id, err := ParseMFAChallenge(token, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, id)
+
+	_, err = ParseMFAChallenge(token, []byte("other secret"))
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+	_, err = ParseMFAChallenge(NewMFAChallenge(user, secret, -time.Minute), secret)
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+	// Tokens of other purposes are not challenges, even with the secret.
+	_, err = ParseMFAChallenge(NewVerificationToken(user, secret, time.Hour), secret)
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+}
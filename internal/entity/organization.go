@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/google/uuid"
+)
+
+// DefaultOrganizationID identifies the organization created along with
+// multi-tenancy. It owns the users and products that existed before, and
+// the users who register on their own.
+var DefaultOrganizationID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Organization is a tenant: its users only ever see its own data.
+type Organization struct {
+	ID        entity.ID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewOrganization(name string) (*Organization, error) {
+	o := &Organization{
+		ID:        entity.NewID(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *Organization) Validate() error {
+	if _, err := entity.ParseID(o.ID.String()); err != nil {
+		return ErrInvalidID
+	}
+
+	if o.Name == "" {
+		return ErrNameRequired
+	}
+
+	return nil
+}
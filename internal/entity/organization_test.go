@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOrganization(t *testing.T) {
+	o, err := NewOrganization("Retail")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, o.ID)
+	assert.Equal(t, "Retail", o.Name)
+	assert.NotEqual(t, DefaultOrganizationID, o.ID)
+
+	_, err = NewOrganization("")
+	assert.Equal(t, ErrNameRequired, err)
+}
@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrWeakPassword = errors.New("Weak Password")
+
+// MaxPasswordLength is the number of bytes bcrypt hashes, longer passwords
+// being refused rather than silently truncated.
+const MaxPasswordLength = 72
+
+// PasswordPolicy lists the rules user passwords must follow.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy only asks for a length, as composition rules make
+// passwords harder to remember more than harder to guess.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+var passwordPolicy = DefaultPasswordPolicy
+
+// SetPasswordPolicy replaces the policy NewUser enforces.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// Validate checks the password against every rule of the policy, the error
+// wrapping ErrWeakPassword and naming all the rules it breaks.
+func (p PasswordPolicy) Validate(password string) error {
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("%w: it must be at most %d bytes long", ErrWeakPassword, MaxPasswordLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	var missing []string
+	if length := len([]rune(password)); length < p.MinLength {
+		missing = append(missing, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireUpper && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: it must contain %s", ErrWeakPassword, strings.Join(missing, ", "))
+	}
+	return nil
+}
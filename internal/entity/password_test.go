@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		valid    bool
+	}{
+		{"default long enough", DefaultPasswordPolicy, "correct horse", true},
+		{"default too short", DefaultPasswordPolicy, "1234567", false},
+		{"default counts characters", DefaultPasswordPolicy, "sécurité", true},
+		{"too long for bcrypt", DefaultPasswordPolicy, strings.Repeat("a", MaxPasswordLength+1), false},
+		{"strict", strict, "Correct-Horse-1", true},
+		{"strict without upper", strict, "correct-horse-1", false},
+		{"strict without lower", strict, "CORRECT-HORSE-1", false},
+		{"strict without digit", strict, "Correct-Horse-X", false},
+		{"strict without symbol", strict, "CorrectHorse12", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_ValidateNamesEveryRule(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireDigit: true, RequireSymbol: true}
+	err := policy.Validate("abc")
+	assert.ErrorContains(t, err, "at least 8 characters, a digit, a symbol")
+}
+
+func TestSetPasswordPolicy(t *testing.T) {
+	defer SetPasswordPolicy(DefaultPasswordPolicy)
+	SetPasswordPolicy(PasswordPolicy{MinLength: 4, RequireDigit: true})
+	_, err := NewUser("John Doe", "john@example.com", "abcd")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	_, err = NewUser("John Doe", "john@example.com", "abc1")
+	assert.NoError(t, err)
+}
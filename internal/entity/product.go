@@ -5,29 +5,40 @@ import (
 	"time"
 
 	"github.com/ThalesLoreto/product-api/pkg/entity"
+	"gorm.io/gorm"
 )
 
 var (
-	ErrIdRequired    = errors.New("ID is required")
-	ErrNameRequired  = errors.New("Name is required")
-	ErrPriceRequired = errors.New("Price is required")
-	ErrInvalidID     = errors.New("Invalid ID")
-	ErrInvalidPrice  = errors.New("Invalid Price")
+	ErrIdRequired      = errors.New("ID is required")
+	ErrNameRequired    = errors.New("Name is required")
+	ErrPriceRequired   = errors.New("Price is required")
+	ErrInvalidID       = errors.New("Invalid ID")
+	ErrInvalidPrice    = errors.New("Invalid Price")
+	ErrInvalidCurrency = errors.New("Invalid Currency")
 )
 
 type Product struct {
-	ID        entity.ID `json:"id"`
-	Name      string    `json:"name"`
-	Price     int       `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         entity.ID    `json:"id"`
+	Name       string       `json:"name"`
+	Price      entity.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Categories []Category   `json:"categories" gorm:"many2many:product_categories"`
+	OwnerID    *entity.ID   `json:"owner_id"`
+	// OrganizationID is the tenant the product belongs to.
+	OrganizationID entity.ID      `json:"organization_id"`
+	Version        int            `json:"version" gorm:"not null;default:1"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" swaggertype:"string"`
 }
 
-func NewProduct(name string, price int) (*Product, error) {
+func NewProduct(name string, price entity.Money) (*Product, error) {
 	p := &Product{
 		ID:        entity.NewID(),
 		Name:      name,
 		Price:     price,
+		Version:   1,
 		CreatedAt: time.Now(),
+		// The repository of a tenant moves the product into it.
+		OrganizationID: DefaultOrganizationID,
 	}
 
 	if err := p.Validate(); err != nil {
@@ -50,13 +61,36 @@ func (p *Product) Validate() error {
 		return ErrNameRequired
 	}
 
-	if p.Price == 0 {
+	if p.Price.Amount == 0 {
 		return ErrPriceRequired
 	}
 
-	if p.Price <= 0 {
+	if p.Price.Amount <= 0 {
 		return ErrInvalidPrice
 	}
 
+	if !entity.IsCurrency(p.Price.Currency) {
+		return ErrInvalidCurrency
+	}
+
 	return nil
 }
+
+// ModifiableBy reports whether the user may update or delete the product,
+// which only its owner and admins may do. Products created before owners
+// were recorded have none and only admins may modify them.
+func (p *Product) ModifiableBy(userID entity.ID, role Role) bool {
+	if role.Includes(RoleAdmin) {
+		return true
+	}
+	return p.OwnerID != nil && *p.OwnerID == userID
+}
+
+// ProductSearchResult is a product matched by a full-text search, with its
+// relevance score (higher is better) and the matched text highlighted. The
+// snippet is HTML: its text is escaped and the matches are in mark tags.
+type ProductSearchResult struct {
+	Product Product `json:"product"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
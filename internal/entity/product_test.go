@@ -3,13 +3,14 @@ package entity
 import (
 	"testing"
 
+	"github.com/ThalesLoreto/product-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewProduct(t *testing.T) {
 	t.Run("Create a new product", func(t *testing.T) {
 		name := "Product Test"
-		price := 1000
+		price := entity.Money{Amount: 1000, Currency: "USD"}
 
 		product, err := NewProduct(name, price)
 
@@ -24,7 +25,7 @@ func TestNewProduct(t *testing.T) {
 func TestProductWhenNameIsEmpty(t *testing.T) {
 	t.Run("Name is required", func(t *testing.T) {
 		name := ""
-		price := 1000
+		price := entity.Money{Amount: 1000, Currency: "USD"}
 
 		product, err := NewProduct(name, price)
 
@@ -37,7 +38,7 @@ func TestProductWhenNameIsEmpty(t *testing.T) {
 func TestProductWhenPriceIsZero(t *testing.T) {
 	t.Run("Price is required", func(t *testing.T) {
 		name := "Product Test"
-		price := 0
+		price := entity.Money{Amount: 0, Currency: "USD"}
 
 		product, err := NewProduct(name, price)
 
@@ -50,7 +51,7 @@ func TestProductWhenPriceIsZero(t *testing.T) {
 func TestProductWhenPriceIsNegative(t *testing.T) {
 	t.Run("Price is invalid", func(t *testing.T) {
 		name := "Product Test"
-		price := -1000
+		price := entity.Money{Amount: -1000, Currency: "USD"}
 
 		product, err := NewProduct(name, price)
 
@@ -59,3 +60,28 @@ func TestProductWhenPriceIsNegative(t *testing.T) {
 		assert.Equal(t, err, ErrInvalidPrice)
 	})
 }
+
+func TestProductWhenCurrencyIsUnknown(t *testing.T) {
+	t.Run("Currency is invalid", func(t *testing.T) {
+		name := "Product Test"
+		price := entity.Money{Amount: 1000, Currency: "XYZ"}
+
+		product, err := NewProduct(name, price)
+
+		assert.NotNil(t, err)
+		assert.Nil(t, product)
+		assert.Equal(t, err, ErrInvalidCurrency)
+	})
+}
+
+func TestProductModifiableBy(t *testing.T) {
+	p, _ := NewProduct("Product 1", entity.Money{Amount: 10, Currency: "USD"})
+	owner := entity.NewID()
+	assert.False(t, p.ModifiableBy(owner, RoleEditor))
+	assert.True(t, p.ModifiableBy(owner, RoleAdmin))
+
+	p.OwnerID = &owner
+	assert.True(t, p.ModifiableBy(owner, RoleEditor))
+	assert.False(t, p.ModifiableBy(entity.NewID(), RoleEditor))
+	assert.True(t, p.ModifiableBy(entity.NewID(), RoleAdmin))
+}
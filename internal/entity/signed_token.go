@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSignedToken = errors.New("invalid signed token")
+
+// newSignedToken returns a token carrying the fields until ttl elapses. It
+// is signed rather than stored, along with the purpose it is issued for so
+// that a token of one purpose is never valid for another, even with the same
+// secret.
+func newSignedToken(purpose string, fields []string, secret []byte, ttl time.Duration) string {
+	payload := strings.Join(append(fields, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)), "\n")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signToken(purpose, encoded, secret))
+}
+
+// parseSignedToken returns the fields of a token of newSignedToken, if it
+// is genuine, unexpired, issued for the purpose and has as many fields.
+func parseSignedToken(purpose, token string, secret []byte, fields int) ([]string, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, errInvalidSignedToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signToken(purpose, encoded, secret)) {
+		return nil, errInvalidSignedToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidSignedToken
+	}
+	parts := strings.Split(string(payload), "\n")
+	if len(parts) != fields+1 {
+		return nil, errInvalidSignedToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[fields], 10, 64)
+	if err != nil || !time.Now().Before(time.Unix(expiresAt, 0)) {
+		return nil, errInvalidSignedToken
+	}
+	return parts[:fields], nil
+}
+
+func signToken(purpose, encoded string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + "." + encoded))
+	return mac.Sum(nil)
+}
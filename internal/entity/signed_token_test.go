@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedToken(t *testing.T) {
+	secret := []byte("secret")
+	token := newSignedToken("purpose", []string{"a", "b"}, secret, time.Hour)
+
+	fields, err := parseSignedToken("purpose", token, secret, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, fields)
+
+	_, err = parseSignedToken("other purpose", token, secret, 2)
+	assert.ErrorIs(t, err, errInvalidSignedToken)
+	_, err = parseSignedToken("purpose", token, secret, 1)
+	assert.ErrorIs(t, err, errInvalidSignedToken)
+	_, err = parseSignedToken("purpose", token, []byte("other secret"), 2)
+	assert.ErrorIs(t, err, errInvalidSignedToken)
+	_, err = parseSignedToken("purpose", newSignedToken("purpose", []string{"a", "b"}, secret, -time.Minute), secret, 2)
+	assert.ErrorIs(t, err, errInvalidSignedToken)
+}
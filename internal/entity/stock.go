@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+type MovementType string
+
+const (
+	MovementReceipt    MovementType = "receipt"
+	MovementAdjustment MovementType = "adjustment"
+	MovementSale       MovementType = "sale"
+)
+
+var (
+	ErrProductIdRequired = errors.New("Product ID is required")
+	ErrInvalidMovement   = errors.New("Invalid Movement Type")
+	ErrQuantityRequired  = errors.New("Quantity is required")
+	ErrInvalidQuantity   = errors.New("Invalid Quantity")
+	ErrInsufficientStock = errors.New("Insufficient Stock")
+)
+
+// StockMovement is an entry of the append-only stock ledger. Quantity is the
+// signed change it causes to the on-hand level: receipts add, sales remove
+// and adjustments may do either.
+type StockMovement struct {
+	ID        entity.ID    `json:"id"`
+	ProductID entity.ID    `json:"product_id"`
+	Type      MovementType `json:"type"`
+	Quantity  int          `json:"quantity"`
+	Note      string       `json:"note"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type StockLevel struct {
+	ProductID entity.ID `json:"product_id" gorm:"primaryKey"`
+	OnHand    int       `json:"on_hand"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewStockMovement builds a ledger entry from the quantity as entered by the
+// user, which must be positive for receipts and sales and non-zero for
+// adjustments.
+func NewStockMovement(productID entity.ID, movementType MovementType, quantity int, note string) (*StockMovement, error) {
+	if movementType == MovementSale {
+		quantity = -quantity
+	}
+	m := &StockMovement{
+		ID:        entity.NewID(),
+		ProductID: productID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *StockMovement) Validate() error {
+	if m.ID.String() == "" {
+		return ErrIdRequired
+	}
+
+	if _, err := entity.ParseID(m.ID.String()); err != nil {
+		return ErrInvalidID
+	}
+
+	if m.ProductID == (entity.ID{}) {
+		return ErrProductIdRequired
+	}
+
+	if m.Quantity == 0 {
+		return ErrQuantityRequired
+	}
+
+	switch m.Type {
+	case MovementReceipt:
+		if m.Quantity < 0 {
+			return ErrInvalidQuantity
+		}
+	case MovementSale:
+		if m.Quantity > 0 {
+			return ErrInvalidQuantity
+		}
+	case MovementAdjustment:
+	default:
+		return ErrInvalidMovement
+	}
+
+	return nil
+}
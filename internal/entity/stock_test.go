@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStockMovement(t *testing.T) {
+	productID := entity.NewID()
+
+	receipt, err := NewStockMovement(productID, MovementReceipt, 10, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, receipt.Quantity)
+
+	sale, err := NewStockMovement(productID, MovementSale, 3, "order 42")
+	assert.Nil(t, err)
+	assert.Equal(t, -3, sale.Quantity)
+
+	adjustment, err := NewStockMovement(productID, MovementAdjustment, -2, "damaged")
+	assert.Nil(t, err)
+	assert.Equal(t, -2, adjustment.Quantity)
+}
+
+func TestStockMovementWhenInvalid(t *testing.T) {
+	productID := entity.NewID()
+
+	_, err := NewStockMovement(productID, MovementReceipt, 0, "")
+	assert.Equal(t, ErrQuantityRequired, err)
+
+	_, err = NewStockMovement(productID, MovementReceipt, -1, "")
+	assert.Equal(t, ErrInvalidQuantity, err)
+
+	_, err = NewStockMovement(productID, MovementSale, -1, "")
+	assert.Equal(t, ErrInvalidQuantity, err)
+
+	_, err = NewStockMovement(productID, "transfer", 1, "")
+	assert.Equal(t, ErrInvalidMovement, err)
+
+	_, err = NewStockMovement(entity.ID{}, MovementReceipt, 1, "")
+	assert.Equal(t, ErrProductIdRequired, err)
+}
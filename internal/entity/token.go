@@ -0,0 +1,144 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("Invalid Refresh Token")
+	ErrRefreshTokenReused  = errors.New("Refresh Token Reused")
+	ErrInvalidResetToken   = errors.New("Invalid Password Reset Token")
+	ErrResetThrottled      = errors.New("Too Many Password Reset Requests")
+)
+
+// RefreshToken is a long-lived credential exchanged for new access tokens.
+// Each exchange rotates it: the token is revoked and replaced by a new one
+// of the same family, so that presenting a revoked token again reveals that
+// it leaked and the whole family can be revoked. Only the hash of the token
+// is stored.
+type RefreshToken struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	FamilyID  entity.ID  `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken denies an access token, identified by its jti claim, until
+// it would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordResetToken lets a user who forgot their password choose a new
+// one. It is mailed to the user, expires shortly and can only be used once.
+// Only the hash of the token is stored.
+type PasswordResetToken struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewRefreshToken returns a refresh token of the family, which starts a new
+// one when familyID is nil, along with its plain text value.
+func NewRefreshToken(userID entity.ID, familyID *entity.ID, ttl time.Duration) (*RefreshToken, string, error) {
+	token, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	t := &RefreshToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+	t.FamilyID = t.ID
+	if familyID != nil {
+		t.FamilyID = *familyID
+	}
+	return t, token, nil
+}
+
+// NewPasswordResetToken returns a reset token of the user along with its
+// plain text value.
+func NewPasswordResetToken(userID entity.ID, ttl time.Duration) (*PasswordResetToken, string, error) {
+	token, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	return &PasswordResetToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}, token, nil
+}
+
+// newSecret returns 256 random bits, encoded to fit in URLs.
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// randomString returns n characters drawn uniformly from the alphabet,
+// which holds at most 256 of them. The random bytes above the largest
+// multiple of the alphabet length are discarded, lest the first characters
+// come up more often than the others.
+func randomString(alphabet string, n int) (string, error) {
+	limit := 256 - 256%len(alphabet)
+	s := make([]byte, 0, n)
+	random := make([]byte, n)
+	for len(s) < n {
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		for _, b := range random {
+			if int(b) < limit && len(s) < n {
+				s = append(s, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(s), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate reports whether the token can still be exchanged.
+func (t *RefreshToken) Validate() error {
+	if t.RevokedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// Validate reports whether the token can still reset the password.
+func (t *PasswordResetToken) Validate() error {
+	if t.UsedAt != nil || !time.Now().Before(t.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+	return nil
+}
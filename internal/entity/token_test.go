@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	userID := entity.NewID()
+	token, plain, err := NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, plain)
+	assert.Equal(t, userID, token.UserID)
+	assert.Equal(t, token.ID, token.FamilyID)
+	assert.Equal(t, HashToken(plain), token.TokenHash)
+	assert.NotEqual(t, plain, token.TokenHash)
+	assert.NoError(t, token.Validate())
+
+	next, nextPlain, err := NewRefreshToken(userID, &token.FamilyID, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, nextPlain)
+	assert.Equal(t, token.FamilyID, next.FamilyID)
+}
+
+func TestRefreshToken_Validate(t *testing.T) {
+	token, _, _ := NewRefreshToken(entity.NewID(), nil, -time.Minute)
+	assert.ErrorIs(t, token.Validate(), ErrInvalidRefreshToken)
+
+	token, _, _ = NewRefreshToken(entity.NewID(), nil, time.Hour)
+	now := time.Now()
+	token.RevokedAt = &now
+	assert.ErrorIs(t, token.Validate(), ErrRefreshTokenReused)
+}
+
+func TestPasswordResetToken_Validate(t *testing.T) {
+	token, plain, err := NewPasswordResetToken(entity.NewID(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, HashToken(plain), token.TokenHash)
+	assert.NoError(t, token.Validate())
+
+	now := time.Now()
+	token.UsedAt = &now
+	assert.ErrorIs(t, token.Validate(), ErrInvalidResetToken)
+
+	token, _, _ = NewPasswordResetToken(entity.NewID(), -time.Minute)
+	assert.ErrorIs(t, token.Validate(), ErrInvalidResetToken)
+}
+
+func TestRandomString(t *testing.T) {
+	s, err := randomString(recoveryAlphabet, 310000)
+	assert.NoError(t, err)
+	assert.Len(t, s, 310000)
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+	assert.Len(t, counts, len(recoveryAlphabet))
+	// Each character comes up 10000 times give or take 100. Taking the
+	// bytes modulo 31 would make the first 8 come up 12% more often.
+	for c, count := range counts {
+		assert.InDelta(t, 10000, count, 500, string(c))
+	}
+}
@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc6238Secret is the SHA1 key of the test vectors of RFC 6238,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP_RFC6238Vectors(t *testing.T) {
+	// The RFC gives 8-digit codes, of which 6-digit codes are the last 6.
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		step, err := ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0), 0)
+		assert.NoError(t, err, v.unix)
+		assert.Equal(t, v.unix/30, step)
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	code, err := TOTPCode(rfc6238Secret, time.Unix(1234567890, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "005924", code)
+	_, err = TOTPCode("not base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	_, err := ValidateTOTP(rfc6238Secret, "050471", now.Add(30*time.Second), 0)
+	assert.NoError(t, err)
+	_, err = ValidateTOTP(rfc6238Secret, "050471", now.Add(-30*time.Second), 0)
+	assert.NoError(t, err)
+	_, err = ValidateTOTP(rfc6238Secret, "050471", now.Add(90*time.Second), 0)
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+}
+
+func TestValidateTOTP_RejectsUsedSteps(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step, err := ValidateTOTP(rfc6238Secret, "050471", now, 0)
+	assert.NoError(t, err)
+	_, err = ValidateTOTP(rfc6238Secret, "050471", now, step)
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+}
+
+func TestValidateTOTP_RejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for _, code := range []string{"", "05047", "0504710", "abcdef"} {
+		_, err := ValidateTOTP(rfc6238Secret, code, now, 0)
+		assert.ErrorIs(t, err, ErrInvalidMFACode, code)
+	}
+	_, err := ValidateTOTP("not base32!", "050471", now, 0)
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+	other, err := NewTOTPSecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Product API", "j@j.com", rfc6238Secret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Product API:j@j.com", uri.Path)
+	query := uri.Query()
+	assert.Equal(t, rfc6238Secret, query.Get("secret"))
+	assert.Equal(t, "Product API", query.Get("issuer"))
+	assert.Equal(t, "SHA1", query.Get("algorithm"))
+	assert.Equal(t, "6", query.Get("digits"))
+	assert.Equal(t, "30", query.Get("period"))
+}
@@ -1,28 +1,162 @@
 package entity
 
 import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
 	"github.com/ThalesLoreto/product-api/pkg/entity"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidRole   = errors.New("Invalid Role")
+	ErrEmailRequired = errors.New("Email is required")
+	ErrInvalidEmail  = errors.New("Invalid Email")
+)
+
+// Role grants a user access to the API. Each role includes the rights of
+// the ones below it: viewers read, editors also change the catalog and
+// admins also manage users.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func ParseRole(role string) (Role, error) {
+	if _, ok := roleRanks[Role(role)]; !ok {
+		return "", ErrInvalidRole
+	}
+	return Role(role), nil
+}
+
+// Includes reports whether r grants the rights of other. Unknown roles
+// grant nothing.
+func (r Role) Includes(other Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[other]
+}
+
+// User is never encoded as is in responses, see dto.UserOutput: the
+// password hash is not even encoded to JSON in case it were.
 type User struct {
 	ID       entity.ID `json:"id"`
 	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Password string    `json:"password"`
+	Email    string    `json:"email" gorm:"uniqueIndex"`
+	Password string    `json:"-"`
+	Role     Role      `json:"role" gorm:"not null;default:viewer"`
+	// OrganizationID is the tenant the user is a member of.
+	OrganizationID entity.ID `json:"organization_id"`
+	// EmailVerifiedAt is when the user proved to own the email, nil until
+	// then. VerificationSentAt is when the proof was last asked for.
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	VerificationSentAt *time.Time `json:"-"`
+	// TOTPSecret is the secret of the authenticator app of the user, set on
+	// enrollment and only asked for on login from TOTPEnabledAt, once the
+	// user proved to have enrolled it. TOTPLastStep is the time step of the
+	// last code used, which cannot be used again.
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	TOTPLastStep  int64      `json:"-"`
 }
 
+// NewUser validates the user and hashes the password, which must follow
+// the policy set with SetPasswordPolicy. The error joins every validation
+// error, so that they can all be reported at once.
 func NewUser(name, email, password string) (*User, error) {
+	u := &User{
+		ID:    entity.NewID(),
+		Name:  strings.TrimSpace(name),
+		Email: NormalizeEmail(email),
+		Role:  RoleViewer,
+		// Users join another organization when it creates them.
+		OrganizationID: DefaultOrganizationID,
+	}
+	if err := errors.Join(u.Validate(), passwordPolicy.Validate(password)); err != nil {
+		return nil, err
+	}
+	if err := u.hashPassword(password); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SetPassword replaces the password of the user, which must follow the
+// policy set with SetPasswordPolicy.
+func (u *User) SetPassword(password string) error {
+	if err := passwordPolicy.Validate(password); err != nil {
+		return err
+	}
+	return u.hashPassword(password)
+}
+
+func (u *User) hashPassword(password string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	u.Password = string(hash)
+	return nil
+}
+
+// Validate checks the fields of the user but its password, which is only
+// known hashed.
+func (u *User) Validate() error {
+	var errs []error
+	if u.Name == "" {
+		errs = append(errs, ErrNameRequired)
+	}
+	if u.Email == "" {
+		errs = append(errs, ErrEmailRequired)
+	} else if !IsEmail(u.Email) {
+		errs = append(errs, ErrInvalidEmail)
+	}
+	return errors.Join(errs...)
+}
+
+// NormalizeEmail returns the form emails are stored and looked up in, so
+// that an address is registered once whatever its case.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsEmail reports whether email is a bare RFC 5322 address, without a
+// display name or angle brackets.
+func IsEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Name == "" && address.Address == email
+}
+
+// EmailVerified reports whether the user proved to own the email.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// TOTPEnabled reports whether logins of the user require a TOTP code.
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// ValidateTOTP returns the time step of a TOTP code of the user, valid at
+// now and not used yet.
+func (u *User) ValidateTOTP(code string, now time.Time) (int64, error) {
+	if u.TOTPSecret == "" {
+		return 0, ErrTOTPNotEnrolled
 	}
-	return &User{
-		ID:       entity.NewID(),
-		Name:     name,
-		Email:    email,
-		Password: string(hash),
-	}, nil
+	return ValidateTOTP(u.TOTPSecret, code, now, u.TOTPLastStep)
 }
 
 func (u *User) ComparePassword(password string) error {
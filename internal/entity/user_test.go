@@ -2,23 +2,106 @@ package entity
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewUser(t *testing.T) {
-	user, err := NewUser("John Doe", "john@example.com", "123456")
+	user, err := NewUser("John Doe", "john@example.com", "s3cure-pass")
 	assert.Nil(t, err)
 	assert.NotNil(t, user)
 	assert.NotEmpty(t, user.ID)
 	assert.NotEmpty(t, user.Password)
 	assert.Equal(t, "John Doe", user.Name)
 	assert.Equal(t, "john@example.com", user.Email)
+	assert.Equal(t, RoleViewer, user.Role)
 }
 
 func TestUserComparePassword(t *testing.T) {
-	user, _ := NewUser("John Doe", "john@example.com", "123456")
-	assert.NotEqual(t, "123456", user.Password)
-	assert.Nil(t, user.ComparePassword("123456"))   // correct password
-	assert.NotNil(t, user.ComparePassword("12345")) // incorrect password
+	user, _ := NewUser("John Doe", "john@example.com", "s3cure-pass")
+	assert.NotEqual(t, "s3cure-pass", user.Password)
+	assert.Nil(t, user.ComparePassword("s3cure-pass"))   // correct password
+	assert.NotNil(t, user.ComparePassword("s3cure-pas")) // incorrect password
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("editor")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+	_, err = ParseRole("root")
+	assert.Equal(t, ErrInvalidRole, err)
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, RoleAdmin.Includes(RoleEditor))
+	assert.True(t, RoleEditor.Includes(RoleEditor))
+	assert.True(t, RoleEditor.Includes(RoleViewer))
+	assert.False(t, RoleViewer.Includes(RoleEditor))
+	assert.False(t, RoleEditor.Includes(RoleAdmin))
+	assert.False(t, Role("").Includes(RoleViewer))
+}
+
+func TestNewUser_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		userName string
+		email    string
+		password string
+		errs     []error
+	}{
+		{"valid", "John Doe", "john@example.com", "s3cure-pass", nil},
+		{"name required", "  ", "john@example.com", "s3cure-pass", []error{ErrNameRequired}},
+		{"email required", "John Doe", "", "s3cure-pass", []error{ErrEmailRequired}},
+		{"no domain", "John Doe", "john", "s3cure-pass", []error{ErrInvalidEmail}},
+		{"display name", "John Doe", "John <john@example.com>", "s3cure-pass", []error{ErrInvalidEmail}},
+		{"two at signs", "John Doe", "john@@example.com", "s3cure-pass", []error{ErrInvalidEmail}},
+		{"weak password", "John Doe", "john@example.com", "123", []error{ErrWeakPassword}},
+		{"everything", "", "john", "", []error{ErrNameRequired, ErrInvalidEmail, ErrWeakPassword}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := NewUser(tt.userName, tt.email, tt.password)
+			if tt.errs == nil {
+				assert.NoError(t, err)
+				assert.NotNil(t, user)
+				return
+			}
+			assert.Nil(t, user)
+			for _, want := range tt.errs {
+				assert.ErrorIs(t, err, want)
+			}
+		})
+	}
+}
+
+func TestNewUser_NormalizesEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "  John@Example.COM ", "s3cure-pass")
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", user.Email)
+}
+
+func TestUser_SetPassword(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "s3cure-pass")
+	assert.ErrorIs(t, user.SetPassword("short"), ErrWeakPassword)
+	assert.NoError(t, user.ComparePassword("s3cure-pass"))
+
+	assert.NoError(t, user.SetPassword("an0ther-pass"))
+	assert.NoError(t, user.ComparePassword("an0ther-pass"))
+	assert.Error(t, user.ComparePassword("s3cure-pass"))
+}
+
+func TestUser_ValidateTOTP(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "s3cure-pass")
+	now := time.Unix(1111111111, 0)
+	_, err := user.ValidateTOTP("050471", now)
+	assert.ErrorIs(t, err, ErrTOTPNotEnrolled)
+	assert.False(t, user.TOTPEnabled())
+
+	user.TOTPSecret = rfc6238Secret
+	step, err := user.ValidateTOTP("050471", now)
+	assert.NoError(t, err)
+	user.TOTPLastStep = step
+	_, err = user.ValidateTOTP("050471", now)
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
 }
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("Invalid Verification Token")
+	ErrEmailNotVerified         = errors.New("Email Not Verified")
+)
+
+const verificationPurpose = "email-verification"
+
+// NewVerificationToken returns a token proving that whoever holds it reads
+// the mails sent to the email of the user. It names the email so that it is
+// void once the user changes it.
+func NewVerificationToken(u *User, secret []byte, ttl time.Duration) string {
+	return newSignedToken(verificationPurpose, []string{u.ID.String(), u.Email}, secret, ttl)
+}
+
+// ParseVerificationToken returns the ID of the user and the email a token
+// of NewVerificationToken was issued for, if it is genuine and unexpired.
+func ParseVerificationToken(token string, secret []byte) (entity.ID, string, error) {
+	fields, err := parseSignedToken(verificationPurpose, token, secret, 2)
+	if err != nil {
+		return entity.ID{}, "", ErrInvalidVerificationToken
+	}
+	id, err := entity.ParseID(fields[0])
+	if err != nil {
+		return entity.ID{}, "", ErrInvalidVerificationToken
+	}
+	return id, fields[1], nil
+}
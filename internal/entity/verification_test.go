@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationToken(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "s3cure-pass")
+	secret := []byte("secret")
+	token := NewVerificationToken(user, secret, time.Hour)
+
+	id, email, err := ParseVerificationToken(token, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, id)
+	assert.Equal(t, "j@j.com", email)
+
+	_, _, err = ParseVerificationToken(token, []byte("other secret"))
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := NewVerificationToken(&User{ID: user.ID, Email: "eve@j.com"}, []byte("eve"), time.Hour)
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	_, _, err = ParseVerificationToken(forgedPayload+"."+signature, secret)
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	_, _, err = ParseVerificationToken(payload, secret)
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+
+	expired := NewVerificationToken(user, secret, -time.Minute)
+	_, _, err = ParseVerificationToken(expired, secret)
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+}
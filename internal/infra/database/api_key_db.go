@@ -0,0 +1,63 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type APIKey struct {
+	DB *gorm.DB
+}
+
+func NewAPIKey(db *gorm.DB) *APIKey {
+	return &APIKey{DB: db}
+}
+
+func (a *APIKey) Create(key *entity.APIKey) error {
+	return translate(a.DB, a.DB.Create(key).Error)
+}
+
+func (a *APIKey) FindByHash(keyHash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	if err := a.DB.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, translate(a.DB, err)
+	}
+	return &key, nil
+}
+
+// FindByUser returns the keys of the user that are not revoked, the latest
+// first.
+func (a *APIKey) FindByUser(userID string) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	err := a.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at desc").Find(&keys).Error
+	if err != nil {
+		return nil, translate(a.DB, err)
+	}
+	return keys, nil
+}
+
+// Revoke revokes the key of the user with the ID. Keys of other users are
+// not found.
+func (a *APIKey) Revoke(userID, id string) error {
+	result := a.DB.Model(&entity.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return translate(a.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return translate(a.DB, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Touch records that the key is used now, unless it was already used after
+// notBefore, so that a busy key is not written on every request.
+func (a *APIKey) Touch(id string, notBefore time.Time) error {
+	err := a.DB.Model(&entity.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, notBefore).
+		Update("last_used_at", time.Now()).Error
+	return translate(a.DB, err)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newAPIKey(t *testing.T) *APIKey {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.APIKey{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return NewAPIKey(db)
+}
+
+func TestAPIKey_CreateAndFind(t *testing.T) {
+	apiKeyDB := newAPIKey(t)
+	userID := pkgEntity.NewID()
+	key, plain, _ := entity.NewAPIKey(userID, "Batch", []entity.Scope{entity.ScopeProductsRead}, nil)
+	assert.NoError(t, apiKeyDB.Create(key))
+
+	found, err := apiKeyDB.FindByHash(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, key.Prefix, found.Prefix)
+	_, err = apiKeyDB.FindByHash(entity.HashToken("pk_unknown"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	other, _, _ := entity.NewAPIKey(pkgEntity.NewID(), "Other", []entity.Scope{entity.ScopeProductsRead}, nil)
+	assert.NoError(t, apiKeyDB.Create(other))
+	keys, err := apiKeyDB.FindByUser(userID.String())
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, key.ID, keys[0].ID)
+	}
+}
+
+func TestAPIKey_Revoke(t *testing.T) {
+	apiKeyDB := newAPIKey(t)
+	userID := pkgEntity.NewID()
+	key, plain, _ := entity.NewAPIKey(userID, "Batch", []entity.Scope{entity.ScopeProductsRead}, nil)
+	assert.NoError(t, apiKeyDB.Create(key))
+
+	assert.ErrorIs(t, apiKeyDB.Revoke(pkgEntity.NewID().String(), key.ID.String()), ErrNotFound, "only the user revokes their keys")
+	assert.NoError(t, apiKeyDB.Revoke(userID.String(), key.ID.String()))
+	assert.ErrorIs(t, apiKeyDB.Revoke(userID.String(), key.ID.String()), ErrNotFound)
+
+	found, err := apiKeyDB.FindByHash(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, found.Validate(), entity.ErrInvalidAPIKey)
+	keys, err := apiKeyDB.FindByUser(userID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestAPIKey_Touch(t *testing.T) {
+	apiKeyDB := newAPIKey(t)
+	key, plain, _ := entity.NewAPIKey(pkgEntity.NewID(), "Batch", []entity.Scope{entity.ScopeProductsRead}, nil)
+	assert.NoError(t, apiKeyDB.Create(key))
+
+	assert.NoError(t, apiKeyDB.Touch(key.ID.String(), time.Now().Add(-time.Minute)))
+	found, _ := apiKeyDB.FindByHash(entity.HashToken(plain))
+	if assert.NotNil(t, found.LastUsedAt) {
+		lastUsed := *found.LastUsedAt
+		assert.NoError(t, apiKeyDB.Touch(key.ID.String(), time.Now().Add(-time.Minute)))
+		found, _ = apiKeyDB.FindByHash(entity.HashToken(plain))
+		assert.True(t, lastUsed.Equal(*found.LastUsedAt), "recent uses are not written again")
+	}
+}
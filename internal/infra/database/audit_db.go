@@ -0,0 +1,27 @@
+package database
+
+import (
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type Audit struct {
+	DB *gorm.DB
+}
+
+func NewAudit(db *gorm.DB) *Audit {
+	return &Audit{DB: db}
+}
+
+func (a *Audit) Record(entry *entity.AuditEntry) error {
+	return translate(a.DB, a.DB.Create(entry).Error)
+}
+
+// FindBySubject returns the entries about the subject, the latest first.
+func (a *Audit) FindBySubject(subject string) ([]entity.AuditEntry, error) {
+	var entries []entity.AuditEntry
+	if err := a.DB.Where("subject = ?", subject).Order("created_at desc").Find(&entries).Error; err != nil {
+		return nil, translate(a.DB, err)
+	}
+	return entries, nil
+}
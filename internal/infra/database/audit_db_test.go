@@ -0,0 +1,34 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAudit_Record(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.AuditEntry{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	audit := NewAudit(db)
+	first := entity.NewAuditEntry(entity.AuditLoginLocked, "j@j.com", "127.0.0.1", "locked for 1m0s")
+	assert.NoError(t, audit.Record(first))
+	second := entity.NewAuditEntry(entity.AuditLoginLocked, "j@j.com", "127.0.0.2", "locked for 2m0s")
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	assert.NoError(t, audit.Record(second))
+	assert.NoError(t, audit.Record(entity.NewAuditEntry(entity.AuditLoginLocked, "127.0.0.1", "127.0.0.1", "")))
+
+	entries, err := audit.FindBySubject("j@j.com")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, second.ID, entries[0].ID)
+	assert.Equal(t, "127.0.0.2", entries[0].IP)
+}
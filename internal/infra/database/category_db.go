@@ -0,0 +1,163 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+	ErrCategoryCycle       = errors.New("category cannot be its own ancestor")
+)
+
+type Category struct {
+	DB *gorm.DB
+	// TenantID confines the repository to the categories of one
+	// organization, see TenantScope.
+	TenantID string
+}
+
+// NewCategory returns the repository of the categories of the
+// organization.
+func NewCategory(db *gorm.DB, tenantID string) *Category {
+	return &Category{DB: db, TenantID: tenantID}
+}
+
+// NewCategoryUnscoped returns a repository of the categories of every
+// organization, for the handlers to confine to the organization of each
+// request with ForTenant.
+func NewCategoryUnscoped(db *gorm.DB) *Category {
+	return &Category{DB: db, TenantID: allTenants}
+}
+
+// ForTenant returns a copy of the repository confined to the organization.
+func (c *Category) ForTenant(tenantID string) CategoryInterface {
+	return &Category{DB: c.DB, TenantID: tenantID}
+}
+
+func (c *Category) scoped() *gorm.DB {
+	return c.DB.Scopes(TenantScope(c.TenantID))
+}
+
+func (c *Category) withDB(db *gorm.DB) *Category {
+	return &Category{DB: db, TenantID: c.TenantID}
+}
+
+// Create stores the category, in the organization of the repository if it
+// is confined to one.
+func (c *Category) Create(category *entity.Category) error {
+	organizationID, err := organizationOf(c.TenantID)
+	if err != nil {
+		return err
+	}
+	if organizationID != nil {
+		category.OrganizationID = *organizationID
+	}
+	return translate(c.DB, c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := c.withDB(tx).checkParent(category); err != nil {
+			return err
+		}
+		return tx.Create(category).Error
+	}))
+}
+
+func (c *Category) FindAll() ([]entity.Category, error) {
+	var categories []entity.Category
+	err := c.scoped().Order("name").Find(&categories).Error
+	return categories, translate(c.DB, err)
+}
+
+func (c *Category) FindByID(id string) (*entity.Category, error) {
+	var category entity.Category
+	if err := c.scoped().Where("id = ?", id).First(&category).Error; err != nil {
+		return nil, translate(c.DB, err)
+	}
+	return &category, nil
+}
+
+// Update saves the name and parent of an existing category, refusing to
+// move it below one of its own descendants.
+func (c *Category) Update(category *entity.Category) error {
+	return translate(c.DB, c.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := c.withDB(tx).FindByID(category.ID.String()); err != nil {
+			return err
+		}
+		if err := c.withDB(tx).checkParent(category); err != nil {
+			return err
+		}
+		return tx.Model(category).Select("name", "parent_id").Updates(category).Error
+	}))
+}
+
+func (c *Category) Delete(id string) error {
+	return translate(c.DB, c.DB.Transaction(func(tx *gorm.DB) error {
+		category, err := c.withDB(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+		var children int64
+		if err := tx.Model(&entity.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrCategoryHasChildren
+		}
+		if err := tx.Table("product_categories").Where("category_id = ?", id).Delete(nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	}))
+}
+
+// FindDescendantIDs returns the ID of the category and of every category
+// below it in the tree.
+func (c *Category) FindDescendantIDs(id string) ([]string, error) {
+	if _, err := c.FindByID(id); err != nil {
+		return nil, err
+	}
+	ids, err := descendantIDs(c.DB, id)
+	return ids, translate(c.DB, err)
+}
+
+func descendantIDs(db *gorm.DB, id string) ([]string, error) {
+	ids := []string{id}
+	level := []string{id}
+	for len(level) > 0 {
+		var children []string
+		if err := db.Model(&entity.Category{}).Where("parent_id IN ?", level).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, children...)
+		level = children
+	}
+	return ids, nil
+}
+
+// checkParent refuses a parent that is not a category of the organization
+// of c or that is below the category itself.
+func (c *Category) checkParent(category *entity.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	parentID := category.ParentID.String()
+	var count int64
+	if err := c.scoped().Model(&entity.Category{}).Where("id = ?", parentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCategoryNotFound
+	}
+	descendants, err := descendantIDs(c.DB, category.ID.String())
+	if err != nil {
+		return err
+	}
+	for _, id := range descendants {
+		if id == parentID {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
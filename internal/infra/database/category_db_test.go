@@ -0,0 +1,103 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCategoryDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Category{}, &entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return db
+}
+
+func TestCategory_Create(t *testing.T) {
+	db := newCategoryDB(t)
+	categoryDB := NewCategory(db, defaultTenant)
+	parent, _ := entity.NewCategory("Electronics", nil)
+	assert.NoError(t, categoryDB.Create(parent))
+	child, _ := entity.NewCategory("Phones", &parent.ID)
+	assert.NoError(t, categoryDB.Create(child))
+
+	found, err := categoryDB.FindByID(child.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, child.Name, found.Name)
+	assert.Equal(t, parent.ID, *found.ParentID)
+
+	missingID := pkgEntity.NewID()
+	orphan, _ := entity.NewCategory("Orphan", &missingID)
+	assert.ErrorIs(t, categoryDB.Create(orphan), ErrCategoryNotFound)
+}
+
+func TestCategory_FindDescendantIDs(t *testing.T) {
+	db := newCategoryDB(t)
+	categoryDB := NewCategory(db, defaultTenant)
+	root, _ := entity.NewCategory("Electronics", nil)
+	child, _ := entity.NewCategory("Phones", &root.ID)
+	grandchild, _ := entity.NewCategory("Smartphones", &child.ID)
+	other, _ := entity.NewCategory("Books", nil)
+	for _, c := range []*entity.Category{root, child, grandchild, other} {
+		assert.NoError(t, categoryDB.Create(c))
+	}
+
+	ids, err := categoryDB.FindDescendantIDs(root.ID.String())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{root.ID.String(), child.ID.String(), grandchild.ID.String()}, ids)
+
+	ids, err = categoryDB.FindDescendantIDs(grandchild.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{grandchild.ID.String()}, ids)
+}
+
+func TestCategory_Update(t *testing.T) {
+	db := newCategoryDB(t)
+	categoryDB := NewCategory(db, defaultTenant)
+	root, _ := entity.NewCategory("Electronics", nil)
+	child, _ := entity.NewCategory("Phones", &root.ID)
+	assert.NoError(t, categoryDB.Create(root))
+	assert.NoError(t, categoryDB.Create(child))
+
+	child.Name = "Mobile Phones"
+	child.ParentID = nil
+	assert.NoError(t, categoryDB.Update(child))
+	found, err := categoryDB.FindByID(child.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Mobile Phones", found.Name)
+	assert.Nil(t, found.ParentID)
+
+	// Moving a category below its own descendant would create a cycle.
+	child.ParentID = &root.ID
+	assert.NoError(t, categoryDB.Update(child))
+	root.ParentID = &child.ID
+	assert.ErrorIs(t, categoryDB.Update(root), ErrCategoryCycle)
+}
+
+func TestCategory_Delete(t *testing.T) {
+	db := newCategoryDB(t)
+	categoryDB := NewCategory(db, defaultTenant)
+	root, _ := entity.NewCategory("Electronics", nil)
+	child, _ := entity.NewCategory("Phones", &root.ID)
+	assert.NoError(t, categoryDB.Create(root))
+	assert.NoError(t, categoryDB.Create(child))
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.Categories = []entity.Category{*child}
+	assert.NoError(t, NewProduct(db, defaultTenant).Create(product))
+
+	assert.ErrorIs(t, categoryDB.Delete(root.ID.String()), ErrCategoryHasChildren)
+	assert.NoError(t, categoryDB.Delete(child.ID.String()))
+	assert.NoError(t, categoryDB.Delete(root.ID.String()))
+
+	productFound, err := NewProduct(db, defaultTenant).FindByID(product.ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, productFound.Categories)
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+type ConnectionConfig struct {
+	Driver string
+	Host   string
+	Port   string
+	User   string
+	Pass   string
+	Name   string
+	// SSLMode is the sslmode of postgres connections, disable if empty.
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func NewConnection(cfg ConnectionConfig) (*gorm.DB, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == DriverMemory {
+		// Every new connection to an in-memory SQLite database is a fresh,
+		// empty database, so the pool must never hand out a second one nor
+		// close the first: the database goes with it.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+		return db, nil
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	return db, nil
+}
+
+func Dialector(cfg ConnectionConfig) (gorm.Dialector, error) {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Driver {
+	case DriverSQLite, DriverMemory:
+		return sqlite.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
+
+func DSN(cfg ConnectionConfig) (string, error) {
+	switch cfg.Driver {
+	case DriverSQLite:
+		if cfg.Name == "" {
+			return "", errors.New("DB_NAME is required for the sqlite driver")
+		}
+		return cfg.Name, nil
+	case DriverMemory:
+		return "file::memory:", nil
+	case DriverPostgres:
+		sslMode := cfg.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		// The URL form escapes credentials and names holding spaces, quotes
+		// or any other character of the key=value form.
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Pass),
+			Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+			Path:     "/" + cfg.Name,
+			RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+		}
+		return dsn.String(), nil
+	case DriverMySQL:
+		// FormatDSN escapes credentials and names holding @, / or ?, which
+		// would otherwise end the part of the DSN they are in.
+		dsn := mysqlDriver.NewConfig()
+		dsn.User = cfg.User
+		dsn.Passwd = cfg.Pass
+		dsn.Net = "tcp"
+		dsn.Addr = net.JoinHostPort(cfg.Host, cfg.Port)
+		dsn.DBName = cfg.Name
+		dsn.ParseTime = true
+		dsn.Loc = time.Local
+		dsn.Params = map[string]string{"charset": "utf8mb4"}
+		return dsn.FormatDSN(), nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTranslate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"record not found", gorm.ErrRecordNotFound, ErrNotFound},
+		{"duplicated key", gorm.ErrDuplicatedKey, ErrConflict},
+		{"foreign key", gorm.ErrForeignKeyViolated, ErrConflict},
+		{"network", refused, ErrUnavailable},
+		{"bad connection", driver.ErrBadConn, ErrUnavailable},
+		{"domain error", ErrVersionMismatch, ErrVersionMismatch},
+		{"already translated", translate(db, gorm.ErrRecordNotFound), ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translate(db, tt.err)
+			assert.ErrorIs(t, got, tt.want)
+			assert.ErrorIs(t, got, tt.err, "the original error must stay in the chain")
+		})
+	}
+	assert.NoError(t, translate(db, nil))
+	assert.Equal(t, ErrVersionMismatch, translate(db, ErrVersionMismatch))
+}
+
+func TestRepositories_ReturnTypedErrors(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}, &entity.Product{}, &entity.Category{}, &entity.Organization{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	productDB := NewProduct(db, defaultTenant)
+	userDB := NewUser(db, defaultTenant)
+	missing := pkgEntity.NewID().String()
+
+	_, err = productDB.FindByID(missing)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, productDB.Update(missing, 0, map[string]interface{}{"name": "x"}), ErrNotFound)
+	assert.ErrorIs(t, productDB.Delete(missing, 0), ErrNotFound)
+	_, err = productDB.Restore(missing)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = NewCategory(db, defaultTenant).FindByID(missing)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = NewOrganization(db).FindByID(missing)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, userDB.UpdateRole(missing, entity.RoleAdmin), ErrNotFound)
+
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	assert.NoError(t, userDB.Create(user))
+	assert.ErrorIs(t, userDB.Create(user), ErrConflict)
+}
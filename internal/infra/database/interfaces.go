@@ -1,16 +1,105 @@
 package database
 
-import "github.com/ThalesLoreto/product-api/internal/entity"
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+)
 
 type UserInterface interface {
+	ForTenant(tenantID string) UserInterface
 	Create(user *entity.User) error
 	FindByEmail(email string) (*entity.User, error)
+	FindByID(id string) (*entity.User, error)
+	UpdateRole(id string, role entity.Role) error
+	Update(user *entity.User) error
+	UpdatePassword(id, passwordHash string) error
+	VerifyEmail(id string) error
+	MarkVerificationSent(id string, notBefore time.Time) (bool, error)
+}
+
+type OrganizationInterface interface {
+	Create(organization *entity.Organization, admin *entity.User) error
+	FindByID(id string) (*entity.Organization, error)
+}
+
+type TokenInterface interface {
+	CreateRefreshToken(token *entity.RefreshToken) error
+	FindRefreshToken(tokenHash string) (*entity.RefreshToken, error)
+	Rotate(current, next *entity.RefreshToken) error
+	RevokeFamily(familyID string) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	RevokeUser(userID, keepFamilyID string) error
+	CreatePasswordResetToken(token *entity.PasswordResetToken) error
+	FindPasswordResetToken(tokenHash string) (*entity.PasswordResetToken, error)
+	ResetPassword(token *entity.PasswordResetToken, passwordHash string) error
+	Purge(expiredBefore time.Time) (int64, error)
+}
+
+type MFAInterface interface {
+	EnrollTOTP(userID, secret string) error
+	EnableTOTP(userID, secret string, step int64, codes []entity.RecoveryCode) error
+	UseTOTPStep(userID string, step int64) error
+	UseRecoveryCode(userID, codeHash string) error
+}
+
+type APIKeyInterface interface {
+	Create(key *entity.APIKey) error
+	FindByHash(keyHash string) (*entity.APIKey, error)
+	FindByUser(userID string) ([]entity.APIKey, error)
+	Revoke(userID, id string) error
+	Touch(id string, notBefore time.Time) error
+}
+
+type LoginThrottleInterface interface {
+	Find(subject string) (*entity.LoginFailures, error)
+	Fail(subject string, policy entity.LockoutPolicy) (*entity.LoginFailures, error)
+	Attempt(policies map[string]entity.LockoutPolicy) (time.Duration, error)
+	Reset(subject string) error
+	Purge(before time.Time) (int64, error)
+}
+
+type AuditInterface interface {
+	Record(entry *entity.AuditEntry) error
+	FindBySubject(subject string) ([]entity.AuditEntry, error)
 }
 
 type ProductInterface interface {
+	ForTenant(tenantID string) ProductInterface
 	Create(product *entity.Product) error
 	FindAll(page, limit int, sort string) ([]entity.Product, error)
+	FindAllByCategory(categoryID string, includeDescendants bool, page, limit int, sort string) ([]entity.Product, error)
+	FindByQuery(query ProductQuery) ([]entity.Product, error)
+	FindPage(query ProductQuery) (*ProductPage, error)
 	FindByID(id string) (*entity.Product, error)
-	Update(id string, fields interface{}) error
+	FindByIDUnscoped(id string) (*entity.Product, error)
+	Update(id string, version int, fields interface{}) error
+	SetCategories(id string, categoryIDs []string) error
+	Replace(id string, version int, fields interface{}, categoryIDs []string) error
+	Delete(id string, version int) error
+	Restore(id string) (*entity.Product, error)
+	Purge(deletedBefore time.Time) (int64, error)
+}
+
+type CategoryInterface interface {
+	ForTenant(tenantID string) CategoryInterface
+	Create(category *entity.Category) error
+	FindAll() ([]entity.Category, error)
+	FindByID(id string) (*entity.Category, error)
+	FindDescendantIDs(id string) ([]string, error)
+	Update(category *entity.Category) error
 	Delete(id string) error
 }
+
+type StockInterface interface {
+	ForTenant(tenantID string) StockInterface
+	Record(movement *entity.StockMovement) (*entity.StockLevel, error)
+	FindLevel(productID string) (*entity.StockLevel, error)
+	FindMovements(productID string, page, limit int) ([]entity.StockMovement, error)
+}
+
+type ProductSearchInterface interface {
+	ForTenant(tenantID string) ProductSearchInterface
+	Search(query string, page, limit int) ([]entity.ProductSearchResult, error)
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottle struct {
+	DB *gorm.DB
+}
+
+func NewLoginThrottle(db *gorm.DB) *LoginThrottle {
+	return &LoginThrottle{DB: db}
+}
+
+// Find returns the failed logins in a row of the subject, none if it has
+// not failed to log in.
+func (l *LoginThrottle) Find(subject string) (*entity.LoginFailures, error) {
+	failures := entity.LoginFailures{Subject: subject}
+	if err := l.DB.Where("subject = ?", subject).Limit(1).Find(&failures).Error; err != nil {
+		return nil, translate(l.DB, err)
+	}
+	return &failures, nil
+}
+
+// Fail counts a failed login of the subject, starting over if the last one
+// is older than the window of the policy, and locks the subject out as the
+// policy says.
+func (l *LoginThrottle) Fail(subject string, policy entity.LockoutPolicy) (*entity.LoginFailures, error) {
+	var failures entity.LoginFailures
+	err := l.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		initial := entity.LoginFailures{Subject: subject, LastFailureAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&initial).Error; err != nil {
+			return err
+		}
+		// The count is incremented by the UPDATE itself so that concurrent
+		// failures are all counted.
+		err := tx.Model(&entity.LoginFailures{}).Where("subject = ?", subject).Updates(map[string]interface{}{
+			"failures":        gorm.Expr("CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END", now.Add(-policy.Window)),
+			"last_failure_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("subject = ?", subject).First(&failures).Error; err != nil {
+			return err
+		}
+		lockout := policy.LockoutFor(failures.Failures)
+		if lockout == 0 {
+			return nil
+		}
+		lockedUntil := now.Add(lockout)
+		failures.LockedUntil = &lockedUntil
+		return tx.Model(&entity.LoginFailures{}).Where("subject = ?", subject).Update("locked_until", lockedUntil).Error
+	})
+	if err != nil {
+		return nil, translate(l.DB, err)
+	}
+	return &failures, nil
+}
+
+// errThrottled rolls back the attempts of Attempt once a subject is found
+// locked out.
+var errThrottled = errors.New("subject is locked out")
+
+// Attempt counts an attempt of every subject, under its policy, and locks
+// them out as the policies say, returning zero. If any subject is locked
+// out, none of them is counted and Attempt returns how long that one
+// remains locked out. Each subject is checked and counted by a single
+// UPDATE, which concurrent attempts wait on, so that they cannot all pass
+// the check before any of them is counted.
+func (l *LoginThrottle) Attempt(policies map[string]entity.LockoutPolicy) (time.Duration, error) {
+	subjects := make([]string, 0, len(policies))
+	for subject := range policies {
+		subjects = append(subjects, subject)
+	}
+	// A single order keeps concurrent attempts from deadlocking.
+	sort.Strings(subjects)
+	var retryAfter time.Duration
+	err := l.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, subject := range subjects {
+			policy := policies[subject]
+			initial := entity.LoginFailures{Subject: subject, LastFailureAt: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&initial).Error; err != nil {
+				return err
+			}
+			result := tx.Model(&entity.LoginFailures{}).
+				Where("subject = ? AND (locked_until IS NULL OR locked_until <= ?)", subject, now).
+				Updates(map[string]interface{}{
+					"failures":        gorm.Expr("CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END", now.Add(-policy.Window)),
+					"last_failure_at": now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			var attempts entity.LoginFailures
+			if err := tx.Where("subject = ?", subject).First(&attempts).Error; err != nil {
+				return err
+			}
+			if result.RowsAffected == 0 {
+				retryAfter = attempts.RetryAfter(now)
+				return errThrottled
+			}
+			lockout := policy.LockoutFor(attempts.Failures)
+			if lockout == 0 {
+				continue
+			}
+			if err := tx.Model(&entity.LoginFailures{}).Where("subject = ?", subject).Update("locked_until", now.Add(lockout)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errThrottled) {
+		return retryAfter, nil
+	}
+	return 0, translate(l.DB, err)
+}
+
+// Reset forgets the failed logins of the subject.
+func (l *LoginThrottle) Reset(subject string) error {
+	return translate(l.DB, l.DB.Where("subject = ?", subject).Delete(&entity.LoginFailures{}).Error)
+}
+
+// Purge removes the failed logins of the subjects that neither failed nor
+// were locked out since the given time.
+func (l *LoginThrottle) Purge(before time.Time) (int64, error) {
+	result := l.DB.
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&entity.LoginFailures{})
+	return result.RowsAffected, translate(l.DB, result.Error)
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newLoginThrottle(t *testing.T) *LoginThrottle {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.LoginFailures{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return NewLoginThrottle(db)
+}
+
+func TestLoginThrottle_Fail(t *testing.T) {
+	throttle := newLoginThrottle(t)
+	policy := entity.LockoutPolicy{MaxFailures: 2, Lockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+
+	failures, err := throttle.Find("j@j.com")
+	assert.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+
+	failures, err = throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures)
+	assert.Zero(t, failures.RetryAfter(time.Now()))
+
+	failures, err = throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failures.Failures)
+	assert.InDelta(t, time.Minute, failures.RetryAfter(time.Now()), float64(time.Second))
+	failures, err = throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2*time.Minute, failures.RetryAfter(time.Now()), float64(time.Second))
+	failures, err = throttle.Find("j@j.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, failures.Failures)
+	assert.NotZero(t, failures.RetryAfter(time.Now()))
+
+	other, err := throttle.Find("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Zero(t, other.Failures)
+
+	assert.NoError(t, throttle.Reset("j@j.com"))
+	failures, err = throttle.Find("j@j.com")
+	assert.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+}
+
+func TestLoginThrottle_FailForgetsOldFailures(t *testing.T) {
+	throttle := newLoginThrottle(t)
+	policy := entity.LockoutPolicy{MaxFailures: 5, Lockout: time.Minute, Window: time.Hour}
+	_, err := throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	_, err = throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	err = throttle.DB.Model(&entity.LoginFailures{}).Where("subject = ?", "j@j.com").
+		Update("last_failure_at", time.Now().Add(-2*time.Hour)).Error
+	assert.NoError(t, err)
+
+	failures, err := throttle.Fail("j@j.com", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures)
+}
+
+func TestLoginThrottle_Attempt(t *testing.T) {
+	throttle := newLoginThrottle(t)
+	policies := map[string]entity.LockoutPolicy{
+		"j@j.com":   {MaxFailures: 2, Lockout: time.Minute, Window: time.Hour},
+		"127.0.0.1": {MaxFailures: 3, Lockout: time.Hour, Window: time.Hour},
+	}
+
+	for i := 0; i < 2; i++ {
+		retryAfter, err := throttle.Attempt(policies)
+		assert.NoError(t, err)
+		assert.Zero(t, retryAfter)
+	}
+	retryAfter, err := throttle.Attempt(policies)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, retryAfter, float64(time.Second))
+	client, err := throttle.Find("127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, client.Failures, "attempts held off are not counted")
+}
+
+func TestLoginThrottle_AttemptIsAtomic(t *testing.T) {
+	throttle := newLoginThrottle(t)
+	// The in-memory database lives in a single connection.
+	sqlDB, _ := throttle.DB.DB()
+	sqlDB.SetMaxOpenConns(1)
+	policies := map[string]entity.LockoutPolicy{"j@j.com": {MaxFailures: 3, Lockout: time.Minute, Window: time.Hour}}
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retryAfter, err := throttle.Attempt(policies)
+			assert.NoError(t, err)
+			if retryAfter == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), allowed.Load())
+}
+
+func TestLoginThrottle_Purge(t *testing.T) {
+	throttle := newLoginThrottle(t)
+	locked := entity.LockoutPolicy{MaxFailures: 1, Lockout: time.Hour}
+	_, err := throttle.Fail("old", entity.LockoutPolicy{})
+	assert.NoError(t, err)
+	_, err = throttle.Fail("locked", locked)
+	assert.NoError(t, err)
+	err = throttle.DB.Model(&entity.LoginFailures{}).Where("1 = 1").
+		Update("last_failure_at", time.Now().Add(-2*time.Hour)).Error
+	assert.NoError(t, err)
+	_, err = throttle.Fail("recent", entity.LockoutPolicy{})
+	assert.NoError(t, err)
+
+	purged, err := throttle.Purge(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	failures, err := throttle.Find("locked")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures, "locked subjects are kept until the lockout ends")
+}
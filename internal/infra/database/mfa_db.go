@@ -0,0 +1,104 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// MFA stores the second factors of the users: their TOTP secret and
+// recovery codes.
+type MFA struct {
+	DB *gorm.DB
+}
+
+func NewMFA(db *gorm.DB) *MFA {
+	return &MFA{DB: db}
+}
+
+// EnrollTOTP sets the TOTP secret of the user, to be enabled once the user
+// proves to have enrolled it. It fails with entity.ErrTOTPEnabled if TOTP
+// is enabled already, lest whoever holds an access token of the user
+// replace the secret.
+func (m *MFA) EnrollTOTP(userID, secret string) error {
+	return translate(m.DB, m.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.User{}).
+			Where("id = ? AND totp_enabled_at IS NULL", userID).
+			Update("totp_secret", secret)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		var users int64
+		if err := tx.Model(&entity.User{}).Where("id = ?", userID).Count(&users).Error; err != nil {
+			return err
+		}
+		if users == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return entity.ErrTOTPEnabled
+	}))
+}
+
+// EnableTOTP enables the TOTP secret the user enrolled, whose code of the
+// time step was just used, and replaces the recovery codes of the user. It
+// fails with entity.ErrTOTPNotEnrolled if the secret is no longer the one
+// enrolled or is already enabled.
+func (m *MFA) EnableTOTP(userID, secret string, step int64, codes []entity.RecoveryCode) error {
+	return translate(m.DB, m.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.User{}).
+			Where("id = ? AND totp_secret = ? AND totp_enabled_at IS NULL", userID, secret).
+			Updates(map[string]interface{}{
+				"totp_enabled_at": time.Now(),
+				"totp_last_step":  step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrTOTPNotEnrolled
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	}))
+}
+
+// UseTOTPStep records that the user logged in with the code of the time
+// step. Only one of several uses of codes of the same or earlier steps
+// succeeds, the others fail with entity.ErrInvalidMFACode, so that a code
+// seen by someone else cannot be used again.
+func (m *MFA) UseTOTPStep(userID string, step int64) error {
+	result := m.DB.Model(&entity.User{}).
+		Where("id = ? AND totp_enabled_at IS NOT NULL AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return translate(m.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseRecoveryCode uses up the recovery code of the user with the hash. It
+// fails with entity.ErrInvalidMFACode if the user has no such code left.
+func (m *MFA) UseRecoveryCode(userID, codeHash string) error {
+	result := m.DB.Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return translate(m.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidMFACode
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newMFA(t *testing.T) (*MFA, *entity.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}, &entity.RecoveryCode{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	if err := NewUser(db, defaultTenant).Create(user); err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	return NewMFA(db), user
+}
+
+func TestMFA_EnrollAndEnableTOTP(t *testing.T) {
+	mfa, user := newMFA(t)
+	id := user.ID.String()
+	codes, _, _ := entity.NewRecoveryCodes(user.ID, 2)
+
+	assert.NoError(t, mfa.EnrollTOTP(id, "FIRST"))
+	assert.NoError(t, mfa.EnrollTOTP(id, "SECOND"))
+	// Only the secret enrolled last can be enabled.
+	assert.ErrorIs(t, mfa.EnableTOTP(id, "FIRST", 10, codes), entity.ErrTOTPNotEnrolled)
+	assert.NoError(t, mfa.EnableTOTP(id, "SECOND", 10, codes))
+
+	found, err := NewUser(mfa.DB, defaultTenant).FindByID(id)
+	assert.NoError(t, err)
+	assert.True(t, found.TOTPEnabled())
+	assert.Equal(t, "SECOND", found.TOTPSecret)
+	assert.Equal(t, int64(10), found.TOTPLastStep)
+
+	assert.ErrorIs(t, mfa.EnrollTOTP(id, "THIRD"), entity.ErrTOTPEnabled)
+	assert.ErrorIs(t, mfa.EnableTOTP(id, "SECOND", 11, codes), entity.ErrTOTPNotEnrolled)
+	assert.ErrorIs(t, mfa.EnrollTOTP(pkgEntity.NewID().String(), "FIRST"), ErrNotFound)
+}
+
+func TestMFA_UseTOTPStep(t *testing.T) {
+	mfa, user := newMFA(t)
+	id := user.ID.String()
+	assert.ErrorIs(t, mfa.UseTOTPStep(id, 11), entity.ErrInvalidMFACode)
+
+	assert.NoError(t, mfa.EnrollTOTP(id, "SECRET"))
+	assert.NoError(t, mfa.EnableTOTP(id, "SECRET", 10, nil))
+	assert.ErrorIs(t, mfa.UseTOTPStep(id, 10), entity.ErrInvalidMFACode)
+	assert.NoError(t, mfa.UseTOTPStep(id, 11))
+	assert.ErrorIs(t, mfa.UseTOTPStep(id, 11), entity.ErrInvalidMFACode)
+	assert.ErrorIs(t, mfa.UseTOTPStep(id, 9), entity.ErrInvalidMFACode)
+}
+
+func TestMFA_UseRecoveryCode(t *testing.T) {
+	mfa, user := newMFA(t)
+	id := user.ID.String()
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID, 2)
+	assert.NoError(t, mfa.EnrollTOTP(id, "SECRET"))
+	assert.NoError(t, mfa.EnableTOTP(id, "SECRET", 10, codes))
+
+	assert.NoError(t, mfa.UseRecoveryCode(id, entity.HashRecoveryCode(plain[0])))
+	assert.ErrorIs(t, mfa.UseRecoveryCode(id, entity.HashRecoveryCode(plain[0])), entity.ErrInvalidMFACode)
+	assert.ErrorIs(t, mfa.UseRecoveryCode(pkgEntity.NewID().String(), entity.HashRecoveryCode(plain[1])), entity.ErrInvalidMFACode)
+	assert.NoError(t, mfa.UseRecoveryCode(id, entity.HashRecoveryCode(plain[1])))
+}
@@ -0,0 +1,443 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrMissingDown      = errors.New("migration has no down script")
+	ErrUnversioned      = errors.New("database has tables but no migrations applied, run `migrate baseline` to adopt it")
+	ErrNoLegacySchema   = errors.New("database has no tables to adopt")
+	ErrVersioned        = errors.New("database already has migrations applied")
+	// ErrNoFTS5 is returned along with the error of the search index
+	// migration on SQLite builds without the FTS5 module.
+	ErrNoFTS5 = errors.New("build with -tags sqlite_fts5")
+)
+
+// legacyTables are the tables AutoMigrate created before migrations
+// existed, by the version of the migration that creates them.
+var legacyTables = []struct {
+	version int
+	table   string
+}{
+	{1, "users"},
+	{2, "products"},
+}
+
+var dollarQuote = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// fileName matches <version>_<name>[.<dialect>].(up|down).sql. Scripts of
+// a dialect, such as sqlite, replace the generic ones on databases of that
+// dialect and are ignored on the others.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)(?:\.(sqlite|postgres|mysql))?\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the contents of a migration, so that editing a
+// migration after it has been applied is detected instead of ignored.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+	return hex.EncodeToString(sum[:])
+}
+
+type Record struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (Record) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded in this package, with
+// the scripts of the dialect of db.
+func New(db *gorm.DB) (*Migrator, error) {
+	sub, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(sub, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads <version>_<name>.up.sql and <version>_<name>.down.sql pairs
+// from fsys and returns them ordered by version. A migration that only has
+// scripts of other dialects than dialect does nothing on this one.
+func Load(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	// dialectOnly holds the versions that only have scripts of other
+	// dialects so far.
+	dialectOnly := map[int]bool{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+			dialectOnly[version] = true
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		fileDialect, direction := match[3], match[4]
+		if fileDialect != "" && fileDialect != dialect {
+			continue
+		}
+		script := &m.Up
+		if direction == "down" {
+			script = &m.Down
+		}
+		// The script of the dialect wins over the generic one, whichever
+		// is read first.
+		if fileDialect == "" && dialectScripts(fsys, match[1], m.Name, dialect, direction) {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		*script = string(content)
+		if fileDialect == "" {
+			delete(dialectOnly, version)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" && !dialectOnly[m.Version] {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// dialectScripts reports whether the migration has a script of the dialect
+// in the direction.
+func dialectScripts(fsys fs.FS, version, name, dialect, direction string) bool {
+	_, err := fs.Stat(fsys, version+"_"+name+"."+dialect+"."+direction+".sql")
+	return err == nil
+}
+
+// Latest returns the highest known migration version, or 0 if there are none.
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		s := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = record.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down rolls back the most recently applied migration, if any.
+func (m *Migrator) Down() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	var versions []int
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			versions = append(versions, migration.Version)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return nil
+	case 1:
+		return m.To(0)
+	}
+	return m.To(versions[len(versions)-2])
+}
+
+// To migrates the schema up or down so that exactly the migrations with a
+// version lower than or equal to target are applied. Target 0 rolls back
+// everything.
+func (m *Migrator) To(target int) error {
+	if target != 0 && m.find(target) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	// Running the first migrations on the tables of AutoMigrate would fail
+	// halfway through.
+	if len(applied) == 0 && target > 0 && m.DB.Migrator().HasTable(legacyTables[0].table) {
+		return ErrUnversioned
+	}
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > target {
+			if err := m.rollback(migration); err != nil {
+				return err
+			}
+		}
+	}
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= target {
+			if err := m.apply(migration); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Baseline adopts a database whose tables AutoMigrate created before
+// migrations existed: it records the migrations creating the tables found
+// as applied, without running them, so that Up applies the later ones. It
+// also creates the index of products that AutoMigrate left out.
+func (m *Migrator) Baseline() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		return ErrVersioned
+	}
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		adopted := 0
+		for _, legacy := range legacyTables {
+			migration := m.find(legacy.version)
+			if migration == nil || !tx.Migrator().HasTable(legacy.table) {
+				break
+			}
+			err := tx.Create(&Record{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum(),
+				AppliedAt: time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+			adopted++
+		}
+		if adopted == 0 {
+			return ErrNoLegacySchema
+		}
+		if adopted == len(legacyTables) && !tx.Migrator().HasIndex("products", "idx_products_created_at") {
+			return tx.Exec("CREATE INDEX idx_products_created_at ON products (created_at)").Error
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := exec(tx, migration.Up); err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				err = fmt.Errorf("%w: %w", err, ErrNoFTS5)
+			}
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		return tx.Create(&Record{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum(),
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+func (m *Migrator) rollback(migration Migration) error {
+	if migration.Down == "" && migration.Up != "" {
+		return fmt.Errorf("%w: %d_%s", ErrMissingDown, migration.Version, migration.Name)
+	}
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		if err := exec(tx, migration.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		return tx.Delete(&Record{}, migration.Version).Error
+	})
+}
+
+// applied loads the schema_migrations table, creating it on first use, and
+// verifies that none of the applied migrations has changed since.
+func (m *Migrator) applied() (map[int]Record, error) {
+	if err := m.DB.AutoMigrate(&Record{}); err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := m.DB.Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		migration := m.find(record.Version)
+		if migration == nil {
+			return nil, fmt.Errorf("%w: %d is applied but not known", ErrUnknownVersion, record.Version)
+		}
+		if migration.Checksum() != record.Checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, record.Version, record.Name)
+		}
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.Migrations {
+		if m.Migrations[i].Version == version {
+			return &m.Migrations[i]
+		}
+	}
+	return nil
+}
+
+// exec runs each statement of a script separately, since not every driver
+// accepts several statements in a single Exec call.
+func exec(tx *gorm.DB, script string) error {
+	for _, statement := range statements(script) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements splits a script on the semicolons ending its statements. Those
+// within quotes, comments, dollar-quoted bodies and the BEGIN ... END
+// blocks of triggers do not end a statement, so scripts must not open
+// transactions of their own: every migration runs in one already.
+func statements(script string) []string {
+	var list []string
+	start, depth := 0, 0
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipTo(script, i+1, string(c))
+		case strings.HasPrefix(script[i:], "--"):
+			i = skipTo(script, i+2, "\n")
+		case strings.HasPrefix(script[i:], "/*"):
+			i = skipTo(script, i+2, "*/")
+		case c == '$' && dollarQuote.MatchString(script[i:]):
+			tag := dollarQuote.FindString(script[i:])
+			i = skipTo(script, i+len(tag), tag)
+		case isWordChar(c) && (i == 0 || !isWordChar(script[i-1])):
+			j := i
+			for j < len(script) && isWordChar(script[j]) {
+				j++
+			}
+			switch strings.ToUpper(script[i:j]) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				if depth > 0 {
+					depth--
+				}
+			}
+			i = j - 1
+		case c == ';' && depth == 0:
+			list = appendStatement(list, script[start:i])
+			start = i + 1
+		}
+	}
+	return appendStatement(list, script[start:])
+}
+
+// skipTo returns the index of the last byte of the first end found from
+// i on, or that of the end of the script if there is none.
+func skipTo(script string, i int, end string) int {
+	n := strings.Index(script[i:], end)
+	if n < 0 {
+		return len(script) - 1
+	}
+	return i + n + len(end) - 1
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func appendStatement(list []string, statement string) []string {
+	if statement = strings.TrimSpace(statement); statement != "" {
+		list = append(list, statement)
+	}
+	return list
+}
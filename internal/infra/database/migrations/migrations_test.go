@@ -0,0 +1,398 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newMigrator(t *testing.T) *Migrator {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("could not load migrations: %v", err)
+	}
+	return migrator
+}
+
+// up applies every migration. The search index migration needs SQLite with
+// the FTS5 module, which go-sqlite3 only compiles with the sqlite_fts5 build
+// tag the Makefile sets: a plain go test skips the test rather than fail.
+func up(t *testing.T, migrator *Migrator) {
+	t.Helper()
+	err := migrator.Up()
+	if errors.Is(err, ErrNoFTS5) {
+		t.Skipf("SQLite lacks FTS5, run the tests with -tags sqlite_fts5: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"README.md":            {Data: []byte("ignored")},
+	}
+	migrations, err := Load(fsys, "sqlite")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, 2, migrations[1].Version)
+	assert.Equal(t, "DROP TABLE b;", migrations[1].Down)
+
+	_, err = Load(fstest.MapFS{"0001_first.down.sql": {Data: []byte("DROP TABLE a;")}}, "sqlite")
+	assert.Error(t, err)
+}
+
+func TestLoad_Dialects(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_first.up.sql":            {Data: []byte("CREATE TABLE a (id INT);")},
+		"0001_first.sqlite.up.sql":     {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"0001_first.down.sql":          {Data: []byte("DROP TABLE a;")},
+		"0002_index.postgres.up.sql":   {Data: []byte("CREATE INDEX i ON a (id);")},
+		"0002_index.postgres.down.sql": {Data: []byte("DROP INDEX i;")},
+	}
+	migrations, err := Load(fsys, "sqlite")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, "CREATE TABLE a (id INTEGER);", migrations[0].Up)
+	assert.Equal(t, "DROP TABLE a;", migrations[0].Down)
+	assert.Equal(t, "index", migrations[1].Name)
+	assert.Empty(t, migrations[1].Up, "the migration does nothing on sqlite")
+
+	migrations, err = Load(fsys, "postgres")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE a (id INT);", migrations[0].Up)
+	assert.Equal(t, "CREATE INDEX i ON a (id);", migrations[1].Up)
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	migrator := newMigrator(t)
+
+	pending, err := migrator.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, len(migrator.Migrations))
+
+	up(t, migrator)
+	pending, err = migrator.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.True(t, migrator.DB.Migrator().HasTable("products"))
+	assert.True(t, migrator.DB.Migrator().HasTable("users"))
+
+	// Running up again is a no-op.
+	assert.NoError(t, migrator.Up())
+
+	assert.NoError(t, migrator.Down())
+	status, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.False(t, status[len(status)-1].Applied)
+	assert.True(t, status[0].Applied)
+
+	assert.NoError(t, migrator.To(0))
+	assert.False(t, migrator.DB.Migrator().HasTable("products_fts"))
+	assert.False(t, migrator.DB.Migrator().HasTable("products"))
+	assert.False(t, migrator.DB.Migrator().HasTable("users"))
+}
+
+func TestMigrator_To(t *testing.T) {
+	migrator := newMigrator(t)
+	assert.NoError(t, migrator.To(1))
+	assert.True(t, migrator.DB.Migrator().HasTable("users"))
+	assert.False(t, migrator.DB.Migrator().HasTable("products"))
+
+	assert.ErrorIs(t, migrator.To(9999), ErrUnknownVersion)
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	migrator := newMigrator(t)
+	up(t, migrator)
+	migrator.Migrations[0].Up += "\n-- edited after being applied"
+	_, err := migrator.Status()
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"statements", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"no trailing semicolon", "DROP TABLE a", []string{"DROP TABLE a"}},
+		{"string", "INSERT INTO a VALUES ('x;y', 'it''s;');", []string{"INSERT INTO a VALUES ('x;y', 'it''s;')"}},
+		{"quoted identifier", `SELECT "a;b" FROM a;`, []string{`SELECT "a;b" FROM a`}},
+		{"comments", "-- drop a; and b\nDROP TABLE a; /* ; */ DROP TABLE b;", []string{"-- drop a; and b\nDROP TABLE a", "/* ; */ DROP TABLE b"}},
+		{"trigger", "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO b VALUES (CASE WHEN new.id > 0 THEN 1 ELSE 0 END);\n  DELETE FROM c;\nEND;\nDROP TABLE d;",
+			[]string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO b VALUES (CASE WHEN new.id > 0 THEN 1 ELSE 0 END);\n  DELETE FROM c;\nEND", "DROP TABLE d"}},
+		{"dollar quoted", "CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql;", []string{"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql"}},
+		{"blank", " ;\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statements(tt.script))
+		})
+	}
+}
+
+// legacyUser and legacyProduct are the models AutoMigrate created the
+// tables of before migrations existed.
+type legacyUser struct {
+	ID       string
+	Name     string
+	Email    string
+	Password string
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacyProduct struct {
+	ID        string
+	Name      string
+	Price     int
+	CreatedAt time.Time
+}
+
+func (legacyProduct) TableName() string { return "products" }
+
+func TestMigrator_BaselineAdoptsAutoMigratedDatabase(t *testing.T) {
+	migrator := newMigrator(t)
+	assert.ErrorIs(t, migrator.Baseline(), ErrNoLegacySchema)
+	assert.NoError(t, migrator.DB.AutoMigrate(&legacyUser{}, &legacyProduct{}))
+	userID, productID := pkgEntity.NewID(), pkgEntity.NewID()
+	assert.NoError(t, migrator.DB.Create(&legacyUser{ID: userID.String(), Name: "Legacy", Email: "legacy@example.com", Password: "hash"}).Error)
+	assert.NoError(t, migrator.DB.Create(&legacyProduct{ID: productID.String(), Name: "Legacy", Price: 1500, CreatedAt: time.Now()}).Error)
+
+	assert.ErrorIs(t, migrator.Up(), ErrUnversioned)
+	assert.NoError(t, migrator.Baseline())
+	assert.ErrorIs(t, migrator.Baseline(), ErrVersioned)
+	status, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+	assert.False(t, status[2].Applied)
+	assert.True(t, migrator.DB.Migrator().HasIndex("products", "idx_products_created_at"))
+
+	up(t, migrator)
+	user, err := database.NewUserUnscoped(migrator.DB).FindByID(userID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Legacy", user.Name)
+	product, err := database.NewProductUnscoped(migrator.DB).FindByID(productID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, pkgEntity.Money{Amount: 1500, Currency: "USD"}, product.Price)
+}
+
+// The migrated schema must stay compatible with the repositories, which are
+// otherwise tested against AutoMigrate.
+func TestMigrator_SchemaMatchesRepositories(t *testing.T) {
+	migrator := newMigrator(t)
+	up(t, migrator)
+
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := database.NewUserUnscoped(migrator.DB)
+	assert.NoError(t, userDB.Create(user))
+	userFound, err := userDB.FindByEmail(user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, userFound.ID)
+	duplicate, _ := entity.NewUser("Johnny", "J@J.com", "s3cure-pass")
+	assert.ErrorIs(t, userDB.Create(duplicate), database.ErrConflict)
+	assert.NoError(t, userDB.UpdateRole(user.ID.String(), entity.RoleAdmin))
+	_, err = userDB.MarkVerificationSent(user.ID.String(), time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, userDB.VerifyEmail(user.ID.String()))
+	userFound, err = userDB.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RoleAdmin, userFound.Role)
+
+	organization, _ := entity.NewOrganization("Retail")
+	admin, _ := entity.NewUser("Ann", "ann@retail.com", "s3cure-pass")
+	organizationDB := database.NewOrganization(migrator.DB)
+	assert.NoError(t, organizationDB.Create(organization, admin))
+	_, err = organizationDB.FindByID(entity.DefaultOrganizationID.String())
+	assert.NoError(t, err)
+	_, err = database.NewUser(migrator.DB, organization.ID.String()).FindByID(admin.ID.String())
+	assert.NoError(t, err)
+
+	category, _ := entity.NewCategory("Electronics", nil)
+	categoryDB := database.NewCategoryUnscoped(migrator.DB)
+	assert.NoError(t, categoryDB.Create(category))
+	categories, err := categoryDB.ForTenant(organization.ID.String()).FindAll()
+	assert.NoError(t, err)
+	assert.Empty(t, categories)
+
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.Categories = []entity.Category{*category}
+	product.OwnerID = &user.ID
+	productDB := database.NewProductUnscoped(migrator.DB)
+	assert.NoError(t, productDB.Create(product))
+	productFound, err := productDB.FindByID(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, product.Name, productFound.Name)
+	assert.Len(t, productFound.Categories, 1)
+	products, err := productDB.FindAllByCategory(category.ID.String(), true, 1, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	products, err = productDB.FindByQuery(database.ProductQuery{OwnerID: user.ID.String()})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	products, err = productDB.ForTenant(organization.ID.String()).FindByQuery(database.ProductQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	search, err := database.NewProductSearchUnscoped(migrator.DB)
+	assert.NoError(t, err)
+	results, err := search.Search("product", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	assert.NoError(t, productDB.Delete(product.ID.String(), 0))
+	_, err = productDB.Restore(product.ID.String())
+	assert.NoError(t, err)
+
+	stockDB := database.NewStockUnscoped(migrator.DB)
+	receipt, _ := entity.NewStockMovement(product.ID, entity.MovementReceipt, 5, "")
+	level, err := stockDB.Record(receipt)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, level.OnHand)
+
+	tokenDB := database.NewToken(migrator.DB)
+	refreshToken, plain, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(refreshToken))
+	next, _, _ := entity.NewRefreshToken(user.ID, &refreshToken.FamilyID, time.Hour)
+	assert.NoError(t, tokenDB.Rotate(refreshToken, next))
+	_, err = tokenDB.FindRefreshToken(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.NoError(t, tokenDB.RevokeFamily(refreshToken.FamilyID.String()))
+	assert.NoError(t, tokenDB.RevokeAccessToken(pkgEntity.NewID().String(), time.Now().Add(time.Hour)))
+	assert.NoError(t, tokenDB.RevokeUser(user.ID.String(), ""))
+	resetToken, resetPlain, _ := entity.NewPasswordResetToken(user.ID, time.Hour)
+	assert.NoError(t, tokenDB.CreatePasswordResetToken(resetToken))
+	resetToken, err = tokenDB.FindPasswordResetToken(entity.HashToken(resetPlain))
+	assert.NoError(t, err)
+	assert.NoError(t, tokenDB.ResetPassword(resetToken, user.Password))
+	assert.NoError(t, userDB.UpdatePassword(user.ID.String(), user.Password))
+	_, err = tokenDB.Purge(time.Now())
+	assert.NoError(t, err)
+
+	throttle := database.NewLoginThrottle(migrator.DB)
+	failures, err := throttle.Fail(user.Email, entity.LockoutPolicy{MaxFailures: 1, Lockout: time.Minute, Window: time.Hour})
+	assert.NoError(t, err)
+	assert.NotNil(t, failures.LockedUntil)
+	failures, err = throttle.Find(user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures)
+	assert.NoError(t, throttle.Reset(user.Email))
+	_, err = throttle.Purge(time.Now())
+	assert.NoError(t, err)
+
+	audit := database.NewAudit(migrator.DB)
+	entry := entity.NewAuditEntry(entity.AuditLoginLocked, user.Email, "127.0.0.1", "locked out")
+	entry.UserID = &user.ID
+	assert.NoError(t, audit.Record(entry))
+	entries, err := audit.FindBySubject(user.Email)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	mfa := database.NewMFA(migrator.DB)
+	codes, plainCodes, _ := entity.NewRecoveryCodes(user.ID, 2)
+	assert.NoError(t, mfa.EnrollTOTP(user.ID.String(), "SECRET"))
+	assert.NoError(t, mfa.EnableTOTP(user.ID.String(), "SECRET", 10, codes))
+	assert.NoError(t, mfa.UseTOTPStep(user.ID.String(), 11))
+	assert.NoError(t, mfa.UseRecoveryCode(user.ID.String(), entity.HashRecoveryCode(plainCodes[0])))
+	userFound, err = userDB.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, userFound.TOTPEnabled())
+	assert.Equal(t, int64(11), userFound.TOTPLastStep)
+
+	apiKeyDB := database.NewAPIKey(migrator.DB)
+	apiKey, plainKey, _ := entity.NewAPIKey(user.ID, "Batch", []entity.Scope{entity.ScopeProductsRead}, nil)
+	assert.NoError(t, apiKeyDB.Create(apiKey))
+	_, err = apiKeyDB.FindByHash(entity.HashToken(plainKey))
+	assert.NoError(t, err)
+	assert.NoError(t, apiKeyDB.Touch(apiKey.ID.String(), time.Now()))
+	apiKeys, err := apiKeyDB.FindByUser(user.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, apiKeys, 1)
+	assert.NoError(t, apiKeyDB.Revoke(user.ID.String(), apiKey.ID.String()))
+}
+
+func TestMigrator_PriceCurrencyKeepsExistingPrices(t *testing.T) {
+	migrator := newMigrator(t)
+	assert.NoError(t, migrator.To(4))
+	id := pkgEntity.NewID()
+	err := migrator.DB.Exec(
+		"INSERT INTO products (id, name, price, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)",
+		id.String(), "Legacy", 1500,
+	).Error
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrator.To(5))
+	var price pkgEntity.Money
+	err = migrator.DB.Raw(
+		"SELECT price_amount AS amount, price_currency AS currency FROM products WHERE id = ?", id.String(),
+	).Scan(&price).Error
+	assert.NoError(t, err)
+	assert.Equal(t, pkgEntity.Money{Amount: 1500, Currency: "USD"}, price)
+}
+
+func TestMigrator_UserEmailUniqueNormalizesEmails(t *testing.T) {
+	migrator := newMigrator(t)
+	assert.NoError(t, migrator.To(11))
+	id := pkgEntity.NewID()
+	err := migrator.DB.Exec(
+		"INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)",
+		id.String(), "Legacy", " Legacy@Example.com ", "hash",
+	).Error
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrator.To(12))
+	user, err := database.NewUserUnscoped(migrator.DB).FindByEmail("legacy@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+
+	assert.NoError(t, migrator.To(11))
+	user, err = database.NewUserUnscoped(migrator.DB).FindByID(id.String())
+	assert.NoError(t, err, "the down migration keeps the users")
+	assert.Equal(t, "legacy@example.com", user.Email)
+}
+
+func TestMigrator_EmailVerificationKeepsExistingUsersVerified(t *testing.T) {
+	migrator := newMigrator(t)
+	assert.NoError(t, migrator.To(13))
+	id := pkgEntity.NewID()
+	err := migrator.DB.Exec(
+		"INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)",
+		id.String(), "Legacy", "legacy@example.com", "hash",
+	).Error
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrator.To(14))
+	user, err := database.NewUserUnscoped(migrator.DB).FindByID(id.String())
+	assert.NoError(t, err)
+	assert.True(t, user.EmailVerified())
+	// Users are created with the columns of the later migrations.
+	up(t, migrator)
+	created, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	assert.NoError(t, database.NewUserUnscoped(migrator.DB).Create(created))
+	user, err = database.NewUserUnscoped(migrator.DB).FindByID(created.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, user.EmailVerified())
+
+	assert.NoError(t, migrator.To(13))
+}
@@ -0,0 +1,7 @@
+CREATE TABLE products (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    price BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_products_created_at ON products (created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE categories (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id VARCHAR(36) NULL REFERENCES categories (id),
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+CREATE TABLE product_categories (
+    product_id VARCHAR(36) NOT NULL REFERENCES products (id),
+    category_id VARCHAR(36) NOT NULL REFERENCES categories (id),
+    PRIMARY KEY (product_id, category_id)
+);
+CREATE INDEX idx_product_categories_category_id ON product_categories (category_id);
@@ -0,0 +1,15 @@
+CREATE TABLE stock_levels (
+    product_id VARCHAR(36) NOT NULL PRIMARY KEY,
+    on_hand BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    CHECK (on_hand >= 0)
+);
+CREATE TABLE stock_movements (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    product_id VARCHAR(36) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    quantity BIGINT NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_stock_movements_product_id ON stock_movements (product_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE products DROP COLUMN price_currency;
+ALTER TABLE products RENAME COLUMN price_amount TO price;
@@ -0,0 +1,2 @@
+ALTER TABLE products RENAME COLUMN price TO price_amount;
+ALTER TABLE products ADD COLUMN price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,14 @@
+CREATE TABLE refresh_tokens (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    family_id VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(36) NOT NULL PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN owner_id VARCHAR(36) NULL;
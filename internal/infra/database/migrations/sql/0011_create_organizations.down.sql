@@ -0,0 +1,3 @@
+ALTER TABLE products DROP COLUMN organization_id;
+ALTER TABLE users DROP COLUMN organization_id;
+DROP TABLE organizations;
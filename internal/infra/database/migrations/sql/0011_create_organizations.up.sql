@@ -0,0 +1,8 @@
+CREATE TABLE organizations (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+INSERT INTO organizations (id, name, created_at) VALUES ('00000000-0000-0000-0000-000000000001', 'Default', CURRENT_TIMESTAMP);
+ALTER TABLE users ADD COLUMN organization_id VARCHAR(36) NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE products ADD COLUMN organization_id VARCHAR(36) NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
@@ -0,0 +1,2 @@
+UPDATE users SET email = LOWER(TRIM(email));
+CREATE UNIQUE INDEX idx_users_email ON users (email);
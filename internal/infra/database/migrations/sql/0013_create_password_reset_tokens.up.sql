@@ -0,0 +1,9 @@
+CREATE TABLE password_reset_tokens (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
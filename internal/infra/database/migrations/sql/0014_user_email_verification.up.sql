@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN verification_sent_at TIMESTAMP NULL;
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP;
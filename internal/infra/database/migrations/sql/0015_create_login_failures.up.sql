@@ -0,0 +1,16 @@
+CREATE TABLE login_failures (
+    subject VARCHAR(255) NOT NULL PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL
+);
+CREATE TABLE audit_entries (
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id VARCHAR(36) NULL,
+    ip VARCHAR(45) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_audit_entries_created_at ON audit_entries (created_at);
@@ -0,0 +1,15 @@
+-- The index was created at startup before it had a migration, hence IF NOT
+-- EXISTS and the rebuild of its rows.
+CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(id UNINDEXED, name);
+CREATE TRIGGER IF NOT EXISTS products_fts_ai AFTER INSERT ON products BEGIN
+    INSERT INTO products_fts (id, name) VALUES (new.id, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS products_fts_au AFTER UPDATE OF name ON products BEGIN
+    DELETE FROM products_fts WHERE id = old.id;
+    INSERT INTO products_fts (id, name) VALUES (new.id, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS products_fts_ad AFTER DELETE ON products BEGIN
+    DELETE FROM products_fts WHERE id = old.id;
+END;
+DELETE FROM products_fts;
+INSERT INTO products_fts (id, name) SELECT id, name FROM products;
@@ -0,0 +1 @@
+ALTER TABLE categories ADD COLUMN organization_id VARCHAR(36) NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type Organization struct {
+	DB *gorm.DB
+}
+
+func NewOrganization(db *gorm.DB) *Organization {
+	return &Organization{DB: db}
+}
+
+// Create stores the organization together with its first user, who is made
+// its admin.
+func (o *Organization) Create(organization *entity.Organization, admin *entity.User) error {
+	return translate(o.DB, o.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		admin.OrganizationID = organization.ID
+		admin.Role = entity.RoleAdmin
+		return tx.Create(admin).Error
+	}))
+}
+
+func (o *Organization) FindByID(id string) (*entity.Organization, error) {
+	var organization entity.Organization
+	if err := o.DB.Where("id = ?", id).First(&organization).Error; err != nil {
+		return nil, translate(o.DB, err)
+	}
+	return &organization, nil
+}
@@ -1,55 +1,282 @@
 package database
 
 import (
+	"errors"
+	"time"
+
 	"github.com/ThalesLoreto/product-api/internal/entity"
 	"gorm.io/gorm"
 )
 
+var ErrVersionMismatch = errors.New("product was modified by another request")
+
 type Product struct {
 	DB *gorm.DB
+	// TenantID confines the repository to the products of one
+	// organization, see TenantScope.
+	TenantID string
+}
+
+// NewProduct returns the repository of the products of the organization.
+func NewProduct(db *gorm.DB, tenantID string) *Product {
+	return &Product{DB: db, TenantID: tenantID}
 }
 
-func NewProduct(db *gorm.DB) *Product {
-	return &Product{DB: db}
+// NewProductUnscoped returns a repository of the products of every
+// organization, for the purge job and for the handlers to confine to the
+// organization of each request with ForTenant.
+func NewProductUnscoped(db *gorm.DB) *Product {
+	return &Product{DB: db, TenantID: allTenants}
 }
 
+// ForTenant returns a copy of the repository confined to the organization.
+func (p *Product) ForTenant(tenantID string) ProductInterface {
+	return &Product{DB: p.DB, TenantID: tenantID}
+}
+
+func (p *Product) scoped() *gorm.DB {
+	return p.DB.Scopes(TenantScope(p.TenantID))
+}
+
+func (p *Product) withDB(db *gorm.DB) *Product {
+	return &Product{DB: db, TenantID: p.TenantID}
+}
+
+// Create stores the product, in the organization of the repository if it
+// is confined to one.
 func (p *Product) Create(product *entity.Product) error {
-	return p.DB.Create(product).Error
+	organizationID, err := organizationOf(p.TenantID)
+	if err != nil {
+		return err
+	}
+	if organizationID != nil {
+		product.OrganizationID = *organizationID
+	}
+	return translate(p.DB, p.DB.Transaction(func(tx *gorm.DB) error {
+		categoryIDs := make([]string, 0, len(product.Categories))
+		for _, category := range product.Categories {
+			categoryIDs = append(categoryIDs, category.ID.String())
+		}
+		categories, err := p.findCategories(tx, categoryIDs)
+		if err != nil {
+			return err
+		}
+		product.Categories = categories
+		return tx.Omit("Categories.*").Create(product).Error
+	}))
 }
 
 func (p *Product) FindAll(page, limit int, sort string) ([]entity.Product, error) {
-	var products []entity.Product
-	if sort != "" && sort != "asc" && sort != "desc" {
-		sort = "asc"
+	return p.FindByQuery(ProductQuery{Sort: legacySort(sort), Page: page, Limit: limit})
+}
+
+// FindAllByCategory lists the products linked to the category and, when
+// includeDescendants is set, to any category below it.
+func (p *Product) FindAllByCategory(categoryID string, includeDescendants bool, page, limit int, sort string) ([]entity.Product, error) {
+	return p.FindByQuery(ProductQuery{
+		CategoryID:         categoryID,
+		IncludeDescendants: includeDescendants,
+		Sort:               legacySort(sort),
+		Page:               page,
+		Limit:              limit,
+	})
+}
+
+// FindByQuery lists the products matching every filter of the query.
+func (p *Product) FindByQuery(query ProductQuery) ([]entity.Product, error) {
+	query.tenantID = p.TenantID
+	db, err := query.scope(p.DB)
+	if err != nil {
+		return nil, translate(p.DB, err)
 	}
-	if page != 0 && limit != 0 {
-		err := p.DB.Offset((page - 1) * limit).Limit(limit).Order("created_at " + sort).Find(&products).Error
-		return products, err
+	if query.Page != 0 && query.Limit != 0 {
+		db = db.Offset((query.Page - 1) * query.Limit).Limit(query.Limit)
 	}
-	err := p.DB.Order("created_at " + sort).Find(&products).Error
-	return products, err
+	var products []entity.Product
+	err = db.Preload("Categories").Find(&products).Error
+	return products, translate(p.DB, err)
 }
 
 func (p *Product) FindByID(id string) (*entity.Product, error) {
 	var product entity.Product
-	if err := p.DB.Where("id = ?", id).First(&product).Error; err != nil {
-		return nil, err
+	if err := p.scoped().Preload("Categories").Where("id = ?", id).First(&product).Error; err != nil {
+		return nil, translate(p.DB, err)
 	}
 	return &product, nil
 }
 
-func (p *Product) Update(id string, fields interface{}) error {
-	product, err := p.FindByID(id)
-	if err != nil {
-		return err
+// Update applies the fields to the product and increments its version. A
+// non-zero version makes the update conditional: it fails with
+// ErrVersionMismatch unless the product is still at that version.
+func (p *Product) Update(id string, version int, fields interface{}) error {
+	return translate(p.DB, p.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := p.withDB(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, product, version); err != nil {
+			return err
+		}
+		return tx.Model(product).Omit("version").Updates(fields).Error
+	}))
+}
+
+// SetCategories replaces the categories the product is linked to.
+func (p *Product) SetCategories(id string, categoryIDs []string) error {
+	return translate(p.DB, p.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := p.withDB(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+		categories, err := p.findCategories(tx, categoryIDs)
+		if err != nil {
+			return err
+		}
+		return tx.Model(product).Omit("Categories.*").Association("Categories").Replace(categories)
+	}))
+}
+
+// Replace applies the fields to the product, replaces its categories and
+// increments its version in a single transaction, so that a failure or a
+// version mismatch leaves the product unchanged. A non-zero version makes
+// the replacement conditional, as for Update.
+func (p *Product) Replace(id string, version int, fields interface{}, categoryIDs []string) error {
+	return translate(p.DB, p.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := p.withDB(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, product, version); err != nil {
+			return err
+		}
+		if err := tx.Model(product).Omit("version").Updates(fields).Error; err != nil {
+			return err
+		}
+		categories, err := p.findCategories(tx, categoryIDs)
+		if err != nil {
+			return err
+		}
+		return tx.Model(product).Omit("Categories.*").Association("Categories").Replace(categories)
+	}))
+}
+
+// Delete soft deletes the product: it is hidden from every lookup but keeps
+// its data, including its categories, until it is restored or purged. A
+// non-zero version makes the deletion conditional, as for Update.
+func (p *Product) Delete(id string, version int) error {
+	return translate(p.DB, p.DB.Transaction(func(tx *gorm.DB) error {
+		product, err := p.withDB(tx).FindByID(id)
+		if err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, product, version); err != nil {
+			return err
+		}
+		return tx.Delete(product).Error
+	}))
+}
+
+// FindByIDUnscoped finds the product even if it has been soft deleted.
+func (p *Product) FindByIDUnscoped(id string) (*entity.Product, error) {
+	var product entity.Product
+	if err := p.scoped().Unscoped().Preload("Categories").Where("id = ?", id).First(&product).Error; err != nil {
+		return nil, translate(p.DB, err)
 	}
-	return p.DB.Model(&product).Updates(fields).Error
+	return &product, nil
 }
 
-func (p *Product) Delete(id string) error {
-	product, err := p.FindByID(id)
+// Restore undoes the soft deletion of the product, as a new version so that
+// copies cached while it was deleted are stale. Restoring a product that is
+// not deleted does nothing.
+func (p *Product) Restore(id string) (*entity.Product, error) {
+	product, err := p.FindByIDUnscoped(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !product.DeletedAt.Valid {
+		return product, nil
+	}
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(product).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return bumpVersion(tx, product, 0)
+	})
+	if err != nil {
+		return nil, translate(p.DB, err)
+	}
+	return p.FindByID(id)
+}
+
+// Purge permanently removes the products soft deleted before the given
+// time, along with their stock, returning how many were removed.
+func (p *Product) Purge(deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		err := tx.Scopes(TenantScope(p.TenantID)).Unscoped().Model(&entity.Product{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := tx.Table("product_categories").Where("product_id IN ?", ids).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&entity.StockMovement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&entity.StockLevel{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&entity.Product{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, translate(p.DB, err)
+}
+
+// bumpVersion increments the version of the product, first checking it
+// against the expected one unless that is zero. The check is part of the
+// UPDATE so that two concurrent writers cannot both pass it.
+func bumpVersion(tx *gorm.DB, product *entity.Product, expected int) error {
+	current := product.Version
+	if expected != 0 && expected != current {
+		return ErrVersionMismatch
+	}
+	result := tx.Model(&entity.Product{}).
+		Where("id = ? AND version = ?", product.ID.String(), current).
+		UpdateColumn("version", current+1)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionMismatch
+	}
+	product.Version = current + 1
+	return nil
+}
+
+// legacySort maps the asc/desc sort of the original listing onto the
+// creation date, falling back to ascending for anything else.
+func legacySort(sort string) []SortField {
+	return []SortField{{Field: "created_at", Desc: sort == "desc"}}
+}
+
+// findCategories loads the categories with the given IDs, failing if any of
+// them does not exist in the organization of p.
+func (p *Product) findCategories(db *gorm.DB, ids []string) ([]entity.Category, error) {
+	categoryDB := &Category{DB: db, TenantID: p.TenantID}
+	categories := make([]entity.Category, 0, len(ids))
+	for _, id := range ids {
+		category, err := categoryDB.FindByID(id)
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
 	}
-	return p.DB.Delete(product).Error
+	return categories, nil
 }
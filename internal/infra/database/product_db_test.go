@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -19,8 +21,8 @@ func TestProduct_Create(t *testing.T) {
 	if err := db.AutoMigrate(&entity.Product{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	product, _ := entity.NewProduct("Product 1", 10)
-	productDB := NewProduct(db)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	productDB := NewProduct(db, defaultTenant)
 	if err := productDB.Create(product); err != nil {
 		t.Errorf("could not create product: %v", err)
 	}
@@ -42,10 +44,10 @@ func TestProduct_FindAll(t *testing.T) {
 		t.Fatalf("could not migrate db: %v", err)
 	}
 	for i := 0; i < 10; i++ {
-		product, _ := entity.NewProduct(fmt.Sprintf("Product %d", i+1), rand.Int())
+		product, _ := entity.NewProduct(fmt.Sprintf("Product %d", i+1), pkgEntity.Money{Amount: rand.Int63n(10000) + 1, Currency: "USD"})
 		db.Create(&product)
 	}
-	productDB := NewProduct(db)
+	productDB := NewProduct(db, defaultTenant)
 	products, err := productDB.FindAll(1, 5, "asc")
 	assert.Nil(t, err)
 	assert.Len(t, products, 5)
@@ -67,9 +69,9 @@ func TestProduct_FindById(t *testing.T) {
 	if err := db.AutoMigrate(&entity.Product{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	product, _ := entity.NewProduct("Product 1", 10)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
 	db.Create(&product)
-	productDB := NewProduct(db)
+	productDB := NewProduct(db, defaultTenant)
 	productFound, err := productDB.FindByID(product.ID.String())
 	assert.Nil(t, err)
 	assert.Equal(t, product.ID, productFound.ID)
@@ -85,11 +87,11 @@ func TestProduct_Update(t *testing.T) {
 	if err := db.AutoMigrate(&entity.Product{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	product, _ := entity.NewProduct("Product 1", 10)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
 	db.Create(&product)
 	product.Name = "Product 2"
-	productDB := NewProduct(db)
-	err = productDB.Update(product.ID.String(), product)
+	productDB := NewProduct(db, defaultTenant)
+	err = productDB.Update(product.ID.String(), 0, product)
 	assert.NoError(t, err)
 	productFound, err := productDB.FindByID(product.ID.String())
 	assert.NoError(t, err)
@@ -106,12 +108,239 @@ func TestProduct_Delete(t *testing.T) {
 	if err := db.AutoMigrate(&entity.Product{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	product, _ := entity.NewProduct("Product 1", 10)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
 	db.Create(&product)
-	productDB := NewProduct(db)
-	err = productDB.Delete(product.ID.String())
+	productDB := NewProduct(db, defaultTenant)
+	err = productDB.Delete(product.ID.String(), 0)
 	assert.NoError(t, err)
 	var productFound entity.Product
 	err = db.First(&productFound, product.ID).Error
 	assert.Error(t, err)
 }
+
+func TestProduct_VersionConflict(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	db.Create(&product)
+	productDB := NewProduct(db, defaultTenant)
+	id := product.ID.String()
+
+	assert.NoError(t, productDB.Update(id, 1, map[string]interface{}{"name": "Product 2"}))
+	productFound, err := productDB.FindByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, productFound.Version)
+
+	err = productDB.Update(id, 1, map[string]interface{}{"name": "Product 3"})
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	assert.ErrorIs(t, productDB.Delete(id, 1), ErrVersionMismatch)
+	productFound, err = productDB.FindByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Product 2", productFound.Name)
+	assert.Equal(t, 2, productFound.Version)
+
+	assert.NoError(t, productDB.Delete(id, 2))
+}
+
+func TestProduct_FindAllByCategory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Category{}, &entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	categoryDB := NewCategory(db, defaultTenant)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	books, _ := entity.NewCategory("Books", nil)
+	for _, c := range []*entity.Category{electronics, phones, books} {
+		assert.NoError(t, categoryDB.Create(c))
+	}
+	productDB := NewProduct(db, defaultTenant)
+	tv, _ := entity.NewProduct("TV", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	tv.Categories = []entity.Category{{ID: electronics.ID}}
+	phone, _ := entity.NewProduct("Phone", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	phone.Categories = []entity.Category{{ID: phones.ID}}
+	book, _ := entity.NewProduct("Book", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	book.Categories = []entity.Category{{ID: books.ID}}
+	for _, p := range []*entity.Product{tv, phone, book} {
+		assert.NoError(t, productDB.Create(p))
+	}
+
+	products, err := productDB.FindAllByCategory(electronics.ID.String(), false, 0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "TV", products[0].Name)
+	assert.Equal(t, "Electronics", products[0].Categories[0].Name)
+
+	products, err = productDB.FindAllByCategory(electronics.ID.String(), true, 0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+
+	_, err = productDB.FindAllByCategory("missing", false, 0, 0, "asc")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestProduct_SetCategories(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Category{}, &entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	categoryDB := NewCategory(db, defaultTenant)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	books, _ := entity.NewCategory("Books", nil)
+	assert.NoError(t, categoryDB.Create(electronics))
+	assert.NoError(t, categoryDB.Create(books))
+	productDB := NewProduct(db, defaultTenant)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.Categories = []entity.Category{{ID: electronics.ID}}
+	assert.NoError(t, productDB.Create(product))
+
+	err = productDB.SetCategories(product.ID.String(), []string{books.ID.String()})
+	assert.NoError(t, err)
+	productFound, err := productDB.FindByID(product.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, productFound.Categories, 1)
+	assert.Equal(t, books.ID, productFound.Categories[0].ID)
+
+	err = productDB.SetCategories(product.ID.String(), []string{"missing"})
+	assert.ErrorIs(t, err, ErrCategoryNotFound)
+
+	err = productDB.SetCategories(product.ID.String(), []string{})
+	assert.NoError(t, err)
+	productFound, _ = productDB.FindByID(product.ID.String())
+	assert.Empty(t, productFound.Categories)
+}
+
+func TestProduct_Replace(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Category{}, &entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	categoryDB := NewCategory(db, defaultTenant)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	books, _ := entity.NewCategory("Books", nil)
+	assert.NoError(t, categoryDB.Create(electronics))
+	assert.NoError(t, categoryDB.Create(books))
+	productDB := NewProduct(db, defaultTenant)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.Categories = []entity.Category{{ID: electronics.ID}}
+	assert.NoError(t, productDB.Create(product))
+	id := product.ID.String()
+
+	err = productDB.Replace(id, 1, map[string]interface{}{"name": "Renamed"}, []string{books.ID.String()})
+	assert.NoError(t, err)
+	productFound, err := productDB.FindByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", productFound.Name)
+	assert.Equal(t, 2, productFound.Version)
+	assert.Len(t, productFound.Categories, 1)
+	assert.Equal(t, books.ID, productFound.Categories[0].ID)
+
+	// Neither a stale version nor an unknown category changes anything.
+	err = productDB.Replace(id, 1, map[string]interface{}{"name": "Stale"}, []string{})
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	err = productDB.Replace(id, 2, map[string]interface{}{"name": "Unknown"}, []string{"missing"})
+	assert.ErrorIs(t, err, ErrCategoryNotFound)
+	productFound, _ = productDB.FindByID(id)
+	assert.Equal(t, "Renamed", productFound.Name)
+	assert.Equal(t, 2, productFound.Version)
+	assert.Len(t, productFound.Categories, 1)
+}
+
+func TestProduct_SoftDeleteAndRestore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	productDB := NewProduct(db, defaultTenant)
+	kept, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	deleted, _ := entity.NewProduct("Product 2", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, productDB.Create(kept))
+	assert.NoError(t, productDB.Create(deleted))
+
+	assert.NoError(t, productDB.Delete(deleted.ID.String(), 0))
+	_, err = productDB.FindByID(deleted.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	products, err := productDB.FindAll(0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+
+	found, err := productDB.FindByIDUnscoped(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.DeletedAt.Valid)
+	page, err := productDB.FindPage(ProductQuery{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+
+	restored, err := productDB.Restore(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+	assert.Greater(t, restored.Version, found.Version, "restoring is a new version")
+	again, err := productDB.Restore(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, restored.Version, again.Version)
+	products, err = productDB.FindAll(0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+
+	_, err = productDB.Restore("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestProduct_Purge(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Category{}, &entity.Product{}, &entity.StockLevel{}, &entity.StockMovement{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	category, _ := entity.NewCategory("Electronics", nil)
+	assert.NoError(t, NewCategory(db, defaultTenant).Create(category))
+	productDB := NewProduct(db, defaultTenant)
+	old, _ := entity.NewProduct("Old", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	old.Categories = []entity.Category{{ID: category.ID}}
+	recent, _ := entity.NewProduct("Recent", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	alive, _ := entity.NewProduct("Alive", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	for _, p := range []*entity.Product{old, recent, alive} {
+		assert.NoError(t, productDB.Create(p))
+	}
+	receipt, _ := entity.NewStockMovement(old.ID, entity.MovementReceipt, 5, "")
+	_, err = NewStock(db, defaultTenant).Record(receipt)
+	assert.NoError(t, err)
+	assert.NoError(t, productDB.Delete(old.ID.String(), 0))
+	assert.NoError(t, productDB.Delete(recent.ID.String(), 0))
+	db.Unscoped().Model(old).Update("deleted_at", time.Now().AddDate(0, 0, -40))
+
+	purged, err := productDB.Purge(time.Now().AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = productDB.FindByIDUnscoped(old.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = productDB.FindByIDUnscoped(recent.ID.String())
+	assert.NoError(t, err)
+	var links int64
+	db.Table("product_categories").Where("product_id = ?", old.ID.String()).Count(&links)
+	assert.Zero(t, links)
+	var levels, movements int64
+	db.Model(&entity.StockLevel{}).Where("product_id = ?", old.ID.String()).Count(&levels)
+	db.Model(&entity.StockMovement{}).Where("product_id = ?", old.ID.String()).Count(&movements)
+	assert.Zero(t, levels)
+	assert.Zero(t, movements)
+}
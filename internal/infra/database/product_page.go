@@ -0,0 +1,201 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+const DefaultPageLimit = 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ProductPage is one page of a product listing. The cursors are opaque
+// tokens to pass back as ProductQuery.Cursor and are empty when there is
+// no page in that direction.
+type ProductPage struct {
+	Items      []entity.Product
+	NextCursor string
+	PrevCursor string
+	Total      int64
+}
+
+// cursor points just after (or, for Before, just before) the product whose
+// sort values it holds. Sort pins the ordering it was issued for, since the
+// values are meaningless under any other one.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	Before bool     `json:"b,omitempty"`
+}
+
+// FindPage lists a page of the products matching the query. A cursor in the
+// query selects keyset pagination, otherwise Page and Limit select an
+// offset page as FindByQuery does.
+func (p *Product) FindPage(query ProductQuery) (*ProductPage, error) {
+	query.tenantID = p.TenantID
+	filtered, err := query.filter(p.DB)
+	if err != nil {
+		return nil, translate(p.DB, err)
+	}
+	page := &ProductPage{}
+	if err := filtered.Model(&entity.Product{}).Count(&page.Total).Error; err != nil {
+		return nil, translate(p.DB, err)
+	}
+	if query.Cursor != "" && query.Limit == 0 {
+		query.Limit = DefaultPageLimit
+	}
+	if query.Limit == 0 {
+		err := query.order(filtered, false).Preload("Categories").Find(&page.Items).Error
+		return page, translate(p.DB, err)
+	}
+
+	var c *cursor
+	if query.Cursor != "" {
+		if c, err = query.decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+		if filtered, err = query.after(filtered, c); err != nil {
+			return nil, err
+		}
+	}
+	backward := c != nil && c.Before
+	ordered := query.order(filtered, backward).Preload("Categories").Limit(query.Limit + 1)
+	if c == nil && query.Page > 1 {
+		ordered = ordered.Offset((query.Page - 1) * query.Limit)
+	}
+	if err := ordered.Find(&page.Items).Error; err != nil {
+		return nil, translate(p.DB, err)
+	}
+	more := len(page.Items) > query.Limit
+	if more {
+		page.Items = page.Items[:query.Limit]
+	}
+	if backward {
+		for i, j := 0, len(page.Items)-1; i < j; i, j = i+1, j-1 {
+			page.Items[i], page.Items[j] = page.Items[j], page.Items[i]
+		}
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+	hasNext := more
+	hasPrev := c != nil || query.Page > 1
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor = query.encodeCursor(page.Items[len(page.Items)-1], false)
+	}
+	if hasPrev {
+		page.PrevCursor = query.encodeCursor(page.Items[0], true)
+	}
+	return page, nil
+}
+
+func (q ProductQuery) sortKey() string {
+	var fields []string
+	for _, field := range q.sortFields() {
+		if field.Desc {
+			fields = append(fields, "-"+field.Field)
+		} else {
+			fields = append(fields, field.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func (q ProductQuery) encodeCursor(product entity.Product, before bool) string {
+	c := cursor{Sort: q.sortKey(), Before: before}
+	for _, field := range q.sortFields() {
+		var value string
+		switch field.Field {
+		case "name":
+			value = product.Name
+		case "price":
+			value = strconv.FormatInt(product.Price.Amount, 10)
+		case "created_at":
+			value = product.CreatedAt.Format(time.RFC3339Nano)
+		case "id":
+			value = product.ID.String()
+		}
+		c.Values = append(c.Values, value)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (q ProductQuery) decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != q.sortKey() || len(c.Values) != len(q.sortFields()) {
+		return nil, fmt.Errorf("%w: it was issued for a different sort", ErrInvalidCursor)
+	}
+	return &c, nil
+}
+
+// after restricts db to the rows strictly after the cursor in the query
+// ordering, or strictly before it for a Before cursor. For fields f1, f2
+// and id that is (f1 > v1) OR (f1 = v1 AND f2 > v2) OR (f1 = v1 AND
+// f2 = v2 AND id > v3), with > flipped for descending fields.
+func (q ProductQuery) after(db *gorm.DB, c *cursor) (*gorm.DB, error) {
+	fields := q.sortFields()
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value, err := cursorValue(field.Field, c.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	var (
+		disjuncts []string
+		args      []interface{}
+	)
+	for i, field := range fields {
+		var conjuncts []string
+		for j := 0; j < i; j++ {
+			conjuncts = append(conjuncts, column(fields[j].Field)+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if field.Desc != c.Before {
+			op = "<"
+		}
+		conjuncts = append(conjuncts, column(field.Field)+" "+op+" ?")
+		args = append(args, values[i])
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+	return db.Where("("+strings.Join(disjuncts, " OR ")+")", args...), nil
+}
+
+func cursorValue(field, value string) (interface{}, error) {
+	switch field {
+	case "price":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return n, nil
+	case "created_at":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newPagedProductDB(t *testing.T, count int) *Product {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	productDB := NewProduct(db, defaultTenant)
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		// Prices repeat so that sorting by price alone has ties.
+		product, _ := entity.NewProduct(fmt.Sprintf("Product %02d", i+1), pkgEntity.Money{Amount: int64(100 * (i%3 + 1)), Currency: "USD"})
+		product.CreatedAt = created.Add(time.Duration(i) * time.Minute)
+		if err := productDB.Create(product); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+	}
+	return productDB
+}
+
+func pageNames(page *ProductPage) []string {
+	var names []string
+	for _, p := range page.Items {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestProduct_FindPageWithCursor(t *testing.T) {
+	productDB := newPagedProductDB(t, 5)
+
+	page, err := productDB.FindPage(ProductQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, []string{"Product 01", "Product 02"}, pageNames(page))
+	assert.NotEmpty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	page, err = productDB.FindPage(ProductQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 03", "Product 04"}, pageNames(page))
+	assert.NotEmpty(t, page.PrevCursor)
+
+	last, err := productDB.FindPage(ProductQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 05"}, pageNames(last))
+	assert.Empty(t, last.NextCursor)
+
+	page, err = productDB.FindPage(ProductQuery{Limit: 2, Cursor: last.PrevCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 03", "Product 04"}, pageNames(page))
+
+	page, err = productDB.FindPage(ProductQuery{Limit: 2, Cursor: page.PrevCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 01", "Product 02"}, pageNames(page))
+	assert.Empty(t, page.PrevCursor)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestProduct_FindPageWithMultiFieldSort(t *testing.T) {
+	productDB := newPagedProductDB(t, 7)
+	query := ProductQuery{Sort: []SortField{{Field: "price", Desc: true}, {Field: "name"}}, Limit: 3}
+
+	var names []string
+	for {
+		page, err := productDB.FindPage(query)
+		assert.NoError(t, err)
+		names = append(names, pageNames(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{
+		"Product 03", "Product 06",
+		"Product 02", "Product 05",
+		"Product 01", "Product 04", "Product 07",
+	}, names)
+}
+
+func TestProduct_FindPageWithOffset(t *testing.T) {
+	productDB := newPagedProductDB(t, 5)
+
+	page, err := productDB.FindPage(ProductQuery{Page: 2, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 03", "Product 04"}, pageNames(page))
+	assert.NotEmpty(t, page.NextCursor)
+	assert.NotEmpty(t, page.PrevCursor)
+
+	page, err = productDB.FindPage(ProductQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 05"}, pageNames(page))
+
+	page, err = productDB.FindPage(ProductQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 5)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestProduct_FindPageWithInvalidCursor(t *testing.T) {
+	productDB := newPagedProductDB(t, 3)
+
+	_, err := productDB.FindPage(ProductQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	page, err := productDB.FindPage(ProductQuery{Limit: 1})
+	assert.NoError(t, err)
+	_, err = productDB.FindPage(ProductQuery{Limit: 1, Cursor: page.NextCursor, Sort: []SortField{{Field: "name"}}})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSort         = errors.New("invalid sort")
+	ErrCurrencyRequired    = errors.New("currency is required to filter by price")
+	ErrInvalidPriceRange   = errors.New("price_min cannot be greater than price_max")
+	ErrInvalidCreatedRange = errors.New("created_after cannot be later than created_before")
+)
+
+// sortableColumns maps the field names accepted from clients to the columns
+// they sort by. Anything else is rejected.
+var sortableColumns = map[string]string{
+	"name":       "name",
+	"price":      "price_amount",
+	"created_at": "created_at",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ProductQuery describes a filtered, sorted and paginated product listing.
+// Zero values mean "no filter".
+type ProductQuery struct {
+	NameContains       string
+	PriceMin           *int64
+	PriceMax           *int64
+	Currency           string
+	CreatedAfter       *time.Time
+	CreatedBefore      *time.Time
+	CategoryID         string
+	IncludeDescendants bool
+	IncludeDeleted     bool
+	OwnerID            string
+	Sort               []SortField
+	Cursor             string
+	Page               int
+	Limit              int
+	// tenantID is set by the repository running the query, see
+	// Product.TenantID.
+	tenantID string
+}
+
+// ParseSort parses a comma separated list of fields, each optionally
+// prefixed with "-" for descending order, e.g. "price,-name". The legacy
+// values "asc" and "desc" sort by creation date.
+func ParseSort(sort string) ([]SortField, error) {
+	switch sort {
+	case "":
+		return nil, nil
+	case "asc":
+		return []SortField{{Field: "created_at"}}, nil
+	case "desc":
+		return []SortField{{Field: "created_at", Desc: true}}, nil
+	}
+	var fields []SortField
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := sortableColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSort, part)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (q ProductQuery) Validate() error {
+	if (q.PriceMin != nil || q.PriceMax != nil) && q.Currency == "" {
+		return ErrCurrencyRequired
+	}
+	if q.PriceMin != nil && q.PriceMax != nil && *q.PriceMin > *q.PriceMax {
+		return ErrInvalidPriceRange
+	}
+	if q.CreatedAfter != nil && q.CreatedBefore != nil && q.CreatedAfter.After(*q.CreatedBefore) {
+		return ErrInvalidCreatedRange
+	}
+	for _, field := range q.Sort {
+		if _, ok := sortableColumns[field.Field]; !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidSort, field.Field)
+		}
+	}
+	return nil
+}
+
+// scope applies the filters and ordering of the query to db, but not its
+// pagination.
+func (q ProductQuery) scope(db *gorm.DB) (*gorm.DB, error) {
+	query, err := q.filter(db)
+	if err != nil {
+		return nil, err
+	}
+	return q.order(query, false), nil
+}
+
+func (q ProductQuery) filter(db *gorm.DB) (*gorm.DB, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	query := db.Scopes(TenantScope(q.tenantID))
+	if q.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if q.OwnerID != "" {
+		query = query.Where("owner_id = ?", q.OwnerID)
+	}
+	if q.NameContains != "" {
+		query = query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(q.NameContains)+"%")
+	}
+	if q.Currency != "" {
+		query = query.Where("price_currency = ?", strings.ToUpper(q.Currency))
+	}
+	if q.PriceMin != nil {
+		query = query.Where("price_amount >= ?", *q.PriceMin)
+	}
+	if q.PriceMax != nil {
+		query = query.Where("price_amount <= ?", *q.PriceMax)
+	}
+	if q.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *q.CreatedAfter)
+	}
+	if q.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *q.CreatedBefore)
+	}
+	if q.CategoryID != "" {
+		categoryDB := &Category{DB: db, TenantID: q.tenantID}
+		if _, err := categoryDB.FindByID(q.CategoryID); err != nil {
+			return nil, err
+		}
+		categoryIDs := []string{q.CategoryID}
+		if q.IncludeDescendants {
+			ids, err := descendantIDs(db, q.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			categoryIDs = ids
+		}
+		linked := db.Table("product_categories").Select("product_id").Where("category_id IN ?", categoryIDs)
+		query = query.Where("id IN (?)", linked)
+	}
+	// A new session lets callers reuse the filtered query, e.g. to count
+	// and then list.
+	return query.Session(&gorm.Session{}), nil
+}
+
+// sortFields returns the effective ordering of the query, which always ends
+// with the ID so that it is total and pages never overlap or skip rows.
+func (q ProductQuery) sortFields() []SortField {
+	sort := q.Sort
+	if len(sort) == 0 {
+		sort = []SortField{{Field: "created_at"}}
+	}
+	return append(append([]SortField{}, sort...), SortField{Field: "id"})
+}
+
+// order sorts by the query fields, or in exactly the opposite order when
+// reverse is set.
+func (q ProductQuery) order(db *gorm.DB, reverse bool) *gorm.DB {
+	for _, field := range q.sortFields() {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column(field.Field)}, Desc: field.Desc != reverse})
+	}
+	return db
+}
+
+func column(field string) string {
+	if field == "id" {
+		return "id"
+	}
+	return sortableColumns[field]
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseSort(t *testing.T) {
+	fields, err := ParseSort("price,-name")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: "price"}, {Field: "name", Desc: true}}, fields)
+
+	fields, err = ParseSort("desc")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: "created_at", Desc: true}}, fields)
+
+	fields, err = ParseSort("")
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = ParseSort("price,password")
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	_, err = ParseSort("name;DROP TABLE products")
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestProductQuery_Validate(t *testing.T) {
+	low, high := int64(10), int64(5)
+	assert.ErrorIs(t, ProductQuery{PriceMin: &low}.Validate(), ErrCurrencyRequired)
+	assert.ErrorIs(t, ProductQuery{PriceMin: &low, PriceMax: &high, Currency: "USD"}.Validate(), ErrInvalidPriceRange)
+	after, before := time.Now(), time.Now().Add(-time.Hour)
+	assert.ErrorIs(t, ProductQuery{CreatedAfter: &after, CreatedBefore: &before}.Validate(), ErrInvalidCreatedRange)
+	assert.ErrorIs(t, ProductQuery{Sort: []SortField{{Field: "password"}}}.Validate(), ErrInvalidSort)
+	assert.NoError(t, ProductQuery{PriceMin: &high, PriceMax: &low, Currency: "USD"}.Validate())
+}
+
+func TestProduct_FindByQuery(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	day := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		name    string
+		price   pkgEntity.Money
+		created time.Time
+	}{
+		{"Blue Mug", pkgEntity.Money{Amount: 1500, Currency: "USD"}, day},
+		{"Red Mug", pkgEntity.Money{Amount: 1500, Currency: "USD"}, day.AddDate(0, 0, 1)},
+		{"Teapot", pkgEntity.Money{Amount: 4000, Currency: "USD"}, day.AddDate(0, 0, 2)},
+		{"Espresso Cup", pkgEntity.Money{Amount: 900, Currency: "EUR"}, day.AddDate(0, 0, 3)},
+	}
+	productDB := NewProduct(db, defaultTenant)
+	owner := pkgEntity.NewID()
+	for i, f := range fixtures {
+		product, _ := entity.NewProduct(f.name, f.price)
+		product.CreatedAt = f.created
+		if i%2 == 0 {
+			product.OwnerID = &owner
+		}
+		assert.NoError(t, productDB.Create(product))
+	}
+	names := func(products []entity.Product) []string {
+		var names []string
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	products, err := productDB.FindByQuery(ProductQuery{NameContains: "mug"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Blue Mug", "Red Mug"}, names(products))
+
+	low, high := int64(1000), int64(2000)
+	products, err = productDB.FindByQuery(ProductQuery{PriceMin: &low, PriceMax: &high, Currency: "usd"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Blue Mug", "Red Mug"}, names(products))
+
+	after, before := day.AddDate(0, 0, 1), day.AddDate(0, 0, 3)
+	products, err = productDB.FindByQuery(ProductQuery{CreatedAfter: &after, CreatedBefore: &before})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Red Mug", "Teapot"}, names(products))
+
+	products, err = productDB.FindByQuery(ProductQuery{Sort: []SortField{{Field: "price", Desc: true}, {Field: "name", Desc: true}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Teapot", "Red Mug", "Blue Mug", "Espresso Cup"}, names(products))
+
+	products, err = productDB.FindByQuery(ProductQuery{Sort: []SortField{{Field: "name"}}, Page: 2, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Red Mug", "Teapot"}, names(products))
+
+	products, err = productDB.FindByQuery(ProductQuery{OwnerID: owner.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Blue Mug", "Teapot"}, names(products))
+
+	_, err = productDB.FindByQuery(ProductQuery{PriceMin: &low})
+	assert.ErrorIs(t, err, ErrCurrencyRequired)
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+	// The matches are first marked with control characters, which HTML
+	// escaping leaves alone, and only turned to tags once the text around
+	// them is escaped.
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+var matchTags = strings.NewReplacer(matchStart, highlightStart, matchEnd, highlightEnd)
+
+var ErrNoSearchIndex = errors.New("the products_fts search index is missing, run the migrations")
+
+var searchTerm = regexp.MustCompile(`[\pL\pN]+`)
+
+type ProductSearch struct {
+	DB  *gorm.DB
+	FTS bool
+	// TenantID confines searches to the products of one organization, see
+	// TenantScope.
+	TenantID string
+}
+
+// NewProductSearch searches the products of the organization in the
+// products_fts index of SQLite databases, which migration 0018 creates
+// along with the triggers keeping it in sync with products. SQLite must be
+// built with FTS5, see the sqlite_fts5 build tag of go-sqlite3. Other
+// databases have no such index: their searches match name prefixes with
+// LIKE, unranked.
+func NewProductSearch(db *gorm.DB, tenantID string) (*ProductSearch, error) {
+	s := &ProductSearch{DB: db, TenantID: tenantID}
+	if db.Dialector.Name() != "sqlite" {
+		return s, nil
+	}
+	if !db.Migrator().HasTable("products_fts") {
+		return nil, ErrNoSearchIndex
+	}
+	s.FTS = true
+	return s, nil
+}
+
+// NewProductSearchUnscoped searches the products of every organization, for
+// the handlers to confine to the organization of each request with
+// ForTenant.
+func NewProductSearchUnscoped(db *gorm.DB) (*ProductSearch, error) {
+	return NewProductSearch(db, allTenants)
+}
+
+// ForTenant returns a copy of the search confined to the organization.
+func (s *ProductSearch) ForTenant(tenantID string) ProductSearchInterface {
+	return &ProductSearch{DB: s.DB, FTS: s.FTS, TenantID: tenantID}
+}
+
+// Search returns the products whose name contains words starting with every
+// term of the query, most relevant first.
+func (s *ProductSearch) Search(query string, page, limit int) ([]entity.ProductSearchResult, error) {
+	terms := searchTerm.FindAllString(strings.ToLower(query), -1)
+	if len(terms) == 0 {
+		return []entity.ProductSearchResult{}, nil
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	var results []entity.ProductSearchResult
+	var err error
+	if s.FTS {
+		results, err = s.searchFTS(terms, page, limit)
+	} else {
+		results, err = s.searchLike(terms, page, limit)
+	}
+	return results, translate(s.DB, err)
+}
+
+func (s *ProductSearch) searchFTS(terms []string, page, limit int) ([]entity.ProductSearchResult, error) {
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + term + `"*`
+	}
+	var rows []struct {
+		ID      string
+		Snippet string
+		Rank    float64
+	}
+	// The index has no tenant, so the products are joined to rank and page
+	// only those of the tenant that are not deleted.
+	err := s.DB.Table("products_fts").
+		Select("products_fts.id, snippet(products_fts, 1, ?, ?, '…', 16) AS snippet, bm25(products_fts) AS rank", matchStart, matchEnd).
+		Joins("JOIN products ON products.id = products_fts.id").
+		Where("products_fts MATCH ? AND products.deleted_at IS NULL", strings.Join(match, " ")).
+		Scopes(TenantScope(s.TenantID)).
+		Order("rank").Limit(limit).Offset((page - 1) * limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	products, err := s.findProducts(ids)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]entity.ProductSearchResult, 0, len(rows))
+	for _, row := range rows {
+		product, ok := products[row.ID]
+		if !ok {
+			continue
+		}
+		// bm25 scores are negative, the best match having the lowest one.
+		results = append(results, entity.ProductSearchResult{Product: product, Score: -row.Rank, Snippet: markup(row.Snippet)})
+	}
+	return results, nil
+}
+
+func (s *ProductSearch) searchLike(terms []string, page, limit int) ([]entity.ProductSearchResult, error) {
+	query := s.DB.Scopes(TenantScope(s.TenantID)).Preload("Categories")
+	for _, term := range terms {
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(name) LIKE ?", term+"%", "% "+term+"%")
+	}
+	var products []entity.Product
+	if err := query.Order("name").Offset((page - 1) * limit).Limit(limit).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	results := make([]entity.ProductSearchResult, 0, len(products))
+	for _, product := range products {
+		results = append(results, entity.ProductSearchResult{
+			Product: product,
+			Score:   float64(len(terms)),
+			Snippet: highlight(product.Name, terms),
+		})
+	}
+	return results, nil
+}
+
+func (s *ProductSearch) findProducts(ids []string) (map[string]entity.Product, error) {
+	var products []entity.Product
+	if len(ids) > 0 {
+		if err := s.DB.Scopes(TenantScope(s.TenantID)).Preload("Categories").Where("id IN ?", ids).Find(&products).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[string]entity.Product, len(products))
+	for _, product := range products {
+		byID[product.ID.String()] = product
+	}
+	return byID, nil
+}
+
+// highlight marks the words of text that start with one of the terms, the
+// same way the FTS5 snippet function does.
+func highlight(text string, terms []string) string {
+	return markup(searchTerm.ReplaceAllStringFunc(text, func(word string) string {
+		for _, term := range terms {
+			if strings.HasPrefix(strings.ToLower(word), term) {
+				return matchStart + word + matchEnd
+			}
+		}
+		return word
+	}))
+}
+
+// markup returns the HTML of a snippet: its text escaped, as product names
+// are user input, and its matches in mark tags.
+func markup(snippet string) string {
+	return matchTags.Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newProductSearch(t *testing.T, names ...string) (*ProductSearch, []*entity.Product) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	createSearchIndex(t, db)
+	var products []*entity.Product
+	for _, name := range names {
+		product, _ := entity.NewProduct(name, pkgEntity.Money{Amount: 10, Currency: "USD"})
+		if err := NewProduct(db, defaultTenant).Create(product); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+		products = append(products, product)
+	}
+	search, err := NewProductSearch(db, defaultTenant)
+	if err != nil {
+		t.Fatalf("could not create search index: %v", err)
+	}
+	return search, products
+}
+
+// createSearchIndex runs the migration creating the FTS5 index, which the
+// repositories tested with AutoMigrate cannot create. go-sqlite3 only has
+// FTS5 with the sqlite_fts5 build tag the Makefile sets: without it the
+// test is skipped.
+func createSearchIndex(t *testing.T, db *gorm.DB) {
+	script, err := os.ReadFile("migrations/sql/0018_create_products_fts.sqlite.up.sql")
+	if err != nil {
+		t.Fatalf("could not read the search index migration: %v", err)
+	}
+	// go-sqlite3 runs every statement of the script.
+	err = db.Exec(string(script)).Error
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skipf("SQLite lacks FTS5, run the tests with -tags sqlite_fts5: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("could not create the search index: %v", err)
+	}
+}
+
+func TestNewProductSearch_RequiresIndex(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	_, err = NewProductSearch(db, defaultTenant)
+	assert.ErrorIs(t, err, ErrNoSearchIndex)
+}
+
+func TestProductSearch_UsesFTS5(t *testing.T) {
+	search, _ := newProductSearch(t, "Phone", "Phone Case Phone Cover", "Laptop")
+	assert.True(t, search.FTS)
+
+	results, err := search.Search("phone", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "Phone", results[0].Product.Name)
+	assert.GreaterOrEqual(t, results[0].Score, results[1].Score)
+
+	again, err := NewProductSearch(search.DB, defaultTenant)
+	assert.NoError(t, err)
+	results, err = again.Search("laptop", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+// Databases other than SQLite search with LIKE.
+func TestProductSearch_SearchLike(t *testing.T) {
+	search, _ := newProductSearch(t, "Phone Case", "Smartphone", "Blue Phone Charger")
+	like := &ProductSearch{DB: search.DB, TenantID: defaultTenant}
+
+	results, err := like.Search("pho", 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "Blue Phone Charger", results[0].Product.Name)
+		assert.Equal(t, "Blue <mark>Phone</mark> Charger", results[0].Snippet)
+	}
+}
+
+func TestProductSearch_EscapesSnippets(t *testing.T) {
+	search, _ := newProductSearch(t, `<img src=x onerror="alert(1)"> Phone & Co`)
+	like := &ProductSearch{DB: search.DB, TenantID: defaultTenant}
+	want := "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>Phone</mark> &amp; Co"
+
+	for _, s := range []*ProductSearch{search, like} {
+		results, err := s.Search("phone", 1, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, want, results[0].Snippet)
+		}
+	}
+}
+
+func TestProductSearch_Search(t *testing.T) {
+	search, _ := newProductSearch(t, "Phone Case", "Smartphone", "Laptop Stand", "Blue Phone Charger")
+
+	results, err := search.Search("pho", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	for _, result := range results {
+		assert.Contains(t, result.Snippet, "<mark>Phone</mark>")
+		assert.Greater(t, result.Score, 0.0)
+	}
+
+	results, err = search.Search("phone CHAR", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "Blue Phone Charger", results[0].Product.Name)
+
+	results, err = search.Search("  \"*  ", 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestProductSearch_StaysInSync(t *testing.T) {
+	search, products := newProductSearch(t, "Phone Case")
+	productDB := NewProduct(search.DB, defaultTenant)
+
+	created, _ := entity.NewProduct("Phone Holder", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, productDB.Create(created))
+	results, err := search.Search("holder", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	assert.NoError(t, productDB.Update(products[0].ID.String(), 0, map[string]interface{}{"name": "Tablet Case"}))
+	results, err = search.Search("phone", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	results, err = search.Search("tablet", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	assert.NoError(t, productDB.Delete(created.ID.String(), 0))
+	results, err = search.Search("phone", 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, "<mark>Phone</mark> Case", highlight("Phone Case", []string{"pho"}))
+	assert.Equal(t, "Smartphone", highlight("Smartphone", []string{"pho"}))
+}
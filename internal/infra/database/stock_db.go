@@ -0,0 +1,106 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Stock struct {
+	DB *gorm.DB
+	// TenantID confines the repository to the stock of the products of one
+	// organization, see TenantScope.
+	TenantID string
+}
+
+// NewStock returns the repository of the stock of the products of the
+// organization.
+func NewStock(db *gorm.DB, tenantID string) *Stock {
+	return &Stock{DB: db, TenantID: tenantID}
+}
+
+// NewStockUnscoped returns a repository of the stock of the products of
+// every organization, for the handlers to confine to the organization of
+// each request with ForTenant.
+func NewStockUnscoped(db *gorm.DB) *Stock {
+	return &Stock{DB: db, TenantID: allTenants}
+}
+
+// ForTenant returns a copy of the repository confined to the organization.
+func (s *Stock) ForTenant(tenantID string) StockInterface {
+	return &Stock{DB: s.DB, TenantID: tenantID}
+}
+
+// product returns the product repository the stock of a product is looked
+// up through, so that it is only found in the organization of s.
+func (s *Stock) product(db *gorm.DB) *Product {
+	return &Product{DB: db, TenantID: s.TenantID}
+}
+
+// Record appends the movement to the ledger and applies it to the on-hand
+// level in the same transaction, refusing movements that would take the
+// level below zero.
+func (s *Stock) Record(movement *entity.StockMovement) (*entity.StockLevel, error) {
+	var level entity.StockLevel
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		productID := movement.ProductID.String()
+		if _, err := s.product(tx).FindByID(productID); err != nil {
+			return err
+		}
+		initial := entity.StockLevel{ProductID: movement.ProductID, UpdatedAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&initial).Error; err != nil {
+			return err
+		}
+		// The guard is part of the UPDATE itself so that concurrent movements
+		// cannot both pass the check against a stale level.
+		result := tx.Model(&entity.StockLevel{}).
+			Where("product_id = ? AND on_hand + ? >= 0", productID, movement.Quantity).
+			Updates(map[string]interface{}{
+				"on_hand":    gorm.Expr("on_hand + ?", movement.Quantity),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInsufficientStock
+		}
+		if err := tx.Create(movement).Error; err != nil {
+			return err
+		}
+		return tx.Where("product_id = ?", productID).First(&level).Error
+	})
+	if err != nil {
+		return nil, translate(s.DB, err)
+	}
+	return &level, nil
+}
+
+// FindLevel returns the on-hand level of the product, which is zero until
+// its first movement is recorded.
+func (s *Stock) FindLevel(productID string) (*entity.StockLevel, error) {
+	product, err := s.product(s.DB).FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	level := entity.StockLevel{ProductID: product.ID}
+	if err := s.DB.Where("product_id = ?", productID).Limit(1).Find(&level).Error; err != nil {
+		return nil, translate(s.DB, err)
+	}
+	return &level, nil
+}
+
+func (s *Stock) FindMovements(productID string, page, limit int) ([]entity.StockMovement, error) {
+	if _, err := s.product(s.DB).FindByID(productID); err != nil {
+		return nil, err
+	}
+	var movements []entity.StockMovement
+	query := s.DB.Where("product_id = ?", productID).Order("created_at asc")
+	if page != 0 && limit != 0 {
+		query = query.Offset((page - 1) * limit).Limit(limit)
+	}
+	err := query.Find(&movements).Error
+	return movements, translate(s.DB, err)
+}
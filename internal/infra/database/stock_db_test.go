@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newStockDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}, &entity.StockLevel{}, &entity.StockMovement{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return db
+}
+
+func TestStock_Record(t *testing.T) {
+	db := newStockDB(t)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, NewProduct(db, defaultTenant).Create(product))
+	stockDB := NewStock(db, defaultTenant)
+
+	receipt, _ := entity.NewStockMovement(product.ID, entity.MovementReceipt, 10, "")
+	level, err := stockDB.Record(receipt)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, level.OnHand)
+
+	sale, _ := entity.NewStockMovement(product.ID, entity.MovementSale, 4, "")
+	level, err = stockDB.Record(sale)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, level.OnHand)
+
+	// A movement that would take the level below zero is rejected and
+	// leaves neither the level nor the ledger changed.
+	oversell, _ := entity.NewStockMovement(product.ID, entity.MovementSale, 7, "")
+	_, err = stockDB.Record(oversell)
+	assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+
+	level, err = stockDB.FindLevel(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, level.OnHand)
+	movements, err := stockDB.FindMovements(product.ID.String(), 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, movements, 2)
+	assert.Equal(t, entity.MovementReceipt, movements[0].Type)
+	assert.Equal(t, -4, movements[1].Quantity)
+}
+
+func TestStock_RecordWhenProductDoesNotExist(t *testing.T) {
+	db := newStockDB(t)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	receipt, _ := entity.NewStockMovement(product.ID, entity.MovementReceipt, 10, "")
+	_, err := NewStock(db, defaultTenant).Record(receipt)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestStock_FindLevel(t *testing.T) {
+	db := newStockDB(t)
+	product, _ := entity.NewProduct("Product 1", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, NewProduct(db, defaultTenant).Create(product))
+
+	level, err := NewStock(db, defaultTenant).FindLevel(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, product.ID, level.ProductID)
+	assert.Equal(t, 0, level.OnHand)
+
+	_, err = NewStock(db, defaultTenant).FindLevel("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// ErrNoTenant is returned by the repositories confined to no organization,
+// so that forgetting to confine one fails instead of reading across
+// organizations.
+var ErrNoTenant = errors.New("repository is confined to no organization")
+
+// allTenants is the tenant of the repositories working across
+// organizations, such as those of the login, the migrations and the purge
+// jobs, see NewProductUnscoped. Organization IDs are UUIDs, so no request
+// can claim it.
+const allTenants = "*"
+
+// TenantScope restricts a query on a table with an organization_id column
+// to the rows of the tenant. The query fails with ErrNoTenant if the tenant
+// is empty, and is left unrestricted only for allTenants.
+func TenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch tenantID {
+		case "":
+			db.AddError(ErrNoTenant)
+			return db
+		case allTenants:
+			return db
+		}
+		return db.Where("organization_id = ?", tenantID)
+	}
+}
+
+// organizationOf returns the organization the rows created by a repository
+// of the tenant belong to, nil for repositories working across
+// organizations, which keep the organization of the rows.
+func organizationOf(tenantID string) (*pkgEntity.ID, error) {
+	switch tenantID {
+	case "":
+		return nil, ErrNoTenant
+	case allTenants:
+		return nil, nil
+	}
+	id, err := pkgEntity.ParseID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// defaultTenant is the organization the entities are created in unless
+// they are given another.
+var defaultTenant = entity.DefaultOrganizationID.String()
+
+func newTenantDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	err = db.AutoMigrate(&entity.Organization{}, &entity.User{}, &entity.Category{}, &entity.Product{}, &entity.StockLevel{}, &entity.StockMovement{})
+	if err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return db
+}
+
+func TestTenant_ProductIsolation(t *testing.T) {
+	db := newTenantDB(t)
+	tenantA, tenantB := pkgEntity.NewID().String(), pkgEntity.NewID().String()
+	productsA := NewProduct(db, tenantA)
+	productsB := NewProduct(db, tenantB)
+	productA, _ := entity.NewProduct("Product A", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, productsA.Create(productA))
+	assert.Equal(t, tenantA, productA.OrganizationID.String())
+	productB, _ := entity.NewProduct("Product B", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, productsB.Create(productB))
+	idA := productA.ID.String()
+
+	_, err := productsB.FindByID(idA)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = productsB.FindByIDUnscoped(idA)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, productsB.Update(idA, 0, map[string]interface{}{"name": "Stolen"}), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, productsB.SetCategories(idA, nil), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, productsB.Delete(idA, 0), gorm.ErrRecordNotFound)
+	_, err = productsB.Restore(idA)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	page, err := productsB.FindPage(ProductQuery{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "Product B", page.Items[0].Name)
+	products, err := productsA.FindAll(1, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Product A", products[0].Name)
+
+	assert.NoError(t, productsA.Delete(idA, 0))
+	purged, err := productsB.Purge(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	_, err = productsA.Restore(idA)
+	assert.NoError(t, err)
+
+	// An unconfined repository, as used by the purge job, sees every tenant.
+	products, err = NewProductUnscoped(db).FindAll(1, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+}
+
+func TestTenant_CategoryIsolation(t *testing.T) {
+	db := newTenantDB(t)
+	tenantA, tenantB := pkgEntity.NewID().String(), pkgEntity.NewID().String()
+	categoriesA := NewCategory(db, tenantA)
+	categoriesB := NewCategory(db, tenantB)
+	categoryA, _ := entity.NewCategory("Books", nil)
+	assert.NoError(t, categoriesA.Create(categoryA))
+	assert.Equal(t, tenantA, categoryA.OrganizationID.String())
+	categoryB, _ := entity.NewCategory("Music", nil)
+	assert.NoError(t, categoriesB.Create(categoryB))
+	idA := categoryA.ID.String()
+
+	_, err := categoriesB.FindByID(idA)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = categoriesB.FindDescendantIDs(idA)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	renamed := *categoryA
+	renamed.Name = "Stolen"
+	assert.ErrorIs(t, categoriesB.Update(&renamed), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, categoriesB.Delete(idA), gorm.ErrRecordNotFound)
+	child, _ := entity.NewCategory("Novels", &categoryA.ID)
+	assert.ErrorIs(t, categoriesB.Create(child), ErrCategoryNotFound)
+	categoryB.ParentID = &categoryA.ID
+	assert.ErrorIs(t, categoriesB.Update(categoryB), ErrCategoryNotFound)
+	categories, err := categoriesB.FindAll()
+	assert.NoError(t, err)
+	assert.Len(t, categories, 1)
+	assert.Equal(t, "Music", categories[0].Name)
+
+	// Products can neither be linked to nor filtered by the categories of
+	// another tenant.
+	productsB := NewProduct(db, tenantB)
+	product, _ := entity.NewProduct("Product B", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	product.Categories = []entity.Category{*categoryA}
+	assert.ErrorIs(t, productsB.Create(product), ErrCategoryNotFound)
+	product.Categories = nil
+	assert.NoError(t, productsB.Create(product))
+	assert.ErrorIs(t, productsB.SetCategories(product.ID.String(), []string{idA}), ErrCategoryNotFound)
+	_, err = productsB.FindPage(ProductQuery{CategoryID: idA})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	found, err := categoriesA.FindByID(idA)
+	assert.NoError(t, err)
+	assert.Equal(t, "Books", found.Name)
+}
+
+func TestTenant_UserIsolation(t *testing.T) {
+	db := newTenantDB(t)
+	tenantA, tenantB := pkgEntity.NewID().String(), pkgEntity.NewID().String()
+	usersA := NewUser(db, tenantA)
+	usersB := NewUser(db, tenantB)
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	assert.NoError(t, usersA.Create(user))
+	assert.Equal(t, tenantA, user.OrganizationID.String())
+
+	_, err := usersB.FindByID(user.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = usersB.FindByEmail(user.Email)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, usersB.UpdateRole(user.ID.String(), entity.RoleAdmin), gorm.ErrRecordNotFound)
+
+	userFound, err := usersA.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RoleViewer, userFound.Role)
+	// The login finds users whatever their organization.
+	_, err = NewUserUnscoped(db).FindByEmail(user.Email)
+	assert.NoError(t, err)
+}
+
+func TestTenant_StockIsolation(t *testing.T) {
+	db := newTenantDB(t)
+	tenantA, tenantB := pkgEntity.NewID().String(), pkgEntity.NewID().String()
+	product, _ := entity.NewProduct("Product A", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, NewProduct(db, tenantA).Create(product))
+	stockB := NewStock(db, tenantB)
+
+	receipt, _ := entity.NewStockMovement(product.ID, entity.MovementReceipt, 5, "")
+	_, err := stockB.Record(receipt)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = stockB.FindLevel(product.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = stockB.FindMovements(product.ID.String(), 1, 10)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	level, err := NewStock(db, tenantA).Record(receipt)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, level.OnHand)
+}
+
+func TestTenant_SearchIsolation(t *testing.T) {
+	db := newTenantDB(t)
+	tenantA, tenantB := pkgEntity.NewID().String(), pkgEntity.NewID().String()
+	product, _ := entity.NewProduct("Blue Mug", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	createSearchIndex(t, db)
+	assert.NoError(t, NewProduct(db, tenantA).Create(product))
+	search, err := NewProductSearchUnscoped(db)
+	assert.NoError(t, err)
+
+	results, err := search.ForTenant(tenantB).Search("mug", 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = search.ForTenant(tenantA).Search("mug", 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+// Repositories confined to no organization fail rather than read across
+// organizations.
+func TestTenant_NoTenant(t *testing.T) {
+	db := newTenantDB(t)
+	product, _ := entity.NewProduct("Product A", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.NoError(t, NewProduct(db, defaultTenant).Create(product))
+	products := NewProduct(db, "")
+
+	_, err := products.FindAll(1, 10, "asc")
+	assert.ErrorIs(t, err, ErrNoTenant)
+	_, err = products.FindByID(product.ID.String())
+	assert.ErrorIs(t, err, ErrNoTenant)
+	_, err = products.FindPage(ProductQuery{})
+	assert.ErrorIs(t, err, ErrNoTenant)
+	assert.ErrorIs(t, products.Update(product.ID.String(), 0, map[string]interface{}{"name": "Stolen"}), ErrNoTenant)
+	other, _ := entity.NewProduct("Product B", pkgEntity.Money{Amount: 10, Currency: "USD"})
+	assert.ErrorIs(t, products.Create(other), ErrNoTenant)
+	_, err = (&Product{DB: db}).FindByID(product.ID.String())
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	_, err = NewUser(db, "").FindByEmail("j@j.com")
+	assert.ErrorIs(t, err, ErrNoTenant)
+	_, err = NewCategory(db, "").FindAll()
+	assert.ErrorIs(t, err, ErrNoTenant)
+	_, err = NewStock(db, "").FindLevel(product.ID.String())
+	assert.ErrorIs(t, err, ErrNoTenant)
+	_, err = (&ProductSearch{DB: db}).Search("product", 1, 10)
+	assert.ErrorIs(t, err, ErrNoTenant)
+}
+
+func TestOrganization_Create(t *testing.T) {
+	db := newTenantDB(t)
+	organization, _ := entity.NewOrganization("Retail")
+	admin, _ := entity.NewUser("Ann", "ann@retail.com", "s3cure-pass")
+	organizationDB := NewOrganization(db)
+	assert.NoError(t, organizationDB.Create(organization, admin))
+
+	organizationFound, err := organizationDB.FindByID(organization.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Retail", organizationFound.Name)
+	adminFound, err := NewUser(db, organization.ID.String()).FindByID(admin.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RoleAdmin, adminFound.Role)
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Token struct {
+	DB *gorm.DB
+}
+
+func NewToken(db *gorm.DB) *Token {
+	return &Token{DB: db}
+}
+
+func (t *Token) CreateRefreshToken(token *entity.RefreshToken) error {
+	return translate(t.DB, t.DB.Create(token).Error)
+}
+
+// FindRefreshToken finds a refresh token by the hash of its value, whether
+// or not it was revoked.
+func (t *Token) FindRefreshToken(tokenHash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	if err := t.DB.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translate(t.DB, err)
+	}
+	return &token, nil
+}
+
+// Rotate revokes the current refresh token and stores next in its place.
+// Only one of several concurrent rotations of the same token succeeds, the
+// others fail with entity.ErrRefreshTokenReused.
+func (t *Token) Rotate(current, next *entity.RefreshToken) error {
+	return translate(t.DB, t.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrRefreshTokenReused
+		}
+		current.RevokedAt = &now
+		return tx.Create(next).Error
+	}))
+}
+
+// RevokeFamily revokes every refresh token descending from the same login.
+func (t *Token) RevokeFamily(familyID string) error {
+	err := t.DB.Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	return translate(t.DB, err)
+}
+
+// RevokeAccessToken denies the access token with the jti until expiresAt.
+func (t *Token) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	revoked := entity.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	return translate(t.DB, t.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error)
+}
+
+func (t *Token) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := t.DB.Model(&entity.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, translate(t.DB, err)
+	}
+	return count > 0, nil
+}
+
+// RevokeUser revokes the refresh tokens of every login of the user but the
+// one of keepFamilyID, which may be empty to revoke them all.
+func (t *Token) RevokeUser(userID, keepFamilyID string) error {
+	return translate(t.DB, revokeUser(t.DB, userID, keepFamilyID))
+}
+
+func revokeUser(tx *gorm.DB, userID, keepFamilyID string) error {
+	query := tx.Model(&entity.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepFamilyID != "" {
+		query = query.Where("family_id <> ?", keepFamilyID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
+
+// CreatePasswordResetToken stores a new reset token of a user and uses up
+// the ones issued before, so that only the latest mailed token works.
+func (t *Token) CreatePasswordResetToken(token *entity.PasswordResetToken) error {
+	return translate(t.DB, t.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entity.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	}))
+}
+
+// FindPasswordResetToken finds a reset token by the hash of its value,
+// whether or not it was used.
+func (t *Token) FindPasswordResetToken(tokenHash string) (*entity.PasswordResetToken, error) {
+	var token entity.PasswordResetToken
+	if err := t.DB.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translate(t.DB, err)
+	}
+	return &token, nil
+}
+
+// ResetPassword uses the reset token to replace the password of its user
+// with the hash. Every other reset token of the user is used up and every
+// login of the user revoked along, as whoever knew the old password may
+// have logged in with it. Only one of several concurrent uses of the same
+// token succeeds, the others fail with entity.ErrInvalidResetToken.
+func (t *Token) ResetPassword(token *entity.PasswordResetToken, passwordHash string) error {
+	return translate(t.DB, t.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInvalidResetToken
+		}
+		token.UsedAt = &now
+		err := tx.Model(&entity.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+		result = tx.Model(&entity.User{}).Where("id = ?", token.UserID).Update("password", passwordHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return revokeUser(tx, token.UserID.String(), "")
+	}))
+}
+
+// Purge removes the refresh tokens, denied access tokens and password reset
+// tokens that expired before the given time, as they are rejected anyway.
+func (t *Token) Purge(expiredBefore time.Time) (int64, error) {
+	var purged int64
+	err := t.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("expires_at < ?", expiredBefore).Delete(&entity.RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged += result.RowsAffected
+		result = tx.Where("expires_at < ?", expiredBefore).Delete(&entity.RevokedToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged += result.RowsAffected
+		result = tx.Where("expires_at < ?", expiredBefore).Delete(&entity.PasswordResetToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged += result.RowsAffected
+		return nil
+	})
+	return purged, translate(t.DB, err)
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTokenDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.RefreshToken{}, &entity.RevokedToken{}, &entity.PasswordResetToken{}, &entity.User{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	return db
+}
+
+func TestToken_Rotate(t *testing.T) {
+	tokenDB := NewToken(newTokenDB(t))
+	userID := pkgEntity.NewID()
+	current, plain, _ := entity.NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(current))
+
+	found, err := tokenDB.FindRefreshToken(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, current.ID, found.ID)
+	assert.NoError(t, found.Validate())
+
+	next, nextPlain, _ := entity.NewRefreshToken(userID, &found.FamilyID, time.Hour)
+	assert.NoError(t, tokenDB.Rotate(found, next))
+	found, err = tokenDB.FindRefreshToken(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, found.Validate(), entity.ErrRefreshTokenReused)
+
+	// A concurrent rotation of the same token loses.
+	other, _, _ := entity.NewRefreshToken(userID, &current.FamilyID, time.Hour)
+	assert.ErrorIs(t, tokenDB.Rotate(current, other), entity.ErrRefreshTokenReused)
+
+	found, err = tokenDB.FindRefreshToken(entity.HashToken(nextPlain))
+	assert.NoError(t, err)
+	assert.NoError(t, found.Validate())
+}
+
+func TestToken_RevokeFamily(t *testing.T) {
+	tokenDB := NewToken(newTokenDB(t))
+	userID := pkgEntity.NewID()
+	first, _, _ := entity.NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(first))
+	second, secondPlain, _ := entity.NewRefreshToken(userID, &first.FamilyID, time.Hour)
+	assert.NoError(t, tokenDB.Rotate(first, second))
+	unrelated, unrelatedPlain, _ := entity.NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(unrelated))
+
+	assert.NoError(t, tokenDB.RevokeFamily(first.FamilyID.String()))
+	found, err := tokenDB.FindRefreshToken(entity.HashToken(secondPlain))
+	assert.NoError(t, err)
+	assert.Error(t, found.Validate())
+	found, err = tokenDB.FindRefreshToken(entity.HashToken(unrelatedPlain))
+	assert.NoError(t, err)
+	assert.NoError(t, found.Validate())
+}
+
+func TestToken_RevokeUser(t *testing.T) {
+	tokenDB := NewToken(newTokenDB(t))
+	userID := pkgEntity.NewID()
+	kept, keptPlain, _ := entity.NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(kept))
+	other, otherPlain, _ := entity.NewRefreshToken(userID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(other))
+	unrelated, unrelatedPlain, _ := entity.NewRefreshToken(pkgEntity.NewID(), nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(unrelated))
+
+	assert.NoError(t, tokenDB.RevokeUser(userID.String(), kept.FamilyID.String()))
+	for plain, valid := range map[string]bool{keptPlain: true, otherPlain: false, unrelatedPlain: true} {
+		found, err := tokenDB.FindRefreshToken(entity.HashToken(plain))
+		assert.NoError(t, err)
+		assert.Equal(t, valid, found.Validate() == nil)
+	}
+
+	assert.NoError(t, tokenDB.RevokeUser(userID.String(), ""))
+	found, _ := tokenDB.FindRefreshToken(entity.HashToken(keptPlain))
+	assert.Error(t, found.Validate())
+}
+
+func TestToken_ResetPassword(t *testing.T) {
+	db := newTokenDB(t)
+	tokenDB := NewToken(db)
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	assert.NoError(t, NewUser(db, defaultTenant).Create(user))
+	session, sessionPlain, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(session))
+	stale, stalePlain, _ := entity.NewPasswordResetToken(user.ID, time.Hour)
+	assert.NoError(t, tokenDB.CreatePasswordResetToken(stale))
+	token, plain, _ := entity.NewPasswordResetToken(user.ID, time.Hour)
+	assert.NoError(t, tokenDB.CreatePasswordResetToken(token))
+
+	// Issuing a token voids the ones issued before.
+	found, err := tokenDB.FindPasswordResetToken(entity.HashToken(stalePlain))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, found.Validate(), entity.ErrInvalidResetToken)
+
+	found, err = tokenDB.FindPasswordResetToken(entity.HashToken(plain))
+	assert.NoError(t, err)
+	assert.NoError(t, found.Validate())
+	assert.NoError(t, user.SetPassword("an0ther-pass"))
+	assert.NoError(t, tokenDB.ResetPassword(found, user.Password))
+
+	userFound, err := NewUser(db, defaultTenant).FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.NoError(t, userFound.ComparePassword("an0ther-pass"))
+	refreshToken, _ := tokenDB.FindRefreshToken(entity.HashToken(sessionPlain))
+	assert.ErrorIs(t, refreshToken.Validate(), entity.ErrRefreshTokenReused)
+	for _, plain := range []string{plain, stalePlain} {
+		found, err := tokenDB.FindPasswordResetToken(entity.HashToken(plain))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, found.Validate(), entity.ErrInvalidResetToken)
+	}
+
+	// A concurrent use of the same token loses.
+	assert.ErrorIs(t, tokenDB.ResetPassword(token, user.Password), entity.ErrInvalidResetToken)
+}
+
+func TestToken_RevokeAccessToken(t *testing.T) {
+	tokenDB := NewToken(newTokenDB(t))
+	jti := pkgEntity.NewID().String()
+	revoked, err := tokenDB.IsAccessTokenRevoked(jti)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, tokenDB.RevokeAccessToken(jti, time.Now().Add(time.Hour)))
+	assert.NoError(t, tokenDB.RevokeAccessToken(jti, time.Now().Add(time.Hour)))
+	revoked, err = tokenDB.IsAccessTokenRevoked(jti)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestToken_Purge(t *testing.T) {
+	tokenDB := NewToken(newTokenDB(t))
+	expired, _, _ := entity.NewRefreshToken(pkgEntity.NewID(), nil, -time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(expired))
+	valid, validPlain, _ := entity.NewRefreshToken(pkgEntity.NewID(), nil, time.Hour)
+	assert.NoError(t, tokenDB.CreateRefreshToken(valid))
+	assert.NoError(t, tokenDB.RevokeAccessToken("expired", time.Now().Add(-time.Hour)))
+	assert.NoError(t, tokenDB.RevokeAccessToken("valid", time.Now().Add(time.Hour)))
+	expiredReset, _, _ := entity.NewPasswordResetToken(pkgEntity.NewID(), -time.Hour)
+	assert.NoError(t, tokenDB.CreatePasswordResetToken(expiredReset))
+
+	purged, err := tokenDB.Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	_, err = tokenDB.FindRefreshToken(entity.HashToken(validPlain))
+	assert.NoError(t, err)
+	revoked, _ := tokenDB.IsAccessTokenRevoked("valid")
+	assert.True(t, revoked)
+}
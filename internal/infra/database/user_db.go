@@ -1,28 +1,138 @@
 package database
 
 import (
+	"time"
+
 	"github.com/ThalesLoreto/product-api/internal/entity"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	DB *gorm.DB
+	// TenantID confines the repository to the members of one organization,
+	// see TenantScope.
+	TenantID string
+}
+
+// NewUser returns the repository of the members of the organization.
+func NewUser(db *gorm.DB, tenantID string) *User {
+	return &User{
+		DB:       db,
+		TenantID: tenantID,
+	}
 }
 
-func NewUser(db *gorm.DB) *User {
+// NewUserUnscoped returns a repository of the users of every organization,
+// for the login and the other lookups of users by their credentials, which
+// come before any organization is known.
+func NewUserUnscoped(db *gorm.DB) *User {
 	return &User{
-		DB: db,
+		DB:       db,
+		TenantID: allTenants,
 	}
 }
 
+// ForTenant returns a copy of the repository confined to the organization.
+func (u *User) ForTenant(tenantID string) UserInterface {
+	return &User{DB: u.DB, TenantID: tenantID}
+}
+
+func (u *User) scoped() *gorm.DB {
+	return u.DB.Scopes(TenantScope(u.TenantID))
+}
+
+// Create stores the user, as a member of the organization of the
+// repository if it is confined to one.
 func (u *User) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+	organizationID, err := organizationOf(u.TenantID)
+	if err != nil {
+		return err
+	}
+	if organizationID != nil {
+		user.OrganizationID = *organizationID
+	}
+	return translate(u.DB, u.DB.Create(user).Error)
 }
 
+// FindByEmail finds the user by email, whatever its case, see
+// entity.NormalizeEmail.
 func (u *User) FindByEmail(email string) (*entity.User, error) {
 	var user entity.User
-	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, err
+	if err := u.scoped().Where("email = ?", entity.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, translate(u.DB, err)
 	}
 	return &user, nil
 }
+
+func (u *User) FindByID(id string) (*entity.User, error) {
+	var user entity.User
+	if err := u.scoped().Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translate(u.DB, err)
+	}
+	return &user, nil
+}
+
+func (u *User) UpdateRole(id string, role entity.Role) error {
+	result := u.scoped().Model(&entity.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return translate(u.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return translate(u.DB, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Update saves the name and email of the user, along with whether the email
+// is verified, the password and role having their own way of being changed.
+func (u *User) Update(user *entity.User) error {
+	result := u.scoped().Model(&entity.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"name":              user.Name,
+		"email":             user.Email,
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+	if result.Error != nil {
+		return translate(u.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return translate(u.DB, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (u *User) UpdatePassword(id, passwordHash string) error {
+	result := u.scoped().Model(&entity.User{}).Where("id = ?", id).Update("password", passwordHash)
+	if result.Error != nil {
+		return translate(u.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return translate(u.DB, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// VerifyEmail records that the user proved to own the email.
+func (u *User) VerifyEmail(id string) error {
+	result := u.scoped().Model(&entity.User{}).Where("id = ?", id).Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		return translate(u.DB, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return translate(u.DB, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// MarkVerificationSent records that a verification mail is sent to the
+// user now, unless one was sent after notBefore, and reports whether it
+// was recorded. Only one of several concurrent calls records it, so that
+// resends cannot be used to flood the user.
+func (u *User) MarkVerificationSent(id string, notBefore time.Time) (bool, error) {
+	result := u.scoped().Model(&entity.User{}).
+		Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at < ?)", id, notBefore).
+		Update("verification_sent_at", time.Now())
+	if result.Error != nil {
+		return false, translate(u.DB, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
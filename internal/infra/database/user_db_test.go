@@ -2,8 +2,10 @@ package database
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -17,8 +19,8 @@ func TestUser_Create(t *testing.T) {
 	if err := db.AutoMigrate(&entity.User{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	user, _ := entity.NewUser("John", "j@j.com", "123456")
-	userDB := NewUser(db)
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
 	if err := userDB.Create(user); err != nil {
 		t.Errorf("could not create user: %v", err)
 	}
@@ -41,8 +43,8 @@ func TestUser_FindByEmail(t *testing.T) {
 	if err := db.AutoMigrate(&entity.User{}); err != nil {
 		t.Fatalf("could not migrate db: %v", err)
 	}
-	user, _ := entity.NewUser("John", "j@j.com", "123456")
-	userDB := NewUser(db)
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
 	if err := userDB.Create(user); err != nil {
 		t.Errorf("could not create user: %v", err)
 	}
@@ -54,3 +56,122 @@ func TestUser_FindByEmail(t *testing.T) {
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotNil(t, userFound.Password)
 }
+
+func TestUser_UpdateRole(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
+	if err := userDB.Create(user); err != nil {
+		t.Errorf("could not create user: %v", err)
+	}
+
+	assert.NoError(t, userDB.UpdateRole(user.ID.String(), entity.RoleEditor))
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleEditor, userFound.Role)
+
+	err = userDB.UpdateRole("00000000-0000-0000-0000-000000000000", entity.RoleEditor)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUser_Update(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	other, _ := entity.NewUser("Jane", "jane@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
+	for _, u := range []*entity.User{user, other} {
+		if err := userDB.Create(u); err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+	}
+
+	user.Name = "Johnny"
+	user.Email = "johnny@j.com"
+	user.Role = entity.RoleAdmin
+	assert.NoError(t, userDB.Update(user))
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Johnny", userFound.Name)
+	assert.Equal(t, "johnny@j.com", userFound.Email)
+	assert.Equal(t, entity.RoleViewer, userFound.Role)
+
+	user.Email = other.Email
+	assert.ErrorIs(t, userDB.Update(user), ErrConflict)
+
+	user.ID = pkgEntity.NewID()
+	assert.ErrorIs(t, userDB.Update(user), ErrNotFound)
+}
+
+func TestUser_UpdatePassword(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
+	if err := userDB.Create(user); err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	assert.NoError(t, user.SetPassword("an0ther-pass"))
+	assert.NoError(t, userDB.UpdatePassword(user.ID.String(), user.Password))
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.NoError(t, userFound.ComparePassword("an0ther-pass"))
+
+	err = userDB.UpdatePassword(pkgEntity.NewID().String(), user.Password)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUser_VerifyEmail(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("could not connect to db: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}); err != nil {
+		t.Fatalf("could not migrate db: %v", err)
+	}
+	user, _ := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	userDB := NewUser(db, defaultTenant)
+	if err := userDB.Create(user); err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	sent, err := userDB.MarkVerificationSent(user.ID.String(), time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, sent)
+	sent, err = userDB.MarkVerificationSent(user.ID.String(), time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, sent, "a mail was sent less than a minute ago")
+	sent, err = userDB.MarkVerificationSent(user.ID.String(), time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.True(t, sent)
+
+	assert.NoError(t, userDB.VerifyEmail(user.ID.String()))
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, userFound.EmailVerified())
+	assert.ErrorIs(t, userDB.VerifyEmail(pkgEntity.NewID().String()), ErrNotFound)
+
+	userFound.Email = "johnny@j.com"
+	userFound.EmailVerifiedAt = nil
+	assert.NoError(t, userDB.Update(userFound))
+	userFound, err = userDB.FindByID(user.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, userFound.EmailVerified())
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type Purger interface {
+	Purge(deletedBefore time.Time) (int64, error)
+}
+
+// PurgeDeleted permanently removes, every interval, the records that were
+// soft deleted, or have expired, longer than retention ago. It runs once
+// immediately and then until ctx is done.
+func PurgeDeleted(ctx context.Context, purger Purger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := purger.Purge(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d records", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
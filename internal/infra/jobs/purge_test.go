@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type purgerFunc func(time.Time) (int64, error)
+
+func (f purgerFunc) Purge(deletedBefore time.Time) (int64, error) {
+	return f(deletedBefore)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan time.Time, 10)
+	purger := purgerFunc(func(deletedBefore time.Time) (int64, error) {
+		calls <- deletedBefore
+		return 0, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		PurgeDeleted(ctx, purger, 24*time.Hour, time.Millisecond)
+		close(done)
+	}()
+
+	first := <-calls
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), first, time.Second)
+	<-calls
+	cancel()
+	<-done
+}
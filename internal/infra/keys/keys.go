@@ -0,0 +1,180 @@
+// Package keys signs and verifies access tokens with a set of keys, so that
+// signing keys can be rotated without logging everyone out and so that
+// other services can verify the tokens with the public keys alone.
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+var (
+	ErrNoPEM          = errors.New("no PEM block found")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrWeakKey        = errors.New("RSA keys must have at least 2048 bits")
+)
+
+// Key signs access tokens from NotBefore on, until a key of a later
+// NotBefore replaces it. Asymmetric keys are identified by the kid header
+// of the tokens they sign, which is the RFC 7638 thumbprint of their public
+// key, so that it is the same wherever the key is loaded.
+type Key struct {
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+	NotBefore time.Time
+	signing   interface{}
+	// verifying is the public key, which is nil for HMAC keys: their
+	// secret is never published.
+	verifying jwk.Key
+	// retiredAt is set on keys that no longer sign, which verify the
+	// tokens they signed until then.
+	retiredAt time.Time
+}
+
+// NewHMAC returns an HS256 key of the secret. Tokens it signs carry no kid,
+// like those signed before keys were rotated.
+func NewHMAC(secret []byte) *Key {
+	return &Key{Algorithm: jwa.HS256, signing: secret}
+}
+
+// NewLegacyHMAC returns an HS256 key of the secret that no longer signs
+// but verifies the tokens it signed until retiredAt, so that moving from a
+// secret to asymmetric keys does not log everyone out. retiredAt should be
+// at least the lifetime of the tokens away.
+func NewLegacyHMAC(secret []byte, retiredAt time.Time) *Key {
+	key := NewHMAC(secret)
+	key.retiredAt = retiredAt
+	return key
+}
+
+// LoadPEM loads the private key of the PEM file, see ParsePEM.
+func LoadPEM(path string, notBefore time.Time) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePEM(data, notBefore)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePEM parses an RSA, ECDSA or Ed25519 private key, in a PKCS #8,
+// PKCS #1 or SEC 1 PEM block. The algorithm follows from the key: RS256
+// for RSA keys, ES256, ES384 or ES512 for ECDSA keys depending on their
+// curve, and EdDSA for Ed25519 keys.
+func ParsePEM(data []byte, notBefore time.Time) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEM
+	}
+	private, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+	alg, public, err := algorithmOf(private)
+	if err != nil {
+		return nil, err
+	}
+	verifying, err := jwk.FromRaw(public)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := verifying.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(thumbprint)
+	for name, value := range map[string]interface{}{jwk.KeyIDKey: id, jwk.AlgorithmKey: alg, jwk.KeyUsageKey: jwk.ForSignature} {
+		if err := verifying.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	signing, err := jwk.FromRaw(private)
+	if err != nil {
+		return nil, err
+	}
+	if err := signing.Set(jwk.KeyIDKey, id); err != nil {
+		return nil, err
+	}
+	return &Key{ID: id, Algorithm: alg, NotBefore: notBefore, signing: signing, verifying: verifying}, nil
+}
+
+func parsePrivateKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+}
+
+func algorithmOf(private interface{}) (jwa.SignatureAlgorithm, crypto.PublicKey, error) {
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return "", nil, ErrWeakKey
+		}
+		return jwa.RS256, &private.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		switch private.Curve {
+		case elliptic.P256():
+			return jwa.ES256, &private.PublicKey, nil
+		case elliptic.P384():
+			return jwa.ES384, &private.PublicKey, nil
+		case elliptic.P521():
+			return jwa.ES512, &private.PublicKey, nil
+		}
+		return "", nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, private.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return jwa.EdDSA, private.Public(), nil
+	}
+	return "", nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, private)
+}
+
+// Load loads the keys of a comma-separated list of PEM files, each
+// optionally followed by @ and the RFC 3339 time it signs from, such as
+// "old.pem,new.pem@2024-07-01T00:00:00Z". Keys without a time sign from the
+// start, the last listed of them signing if several do. The legacy keys,
+// see NewLegacyHMAC, are added to the set.
+func Load(spec string, tokenTTL time.Duration, legacy ...*Key) (*Set, error) {
+	keys := append([]*Key(nil), legacy...)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, at, scheduled := strings.Cut(entry, "@")
+		var notBefore time.Time
+		if scheduled {
+			var err error
+			if notBefore, err = time.Parse(time.RFC3339, at); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		key, err := LoadPEM(path, notBefore)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewSet(tokenTTL, keys...)
+}
@@ -0,0 +1,121 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func generate(t *testing.T, alg jwa.SignatureAlgorithm) crypto.Signer {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch alg {
+	case jwa.RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwa.ES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.ES384:
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwa.EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("could not generate %s key: %v", alg, err)
+	}
+	return key
+}
+
+// pkcs8 returns the key in a PKCS #8 PEM block.
+func pkcs8(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParsePEM(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.ES384, jwa.EdDSA} {
+		data := pkcs8(t, generate(t, alg))
+		key, err := ParsePEM(data, time.Time{})
+		assert.NoError(t, err, alg)
+		assert.Equal(t, alg, key.Algorithm)
+		assert.NotEmpty(t, key.ID)
+		again, err := ParsePEM(data, time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, key.ID, again.ID, "the ID is derived from the key")
+	}
+}
+
+func TestParsePEM_LegacyBlocks(t *testing.T) {
+	rsaKey := generate(t, jwa.RS256).(*rsa.PrivateKey)
+	key, err := ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, jwa.RS256, key.Algorithm)
+
+	ecKey := generate(t, jwa.ES256).(*ecdsa.PrivateKey)
+	der, _ := x509.MarshalECPrivateKey(ecKey)
+	key, err = ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, jwa.ES256, key.Algorithm)
+}
+
+func TestParsePEM_Rejects(t *testing.T) {
+	_, err := ParsePEM([]byte("not a key"), time.Time{})
+	assert.ErrorIs(t, err, ErrNoPEM)
+
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	_, err = ParsePEM(pkcs8(t, weak), time.Time{})
+	assert.ErrorIs(t, err, ErrWeakKey)
+
+	public, _ := x509.MarshalPKIXPublicKey(generate(t, jwa.ES256).Public())
+	_, err = ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), time.Time{})
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.pem")
+	newPath := filepath.Join(dir, "new.pem")
+	assert.NoError(t, os.WriteFile(oldPath, pkcs8(t, generate(t, jwa.RS256)), 0o600))
+	assert.NoError(t, os.WriteFile(newPath, pkcs8(t, generate(t, jwa.EdDSA)), 0o600))
+
+	set, err := Load(oldPath+", "+newPath+"@2024-07-01T00:00:00Z", time.Hour)
+	assert.NoError(t, err)
+	if assert.Len(t, set.keys, 2) {
+		assert.Equal(t, jwa.RS256, set.keys[0].Algorithm)
+		assert.True(t, set.keys[0].NotBefore.IsZero())
+		assert.Equal(t, jwa.EdDSA, set.keys[1].Algorithm)
+		assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), set.keys[1].NotBefore)
+	}
+
+	set, err = Load(newPath, time.Hour, NewLegacyHMAC([]byte("secret"), time.Now().Add(time.Hour)))
+	assert.NoError(t, err)
+	if assert.Len(t, set.keys, 2) {
+		assert.Equal(t, jwa.EdDSA, set.current(time.Now()).Algorithm)
+		assert.Len(t, set.verifying(time.Now()), 2)
+	}
+
+	_, err = Load(newPath+"@July", time.Hour)
+	assert.Error(t, err)
+	_, err = Load(filepath.Join(dir, "missing.pem"), time.Hour)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = Load("", time.Hour)
+	assert.ErrorIs(t, err, ErrNoKeys)
+	_, err = Load(oldPath+","+oldPath, time.Hour)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+}
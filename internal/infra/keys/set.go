@@ -0,0 +1,173 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var (
+	ErrNoKeys       = errors.New("no signing keys")
+	ErrDuplicateKey = errors.New("duplicate signing key")
+)
+
+// Set signs access tokens with the key whose time has come last and
+// verifies them with any key that may have signed a token still alive.
+// A key replaced at a given time is thus retired once tokenTTL has passed
+// since, and a key scheduled for later already verifies, so that it can be
+// published before it signs.
+type Set struct {
+	keys     []*Key
+	tokenTTL time.Duration
+	now      func() time.Time
+}
+
+// NewSet returns a set of the keys, which sign tokens living for tokenTTL.
+// At least one of them must sign.
+func NewSet(tokenTTL time.Duration, keys ...*Key) (*Set, error) {
+	var sorted []*Key
+	signing := false
+	for _, key := range keys {
+		sorted = append(sorted, key)
+		signing = signing || key.retiredAt.IsZero()
+	}
+	if !signing {
+		return nil, ErrNoKeys
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].NotBefore.Before(sorted[j].NotBefore)
+	})
+	ids := map[string]bool{}
+	for _, key := range sorted {
+		if ids[key.ID] {
+			return nil, ErrDuplicateKey
+		}
+		ids[key.ID] = true
+	}
+	return &Set{keys: sorted, tokenTTL: tokenTTL, now: time.Now}, nil
+}
+
+// Encode signs a token of the claims with the current key, like
+// jwtauth.JWTAuth.Encode.
+func (s *Set) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	key := s.current(s.now())
+	signed, err := jwt.Sign(token, jwt.WithKey(key.Algorithm, key.signing))
+	if err != nil {
+		return nil, "", err
+	}
+	return token, string(signed), nil
+}
+
+// Decode parses a token signed by one of the keys that are not retired,
+// which must have the kid and algorithm of the token. Like
+// jwtauth.JWTAuth.Decode, it leaves the validation of the claims to
+// jwt.Validate.
+func (s *Set) Decode(tokenString string) (jwt.Token, error) {
+	keys := s.verifying(s.now())
+	provider := jws.KeyProviderFunc(func(_ context.Context, sink jws.KeySink, sig *jws.Signature, _ *jws.Message) error {
+		headers := sig.ProtectedHeaders()
+		for _, key := range keys {
+			if key.ID == headers.KeyID() && key.Algorithm == headers.Algorithm() {
+				sink.Key(key.Algorithm, key.verificationKey())
+			}
+		}
+		return nil
+	})
+	return jwt.Parse([]byte(tokenString), jwt.WithKeyProvider(provider), jwt.WithValidate(false))
+}
+
+// ValidateOptions returns the options the claims of tokens are validated
+// with, like jwtauth.JWTAuth.ValidateOptions.
+func (s *Set) ValidateOptions() []jwt.ValidateOption {
+	return nil
+}
+
+// PublicKeys returns the JSON Web Key Set of the public keys that are not
+// retired, for other services to verify tokens with. HMAC keys are left
+// out.
+func (s *Set) PublicKeys() (jwk.Set, error) {
+	set := jwk.NewSet()
+	for _, key := range s.verifying(s.now()) {
+		if key.verifying == nil {
+			continue
+		}
+		if err := set.AddKey(key.verifying); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// current returns the key signing at now: the last one whose time has
+// come, or the first one if none has.
+func (s *Set) current(now time.Time) *Key {
+	signing := s.signing()
+	current := signing[0]
+	for _, key := range signing[1:] {
+		if key.NotBefore.After(now) {
+			break
+		}
+		current = key
+	}
+	return current
+}
+
+// verifying returns the keys that signed or may sign tokens alive at now.
+// Keys replaced by one without a time are never retired, as when it was
+// added is unknown: they verify until they are removed from the set.
+// Legacy keys verify until their own retirement.
+func (s *Set) verifying(now time.Time) []*Key {
+	var keys []*Key
+	for _, key := range s.keys {
+		if !key.retiredAt.IsZero() && !now.Before(key.retiredAt) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	signing := s.signing()
+	for i, key := range signing[:len(signing)-1] {
+		replaced := signing[i+1].NotBefore
+		if !replaced.IsZero() && !now.Before(replaced.Add(s.tokenTTL)) {
+			keys = remove(keys, key)
+		}
+	}
+	return keys
+}
+
+// signing returns the keys that sign, in the order of their time.
+func (s *Set) signing() []*Key {
+	var keys []*Key
+	for _, key := range s.keys {
+		if key.retiredAt.IsZero() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func remove(keys []*Key, removed *Key) []*Key {
+	for i, key := range keys {
+		if key == removed {
+			return append(keys[:i:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
+
+func (k *Key) verificationKey() interface{} {
+	if k.verifying == nil {
+		return k.signing
+	}
+	return k.verifying
+}
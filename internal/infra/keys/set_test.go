@@ -0,0 +1,173 @@
+package keys
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKey(t *testing.T, alg jwa.SignatureAlgorithm, notBefore time.Time) *Key {
+	key, err := ParsePEM(pkcs8(t, generate(t, alg)), notBefore)
+	if err != nil {
+		t.Fatalf("could not parse key: %v", err)
+	}
+	return key
+}
+
+// kid returns the key ID in the header of the token.
+func kid(t *testing.T, token string) string {
+	message, err := jws.Parse([]byte(token))
+	if err != nil {
+		t.Fatalf("could not parse token: %v", err)
+	}
+	return message.Signatures()[0].ProtectedHeaders().KeyID()
+}
+
+func TestSet_EncodeDecode(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA} {
+		key := newKey(t, alg, time.Time{})
+		set, err := NewSet(time.Hour, key)
+		assert.NoError(t, err)
+		_, token, err := set.Encode(map[string]interface{}{"sub": "john"})
+		assert.NoError(t, err)
+		assert.Equal(t, key.ID, kid(t, token))
+
+		decoded, err := set.Decode(token)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, "john", decoded.Subject())
+	}
+}
+
+func TestSet_DecodeRejectsOtherKeys(t *testing.T) {
+	key := newKey(t, jwa.ES256, time.Time{})
+	set, _ := NewSet(time.Hour, key)
+	other, _ := NewSet(time.Hour, newKey(t, jwa.ES256, time.Time{}))
+	_, token, _ := other.Encode(map[string]interface{}{"sub": "john"})
+	_, err := set.Decode(token)
+	assert.Error(t, err)
+
+	// A token of another key claiming the kid of the set is rejected too.
+	impostor := newKey(t, jwa.ES256, time.Time{})
+	impostor.ID = key.ID
+	assert.NoError(t, impostor.signing.(jwk.Key).Set(jwk.KeyIDKey, key.ID))
+	forged, _ := NewSet(time.Hour, impostor)
+	_, token, _ = forged.Encode(map[string]interface{}{"sub": "john"})
+	assert.Equal(t, key.ID, kid(t, token))
+	_, err = set.Decode(token)
+	assert.Error(t, err)
+
+	// So is an HMAC token signed with the public key as secret.
+	public, _ := json.Marshal(key.verifying)
+	hmac, _ := NewSet(time.Hour, NewHMAC(public))
+	_, token, _ = hmac.Encode(map[string]interface{}{"sub": "john"})
+	_, err = set.Decode(token)
+	assert.Error(t, err)
+}
+
+func TestSet_HMAC(t *testing.T) {
+	set, err := NewSet(time.Hour, NewHMAC([]byte("secret")))
+	assert.NoError(t, err)
+	_, token, err := set.Encode(map[string]interface{}{"sub": "john"})
+	assert.NoError(t, err)
+	assert.Empty(t, kid(t, token))
+	decoded, err := set.Decode(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "john", decoded.Subject())
+
+	public, err := set.PublicKeys()
+	assert.NoError(t, err)
+	assert.Zero(t, public.Len(), "HMAC secrets are never published")
+
+	other, _ := NewSet(time.Hour, NewHMAC([]byte("other secret")))
+	_, err = other.Decode(token)
+	assert.Error(t, err)
+}
+
+func TestSet_Rotation(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	oldKey := newKey(t, jwa.RS256, time.Time{})
+	newKey := newKey(t, jwa.ES256, start)
+	set, err := NewSet(time.Hour, newKey, oldKey)
+	assert.NoError(t, err)
+
+	set.now = func() time.Time { return start.Add(-time.Minute) }
+	_, oldToken, err := set.Encode(map[string]interface{}{"sub": "john"})
+	assert.NoError(t, err)
+	assert.Equal(t, oldKey.ID, kid(t, oldToken))
+	public, _ := set.PublicKeys()
+	assert.Equal(t, 2, public.Len(), "the scheduled key is published before it signs")
+
+	set.now = func() time.Time { return start }
+	_, newToken, err := set.Encode(map[string]interface{}{"sub": "john"})
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.ID, kid(t, newToken))
+	_, err = set.Decode(oldToken)
+	assert.NoError(t, err, "tokens of the replaced key stay valid while they live")
+
+	set.now = func() time.Time { return start.Add(time.Hour) }
+	_, err = set.Decode(oldToken)
+	assert.Error(t, err, "the replaced key is retired once its tokens expired")
+	_, err = set.Decode(newToken)
+	assert.NoError(t, err)
+	public, _ = set.PublicKeys()
+	if assert.Equal(t, 1, public.Len()) {
+		published, _ := public.Key(0)
+		assert.Equal(t, newKey.ID, published.KeyID())
+		assert.Equal(t, jwa.ES256, published.Algorithm())
+	}
+}
+
+func TestSet_UnscheduledKeysAreNotRetired(t *testing.T) {
+	oldKey := newKey(t, jwa.RS256, time.Time{})
+	newKey := newKey(t, jwa.EdDSA, time.Time{})
+	set, _ := NewSet(time.Hour, oldKey, newKey)
+	old, _ := NewSet(time.Hour, oldKey)
+	_, oldToken, _ := old.Encode(map[string]interface{}{"sub": "john"})
+
+	_, token, _ := set.Encode(map[string]interface{}{"sub": "john"})
+	assert.Equal(t, newKey.ID, kid(t, token), "the last listed key signs")
+	_, err := set.Decode(oldToken)
+	assert.NoError(t, err)
+}
+
+func TestSet_ValidatesNothingOnDecode(t *testing.T) {
+	set, _ := NewSet(time.Hour, newKey(t, jwa.EdDSA, time.Time{}))
+	_, token, _ := set.Encode(map[string]interface{}{"sub": "john", "exp": time.Now().Add(-time.Minute).Unix()})
+	decoded, err := set.Decode(token)
+	assert.NoError(t, err)
+	assert.Error(t, jwt.Validate(decoded, set.ValidateOptions()...))
+}
+
+func TestSet_LegacyHMAC(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	secret := []byte("secret")
+	legacy, _ := NewSet(time.Hour, NewHMAC(secret))
+	_, legacyToken, _ := legacy.Encode(map[string]interface{}{"sub": "john"})
+
+	key := newKey(t, jwa.ES256, time.Time{})
+	set, err := NewSet(time.Hour, NewLegacyHMAC(secret, start.Add(time.Hour)), key)
+	assert.NoError(t, err)
+	set.now = func() time.Time { return start }
+	_, token, err := set.Encode(map[string]interface{}{"sub": "john"})
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, kid(t, token), "the legacy key never signs")
+	_, err = set.Decode(legacyToken)
+	assert.NoError(t, err, "tokens of the legacy key stay valid while they live")
+	public, _ := set.PublicKeys()
+	assert.Equal(t, 1, public.Len())
+
+	set.now = func() time.Time { return start.Add(time.Hour) }
+	_, err = set.Decode(legacyToken)
+	assert.Error(t, err, "the legacy key is retired at its time")
+	_, err = set.Decode(token)
+	assert.NoError(t, err)
+
+	_, err = NewSet(time.Hour, NewLegacyHMAC(secret, start))
+	assert.ErrorIs(t, err, ErrNoKeys, "a set needs a key that signs")
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+var ErrUnknownDriver = errors.New("unknown mail driver")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+type Config struct {
+	Driver string
+	From   string
+	Host   string
+	Port   string
+	User   string
+	Pass   string
+	// Dir is where the file driver writes messages.
+	Dir string
+}
+
+// NewMailer returns the mailer of the driver. SMTP is meant for production,
+// the file and memory drivers for running the API locally and for tests.
+func NewMailer(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		var auth smtp.Auth
+		if cfg.User != "" {
+			auth = smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
+		}
+		return &SMTPMailer{Addr: net.JoinHostPort(cfg.Host, cfg.Port), From: cfg.From, Auth: auth}, nil
+	case DriverFile:
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}, nil
+	case DriverMemory:
+		return &MemoryMailer{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
+
+// format encodes the message as RFC 5322 text. Line breaks are removed from
+// header values so that they cannot add headers.
+func format(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", msg.Subject)
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMailer(t *testing.T) {
+	m, err := NewMailer(Config{Driver: DriverSMTP, Host: "localhost", Port: "25", User: "user", Pass: "pass"})
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:25", m.(*SMTPMailer).Addr)
+	assert.NotNil(t, m.(*SMTPMailer).Auth)
+
+	m, err = NewMailer(Config{Driver: DriverFile, Dir: "mail"})
+	assert.NoError(t, err)
+	assert.IsType(t, &FileMailer{}, m)
+
+	_, err = NewMailer(Config{Driver: "pigeon"})
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+}
+
+func TestFormat_StripsLineBreaksFromHeaders(t *testing.T) {
+	data := string(format("api@example.com", Message{
+		To:      "j@j.com",
+		Subject: "Hello\r\nBcc: eve@example.com",
+		Body:    "line 1\nline 2",
+	}))
+	assert.Contains(t, data, "Subject: HelloBcc: eve@example.com\r\n")
+	assert.NotContains(t, data, "\r\nBcc:")
+	assert.True(t, strings.HasSuffix(data, "\r\n\r\nline 1\r\nline 2"))
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := &FileMailer{Dir: dir, From: "api@example.com"}
+	assert.NoError(t, m.Send(Message{To: "j@j.com", Subject: "Hello", Body: "Hi John"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "To: j@j.com\r\n")
+	assert.Contains(t, string(data), "Hi John")
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	assert.NoError(t, m.Send(Message{To: "j@j.com", Subject: "1"}))
+	assert.NoError(t, m.Send(Message{To: "j@j.com", Subject: "2"}))
+	messages := m.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "2", messages[1].Subject)
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go serveSMTP(listener, received)
+
+	m := &SMTPMailer{Addr: listener.Addr().String(), From: "api@example.com"}
+	assert.NoError(t, m.Send(Message{To: "j@j.com", Subject: "Hello", Body: "Hi John"}))
+	data := <-received
+	assert.Contains(t, data, "MAIL FROM:<api@example.com>")
+	assert.Contains(t, data, "RCPT TO:<j@j.com>")
+	assert.Contains(t, data, "Subject: Hello")
+	assert.Contains(t, data, "Hi John")
+}
+
+// serveSMTP answers a single SMTP session, sending what the client wrote.
+func serveSMTP(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		received <- ""
+		return
+	}
+	defer conn.Close()
+	var session strings.Builder
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		session.WriteString(line)
+		switch {
+		case inData:
+			if line == ".\r\n" {
+				inData = false
+				reply("250 OK")
+			}
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250 localhost")
+		case strings.HasPrefix(line, "DATA"):
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case strings.HasPrefix(line, "QUIT"):
+			reply("221 Bye")
+			received <- session.String()
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+	received <- session.String()
+}
@@ -0,0 +1,16 @@
+package mail
+
+import "net/smtp"
+
+// SMTPMailer relays messages to an SMTP server, upgrading the connection
+// with STARTTLS when the server supports it.
+type SMTPMailer struct {
+	Addr string
+	From string
+	// Auth may be nil for servers that do not require authentication.
+	Auth smtp.Auth
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, format(m.From, msg))
+}
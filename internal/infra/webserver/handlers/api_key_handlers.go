@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyHandler struct {
+	APIKeyDB database.APIKeyInterface
+}
+
+func NewAPIKeyHandler(db database.APIKeyInterface) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyDB: db,
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a long-lived API key of the current user for programs to call the API with in the X-API-Key header, with the scopes it lists. Keys act within the current role of the user. The response holds the key, which is never shown again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body dto.CreateAPIKeyInput true "API key name, scopes and expiry"
+// @Success 201 {object} dto.CreatedAPIKeyOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/api-keys [post]
+// @Security ApiKeyAuth
+func (ah *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateAPIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	userID, _, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	scopes := make([]entity.Scope, len(input.Scopes))
+	for i, scope := range input.Scopes {
+		scopes[i] = entity.Scope(scope)
+	}
+	key, plain, err := entity.NewAPIKey(userID, input.Name, scopes, input.ExpiresAt)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := ah.APIKeyDB.Create(key); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	output := dto.CreatedAPIKeyOutput{APIKeyOutput: dto.NewAPIKeyOutput(key), Key: plain}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(output)
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description List the API keys of the current user that are not revoked, the latest first, with when each was last used
+// @Tags users
+// @Produce json
+// @Success 200 {array} dto.APIKeyOutput
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/api-keys [get]
+// @Security ApiKeyAuth
+func (ah *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	keys, err := ah.APIKeyDB.FindByUser(userID.String())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	output := []dto.APIKeyOutput{}
+	for i := range keys {
+		output = append(output, dto.NewAPIKeyOutput(&keys[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key of the current user, which is rejected from then on
+// @Tags users
+// @Param id path string true "API key ID"
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/api-keys/{id} [delete]
+// @Security ApiKeyAuth
+func (ah *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	if err := ah.APIKeyDB.Revoke(userID.String(), chi.URLParam(r, "id")); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
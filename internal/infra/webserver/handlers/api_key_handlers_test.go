@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// apiKeyDBStub holds no keys, failing its lookups with findErr and its
+// modifications with writeErr. Other methods are not implemented.
+type apiKeyDBStub struct {
+	database.APIKeyInterface
+	findErr  error
+	writeErr error
+}
+
+func (s *apiKeyDBStub) Create(*entity.APIKey) error { return s.writeErr }
+
+func (s *apiKeyDBStub) FindByUser(string) ([]entity.APIKey, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return []entity.APIKey{}, nil
+}
+
+func (s *apiKeyDBStub) Revoke(string, string) error { return s.writeErr }
+
+const newAPIKey = `{"name":"Batch","scopes":["products:read"]}`
+
+func TestAPIKeyHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(*APIKeyHandler) http.HandlerFunc
+		body     string
+		findErr  error
+		writeErr error
+		status   int
+	}{
+		{"create", createAPIKey, newAPIKey, nil, nil, http.StatusCreated},
+		{"create without name", createAPIKey, `{"name":" ","scopes":["products:read"]}`, nil, nil, http.StatusBadRequest},
+		{"create without scopes", createAPIKey, `{"name":"Batch","scopes":[]}`, nil, nil, http.StatusBadRequest},
+		{"create unknown scope", createAPIKey, `{"name":"Batch","scopes":["users:write"]}`, nil, nil, http.StatusBadRequest},
+		{"create expired", createAPIKey, `{"name":"Batch","scopes":["products:read"],"expires_at":"2000-01-01T00:00:00Z"}`, nil, nil, http.StatusBadRequest},
+		{"create not json", createAPIKey, `{`, nil, nil, http.StatusBadRequest},
+		{"create unavailable", createAPIKey, newAPIKey, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"create failure", createAPIKey, newAPIKey, nil, errDriver, http.StatusInternalServerError},
+
+		{"list", getAPIKeys, "", nil, nil, http.StatusOK},
+		{"list unavailable", getAPIKeys, "", database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"list failure", getAPIKeys, "", errDriver, nil, http.StatusInternalServerError},
+
+		{"revoke", revokeAPIKey, "", nil, nil, http.StatusNoContent},
+		{"revoke not found", revokeAPIKey, "", nil, database.ErrNotFound, http.StatusNotFound},
+		{"revoke unavailable", revokeAPIKey, "", nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"revoke failure", revokeAPIKey, "", nil, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewAPIKeyHandler(&apiKeyDBStub{findErr: tt.findErr, writeErr: tt.writeErr})
+			id := pkgEntity.NewID().String()
+			r := httptest.NewRequest(http.MethodPost, "/users/me/api-keys/"+id, strings.NewReader(tt.body))
+			r = authenticate(t, withURLParam(r, "id", id), pkgEntity.NewID().String(), entity.RoleEditor)
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func createAPIKey(h *APIKeyHandler) http.HandlerFunc { return h.CreateAPIKey }
+func getAPIKeys(h *APIKeyHandler) http.HandlerFunc   { return h.GetAPIKeys }
+func revokeAPIKey(h *APIKeyHandler) http.HandlerFunc { return h.RevokeAPIKey }
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+)
+
+type CategoryHandler struct {
+	CategoryDB database.CategoryInterface
+}
+
+func NewCategoryHandler(db database.CategoryInterface) *CategoryHandler {
+	return &CategoryHandler{
+		CategoryDB: db,
+	}
+}
+
+// categoryDB returns the repository confined to the organization of the
+// request, or writes the response and returns false if it has none.
+func (ch *CategoryHandler) categoryDB(w http.ResponseWriter, r *http.Request) (database.CategoryInterface, bool) {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	return ch.CategoryDB.ForTenant(tenant), true
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Create a category of the organization of the current user, optionally below a parent category of the same organization
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param input body dto.CreateCategoryInput true "Category Data"
+// @Success 201 {object} entity.Category
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /categories [post]
+// @Security ApiKeyAuth
+func (ch *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	categoryDB, ok := ch.categoryDB(w, r)
+	if !ok {
+		return
+	}
+	var category dto.CreateCategoryInput
+	err := json.NewDecoder(r.Body).Decode(&category)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	parentID, err := parseParentID(category.ParentID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	c, err := entity.NewCategory(category.Name, parentID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	err = categoryDB.Create(c)
+	if errors.Is(err, database.ErrCategoryNotFound) {
+		problem.Write(w, r, problem.Invalid("parent_id", err))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// GetAllCategories godoc
+// @Summary Get all categories
+// @Description Get all categories of the organization of the current user as a flat list, or as a tree when tree=true
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param tree query bool false "Return the categories as a tree"
+// @Success 200 {array} entity.Category
+// @Failure 500 {object} problem.Problem
+// @Router /categories [get]
+// @Security ApiKeyAuth
+func (ch *CategoryHandler) GetAllCategories(w http.ResponseWriter, r *http.Request) {
+	categoryDB, ok := ch.categoryDB(w, r)
+	if !ok {
+		return
+	}
+	categories, err := categoryDB.FindAll()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.URL.Query().Get("tree") == "true" {
+		json.NewEncoder(w).Encode(categoryTree(categories, nil))
+		return
+	}
+	json.NewEncoder(w).Encode(categories)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Get a category
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID"
+// @Success 200 {object} entity.Category
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /categories/{id} [get]
+// @Security ApiKeyAuth
+func (ch *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	categoryDB, ok := ch.categoryDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	c, err := categoryDB.FindByID(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Rename a category or move it below another parent
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID"
+// @Param input body dto.UpdateCategoryInput true "Category Data"
+// @Success 200 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /categories/{id} [put]
+// @Security ApiKeyAuth
+func (ch *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	categoryDB, ok := ch.categoryDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	var fields dto.UpdateCategoryInput
+	err := json.NewDecoder(r.Body).Decode(&fields)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	c, err := categoryDB.FindByID(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	c.Name = fields.Name
+	c.ParentID, err = parseParentID(fields.ParentID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	err = categoryDB.Update(c)
+	if errors.Is(err, database.ErrCategoryNotFound) || errors.Is(err, database.ErrCategoryCycle) {
+		problem.Write(w, r, problem.Invalid("parent_id", err))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category that has no subcategories
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID"
+// @Success 200 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /categories/{id} [delete]
+// @Security ApiKeyAuth
+func (ch *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	categoryDB, ok := ch.categoryDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	err := categoryDB.Delete(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func parseParentID(parentID *string) (*pkgEntity.ID, error) {
+	if parentID == nil || *parentID == "" {
+		return nil, nil
+	}
+	id, err := pkgEntity.ParseID(*parentID)
+	if err != nil {
+		return nil, entity.ErrInvalidParent
+	}
+	return &id, nil
+}
+
+// categoryTree nests the categories below the given parent, nil being the
+// root of the tree.
+func categoryTree(categories []entity.Category, parentID *pkgEntity.ID) []dto.CategoryTreeOutput {
+	nodes := []dto.CategoryTreeOutput{}
+	for _, c := range categories {
+		if (parentID == nil) != (c.ParentID == nil) {
+			continue
+		}
+		if parentID != nil && *parentID != *c.ParentID {
+			continue
+		}
+		nodes = append(nodes, dto.CategoryTreeOutput{
+			ID:       c.ID.String(),
+			Name:     c.Name,
+			Children: categoryTree(categories, &c.ID),
+		})
+	}
+	return nodes
+}
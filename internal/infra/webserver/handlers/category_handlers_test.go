@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// categoryDBStub serves a single category, failing its lookups with
+// findErr and its modifications with writeErr.
+type categoryDBStub struct {
+	database.CategoryInterface
+	category *entity.Category
+	findErr  error
+	writeErr error
+}
+
+func (s *categoryDBStub) ForTenant(string) database.CategoryInterface { return s }
+
+func (s *categoryDBStub) FindAll() ([]entity.Category, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return []entity.Category{*s.category}, nil
+}
+
+func (s *categoryDBStub) FindByID(string) (*entity.Category, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return s.category, nil
+}
+
+func (s *categoryDBStub) Create(*entity.Category) error { return s.writeErr }
+
+func (s *categoryDBStub) Update(*entity.Category) error { return s.writeErr }
+
+func (s *categoryDBStub) Delete(string) error { return s.writeErr }
+
+func TestCategoryHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(*CategoryHandler) http.HandlerFunc
+		method   string
+		body     string
+		findErr  error
+		writeErr error
+		status   int
+	}{
+		{"list", listCategories, http.MethodGet, "", nil, nil, http.StatusOK},
+		{"list unavailable", listCategories, http.MethodGet, "", database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"get", getCategory, http.MethodGet, "", nil, nil, http.StatusOK},
+		{"get not found", getCategory, http.MethodGet, "", database.ErrNotFound, nil, http.StatusNotFound},
+		{"get unavailable", getCategory, http.MethodGet, "", database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"get failure", getCategory, http.MethodGet, "", errDriver, nil, http.StatusInternalServerError},
+		{"create conflict", createCategory, http.MethodPost, `{"name":"Books"}`, nil, database.ErrConflict, http.StatusConflict},
+		{"create unknown parent", createCategory, http.MethodPost, `{"name":"Books"}`, nil, database.ErrCategoryNotFound, http.StatusBadRequest},
+		{"update not found", updateCategory, http.MethodPut, `{"name":"Books"}`, database.ErrNotFound, nil, http.StatusNotFound},
+		{"update unavailable", updateCategory, http.MethodPut, `{"name":"Books"}`, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"update cycle", updateCategory, http.MethodPut, `{"name":"Books"}`, nil, database.ErrCategoryCycle, http.StatusBadRequest},
+		{"delete", deleteCategory, http.MethodDelete, "", nil, nil, http.StatusOK},
+		{"delete not found", deleteCategory, http.MethodDelete, "", nil, database.ErrNotFound, http.StatusNotFound},
+		{"delete with subcategories", deleteCategory, http.MethodDelete, "", nil, database.ErrCategoryHasChildren, http.StatusConflict},
+		{"delete unavailable", deleteCategory, http.MethodDelete, "", nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"delete failure", deleteCategory, http.MethodDelete, "", nil, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := entity.NewCategory("Books", nil)
+			h := NewCategoryHandler(&categoryDBStub{category: c, findErr: tt.findErr, writeErr: tt.writeErr})
+			r := httptest.NewRequest(tt.method, "/categories/"+c.ID.String(), strings.NewReader(tt.body))
+			r = authenticate(t, withURLParam(r, "id", c.ID.String()), pkgEntity.NewID().String(), entity.RoleEditor)
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func listCategories(h *CategoryHandler) http.HandlerFunc { return h.GetAllCategories }
+func getCategory(h *CategoryHandler) http.HandlerFunc    { return h.GetCategory }
+func createCategory(h *CategoryHandler) http.HandlerFunc { return h.CreateCategory }
+func updateCategory(h *CategoryHandler) http.HandlerFunc { return h.UpdateCategory }
+func deleteCategory(h *CategoryHandler) http.HandlerFunc { return h.DeleteCategory }
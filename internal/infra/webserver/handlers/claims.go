@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+var (
+	errNoUser   = errors.New("the access token does not identify a user")
+	errNoTenant = errors.New("the access token does not identify an organization")
+)
+
+// currentUser returns the ID and role of the user the access token of the
+// request was issued to. Tokens without a role claim are a viewer's, as in
+// middlewares.RequireRole.
+func currentUser(r *http.Request) (pkgEntity.ID, entity.Role, error) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return pkgEntity.ID{}, "", err
+	}
+	sub, _ := claims["sub"].(string)
+	id, err := pkgEntity.ParseID(sub)
+	if err != nil {
+		return pkgEntity.ID{}, "", errNoUser
+	}
+	role := entity.RoleViewer
+	if claim, ok := claims["role"].(string); ok {
+		role = entity.Role(claim)
+	}
+	return id, role, nil
+}
+
+// currentTenant returns the ID of the organization the access token of the
+// request was issued for. Every tenant-owned lookup must be confined to it.
+func currentTenant(r *http.Request) (string, error) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return "", err
+	}
+	tenant, _ := claims["tenant"].(string)
+	if _, err := pkgEntity.ParseID(tenant); err != nil {
+		return "", errNoTenant
+	}
+	return tenant, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+// JWKSMaxAge is how long other services may cache the published keys. Keys
+// scheduled to sign must be published for longer than that beforehand, so
+// that the tokens they sign verify everywhere.
+const JWKSMaxAge = 5 * time.Minute
+
+type KeyHandler struct {
+	Keys *keys.Set
+}
+
+func NewKeyHandler(set *keys.Set) *KeyHandler {
+	return &KeyHandler{
+		Keys: set,
+	}
+}
+
+// GetJWKS godoc
+// @Summary Get the access token verification keys
+// @Description The JSON Web Key Set of the public keys access tokens are signed with, for other services to verify them locally by the kid of their header. Keys are published before they start signing and until the tokens they signed expire. HMAC secrets are never published.
+// @Tags keys
+// @Produce json
+// @Success 200 {object} object "JSON Web Key Set"
+// @Failure 500 {object} problem.Problem
+// @Router /.well-known/jwks.json [get]
+func (kh *KeyHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := kh.Keys.PublicKeys()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(JWKSMaxAge.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(set)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+// loginSubject is an email or client IP address whose failed logins are
+// counted, and the policy locking it out.
+type loginSubject struct {
+	name   string
+	policy entity.LockoutPolicy
+}
+
+func (uh *UserHandler) loginSubjects(email string, r *http.Request) []loginSubject {
+	return []loginSubject{
+		{name: email, policy: uh.AccountLockout},
+		{name: clientIP(r), policy: uh.ClientLockout},
+	}
+}
+
+// lockedOut returns how long logins of the email from the client of the
+// request remain locked out, zero if they are not.
+func (uh *UserHandler) lockedOut(email string, r *http.Request) (time.Duration, error) {
+	if uh.Throttle == nil {
+		return 0, nil
+	}
+	var retryAfter time.Duration
+	now := time.Now()
+	for _, subject := range uh.loginSubjects(email, r) {
+		failures, err := uh.Throttle.Find(subject.name)
+		if err != nil {
+			return 0, err
+		}
+		if d := failures.RetryAfter(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	return retryAfter, nil
+}
+
+// failLogin counts a failed login of the email, of user u if it is known,
+// from the client of the request, and audits the lockouts it causes.
+func (uh *UserHandler) failLogin(email string, u *entity.User, r *http.Request) error {
+	if uh.Throttle == nil {
+		return nil
+	}
+	for _, subject := range uh.loginSubjects(email, r) {
+		failures, err := uh.Throttle.Fail(subject.name, subject.policy)
+		if err != nil {
+			return err
+		}
+		lockout := subject.policy.LockoutFor(failures.Failures)
+		if lockout == 0 || uh.Audit == nil {
+			continue
+		}
+		entry := entity.NewAuditEntry(entity.AuditLoginLocked, subject.name, clientIP(r),
+			fmt.Sprintf("locked out for %s after %d failed logins", lockout, failures.Failures))
+		if u != nil && subject.name == email {
+			entry.UserID = &u.ID
+		}
+		// The lockout holds even if it cannot be audited.
+		if err := uh.Audit.Record(entry); err != nil {
+			log.Printf("could not audit the lockout of %s: %v", subject.name, err)
+		}
+	}
+	return nil
+}
+
+// resetLogin forgets the failed logins of the email, which just logged in.
+// Those of the client are kept, lest an attacker reset them with an account
+// of their own.
+func (uh *UserHandler) resetLogin(email string) error {
+	if uh.Throttle == nil {
+		return nil
+	}
+	return uh.Throttle.Reset(email)
+}
+
+// resetSubjectPrefix keeps the password reset requests of an email or a
+// client apart from its failed logins.
+const resetSubjectPrefix = "password-reset:"
+
+// throttleReset counts a password reset request for the email from the
+// client of the request and returns how long further requests are held off,
+// zero if this one may proceed. Every request counts, whether or not a user
+// has the email, so that the answer does not tell who is registered.
+func (uh *UserHandler) throttleReset(email string, r *http.Request) (time.Duration, error) {
+	if uh.Throttle == nil {
+		return 0, nil
+	}
+	return uh.Throttle.Attempt(map[string]entity.LockoutPolicy{
+		resetSubjectPrefix + email:       uh.ResetAccountLockout,
+		resetSubjectPrefix + clientIP(r): uh.ResetClientLockout,
+	})
+}
+
+// writeLockedOut responds 429 with the number of seconds to wait.
+func writeLockedOut(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	writeRetryAfter(w, r, retryAfter, entity.ErrLoginLocked)
+}
+
+// writeRetryAfter responds with the problem of err, with the number of
+// seconds to wait.
+func writeRetryAfter(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	problem.Error(w, r, err)
+}
+
+// clientIP returns the IP address of the client of the request. Behind a
+// reverse proxy, the RealIP middleware has to set it from the headers of
+// the proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
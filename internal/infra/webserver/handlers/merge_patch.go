@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+// MergePatchContentType is the media type of RFC 7386 JSON Merge Patch
+// documents, the only one PATCH requests accept.
+const MergePatchContentType = "application/merge-patch+json"
+
+var errNotMergePatch = problem.New(http.StatusBadRequest, "the request body must be a JSON merge patch object")
+
+// isMergePatch reports whether the request body is a JSON merge patch.
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == MergePatchContentType
+}
+
+// applyMergePatch merges the patch into the JSON representation of target
+// and decodes the result into out, as RFC 7386 describes: members of the
+// patch replace those of the target, null members remove them and objects
+// are merged recursively.
+func applyMergePatch(target interface{}, patch json.RawMessage, out interface{}) error {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return err
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return errNotMergePatch
+	}
+	data, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var targetDoc interface{}
+	if err := json.Unmarshal(data, &targetDoc); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(targetDoc, patchDoc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, out)
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+// EnrollTOTP godoc
+// @Summary Enroll an authenticator app
+// @Description Generate a TOTP secret for the current user to enroll in an authenticator app, by typing it in or scanning the provisioning URI as a QR code. Logins only require a code once one is confirmed. Enrolling again replaces a secret that is not confirmed yet.
+// @Tags users
+// @Produce json
+// @Success 200 {object} dto.TOTPEnrollmentOutput
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem "Already enabled"
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/mfa/totp [post]
+// @Security ApiKeyAuth
+func (uh *UserHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	if uh.MFA == nil {
+		problem.Status(w, r, http.StatusNotFound)
+		return
+	}
+	u, ok := uh.me(w, r)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabled() {
+		problem.Error(w, r, entity.ErrTOTPEnabled)
+		return
+	}
+	secret, err := entity.NewTOTPSecret()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.MFA.EnrollTOTP(u.ID.String(), secret); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	output := dto.TOTPEnrollmentOutput{
+		Secret:          secret,
+		ProvisioningURI: entity.TOTPURI(uh.MFAIssuer, u.Email, secret),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// ConfirmTOTP godoc
+// @Summary Confirm an authenticator app
+// @Description Enable two-factor authentication for the current user with a code of the authenticator app enrolled last. The response holds recovery codes to log in without the app, each once, which are never shown again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body dto.ConfirmTOTPInput true "TOTP code"
+// @Success 200 {object} dto.RecoveryCodesOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem "Already enabled or not enrolled"
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/mfa/totp/confirm [post]
+// @Security ApiKeyAuth
+func (uh *UserHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if uh.MFA == nil {
+		problem.Status(w, r, http.StatusNotFound)
+		return
+	}
+	var input dto.ConfirmTOTPInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, ok := uh.me(w, r)
+	if !ok {
+		return
+	}
+	if u.TOTPEnabled() {
+		problem.Error(w, r, entity.ErrTOTPEnabled)
+		return
+	}
+	step, err := u.ValidateTOTP(input.Code, time.Now())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	codes, plain, err := entity.NewRecoveryCodes(u.ID, entity.RecoveryCodeCount)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.MFA.EnableTOTP(u.ID.String(), u.TOTPSecret, step, codes); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.RecoveryCodesOutput{RecoveryCodes: plain})
+}
+
+// LoginMFA godoc
+// @Summary Complete a login with a second factor
+// @Description Exchange the challenge token of a login for an access token and a refresh token, with a code of the authenticator app of the user or else one of their recovery codes. Codes cannot be used twice, and wrong codes count as failed logins.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body dto.MFALoginInput true "Challenge token and code"
+// @Success 200 {object} dto.LoginUserOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem "Locked out"
+// @Failure 500 {object} problem.Problem
+// @Router /users/login/mfa [post]
+func (uh *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	if uh.MFA == nil {
+		problem.Status(w, r, http.StatusNotFound)
+		return
+	}
+	var input dto.MFALoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	id, err := entity.ParseMFAChallenge(input.MFAToken, uh.MFASecret)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, err := uh.UserDB.FindByID(id.String())
+	if errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, entity.ErrInvalidMFAToken)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if !u.TOTPEnabled() {
+		problem.Error(w, r, entity.ErrInvalidMFAToken)
+		return
+	}
+	retryAfter, err := uh.lockedOut(u.Email, r)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		writeLockedOut(w, r, retryAfter)
+		return
+	}
+	field, err := uh.useSecondFactor(u, input)
+	if errors.Is(err, entity.ErrInvalidMFACode) {
+		if err := uh.failLogin(u.Email, u, r); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Write(w, r, problem.Invalid(field, err))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.resetLogin(u.Email); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	uh.login(w, r, u)
+}
+
+// mfaRequired reports whether logins of the user require a second factor.
+func (uh *UserHandler) mfaRequired(u *entity.User) bool {
+	return uh.MFA != nil && u.TOTPEnabled()
+}
+
+// useSecondFactor uses up the TOTP code of the input, or its recovery code
+// if it has no TOTP code, and returns the name of the field it was read
+// from.
+func (uh *UserHandler) useSecondFactor(u *entity.User, input dto.MFALoginInput) (string, error) {
+	if input.Code == "" && input.RecoveryCode != "" {
+		return "recovery_code", uh.MFA.UseRecoveryCode(u.ID.String(), entity.HashRecoveryCode(input.RecoveryCode))
+	}
+	step, err := u.ValidateTOTP(input.Code, time.Now())
+	if err != nil {
+		return "code", err
+	}
+	return "code", uh.MFA.UseTOTPStep(u.ID.String(), step)
+}
+
+// writeMFAChallenge responds 202 with the challenge token of a login that
+// awaits a second factor.
+func writeMFAChallenge(w http.ResponseWriter, token string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto.MFAChallengeOutput{MFAToken: token})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+type OrganizationHandler struct {
+	OrganizationDB database.OrganizationInterface
+	UserDB         database.UserInterface
+	// Verifier mails the users created a link to verify their email, if
+	// set.
+	Verifier *EmailVerifier
+}
+
+func NewOrganizationHandler(db database.OrganizationInterface, userDB database.UserInterface) *OrganizationHandler {
+	return &OrganizationHandler{
+		OrganizationDB: db,
+		UserDB:         userDB,
+	}
+}
+
+// CreateOrganization godoc
+// @Summary Create an organization
+// @Description Create an organization along with its first user, who administers it
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param input body dto.CreateOrganizationInput true "Organization Data"
+// @Success 201 {object} entity.Organization
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /organizations [post]
+func (oh *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateOrganizationInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	o, err := entity.NewOrganization(input.Name)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	admin, err := entity.NewUser(input.Admin.Name, input.Admin.Email, input.Admin.Password)
+	if err != nil {
+		problem.Write(w, r, problem.From(err).Nest("admin"))
+		return
+	}
+	err = oh.OrganizationDB.Create(o, admin)
+	if errors.Is(err, database.ErrConflict) {
+		problem.Write(w, r, problem.Conflict("admin.email", errEmailTaken))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	oh.Verifier.sendNow(admin)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(o)
+}
+
+// GetOrganization godoc
+// @Summary Get an organization
+// @Description Get the organization of the current user
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} entity.Organization
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /organizations/{id} [get]
+// @Security ApiKeyAuth
+func (oh *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	id, ok := ownOrganization(w, r)
+	if !ok {
+		return
+	}
+	o, err := oh.OrganizationDB.FindByID(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(o)
+}
+
+// CreateMember godoc
+// @Summary Create a member
+// @Description Create a viewer in the organization of the current user
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param user body dto.CreateUserInput true "User data"
+// @Success 201 {object} dto.UserOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /organizations/{id}/users [post]
+// @Security ApiKeyAuth
+func (oh *OrganizationHandler) CreateMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := ownOrganization(w, r)
+	if !ok {
+		return
+	}
+	var user dto.CreateUserInput
+	err := json.NewDecoder(r.Body).Decode(&user)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, err := entity.NewUser(user.Name, user.Email, user.Password)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	err = oh.UserDB.ForTenant(id).Create(u)
+	if errors.Is(err, database.ErrConflict) {
+		problem.Write(w, r, problem.Conflict("email", errEmailTaken))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	oh.Verifier.sendNow(u)
+	writeUser(w, http.StatusCreated, u)
+}
+
+// ownOrganization returns the organization ID of the path if it is the one
+// of the current user. Other organizations are reported as not found, as
+// their existence is none of the user's business.
+func ownOrganization(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return "", false
+	}
+	if chi.URLParam(r, "id") != tenant {
+		problem.Status(w, r, http.StatusNotFound)
+		return "", false
+	}
+	return tenant, true
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// organizationDBStub serves the default organization, failing its lookups
+// with findErr and the organizations created with writeErr.
+type organizationDBStub struct {
+	findErr  error
+	writeErr error
+}
+
+func (s *organizationDBStub) Create(*entity.Organization, *entity.User) error { return s.writeErr }
+
+func (s *organizationDBStub) FindByID(id string) (*entity.Organization, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return &entity.Organization{ID: entity.DefaultOrganizationID, Name: "Default"}, nil
+}
+
+const newOrganization = `{"name":"Acme","admin":` + newUser + `}`
+
+func TestOrganizationHandler_ErrorStatuses(t *testing.T) {
+	own := entity.DefaultOrganizationID.String()
+	tests := []struct {
+		name     string
+		handler  func(*OrganizationHandler) http.HandlerFunc
+		id       string
+		body     string
+		findErr  error
+		writeErr error
+		status   int
+	}{
+		{"create", createOrganization, "", newOrganization, nil, nil, http.StatusCreated},
+		{"create without name", createOrganization, "", `{"name":"","admin":` + newUser + `}`, nil, nil, http.StatusBadRequest},
+		{"create weak admin password", createOrganization, "", `{"name":"Acme","admin":{"name":"Jane","email":"jane@j.com","password":"short"}}`, nil, nil, http.StatusBadRequest},
+		{"create not json", createOrganization, "", `{`, nil, nil, http.StatusBadRequest},
+		{"create admin email taken", createOrganization, "", newOrganization, nil, database.ErrConflict, http.StatusConflict},
+		{"create unavailable", createOrganization, "", newOrganization, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"create failure", createOrganization, "", newOrganization, nil, errDriver, http.StatusInternalServerError},
+
+		{"get", getOrganization, own, "", nil, nil, http.StatusOK},
+		{"get another organization", getOrganization, pkgEntity.NewID().String(), "", nil, nil, http.StatusNotFound},
+		{"get not found", getOrganization, own, "", database.ErrNotFound, nil, http.StatusNotFound},
+		{"get unavailable", getOrganization, own, "", database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"get failure", getOrganization, own, "", errDriver, nil, http.StatusInternalServerError},
+
+		{"create member", createMember, own, newUser, nil, nil, http.StatusCreated},
+		{"create member of another organization", createMember, pkgEntity.NewID().String(), newUser, nil, nil, http.StatusNotFound},
+		{"create member invalid email", createMember, own, `{"name":"Jane","email":"jane","password":"s3cure-pass"}`, nil, nil, http.StatusBadRequest},
+		{"create member email taken", createMember, own, newUser, nil, database.ErrConflict, http.StatusConflict},
+		{"create member unavailable", createMember, own, newUser, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"create member failure", createMember, own, newUser, nil, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewOrganizationHandler(
+				&organizationDBStub{findErr: tt.findErr, writeErr: tt.writeErr},
+				&userDBStub{writeErr: tt.writeErr},
+			)
+			r := httptest.NewRequest(http.MethodPost, "/organizations/"+tt.id, strings.NewReader(tt.body))
+			r = authenticate(t, withURLParam(r, "id", tt.id), pkgEntity.NewID().String(), entity.RoleAdmin)
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func createOrganization(h *OrganizationHandler) http.HandlerFunc { return h.CreateOrganization }
+func getOrganization(h *OrganizationHandler) http.HandlerFunc    { return h.GetOrganization }
+func createMember(h *OrganizationHandler) http.HandlerFunc       { return h.CreateMember }
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+var errWrongPassword = errors.New("the current password is wrong")
+
+// ChangePassword godoc
+// @Summary Change the password of the current user
+// @Description Replace the password of the user the access token was issued to, who must give the current one. Every other login of the user is revoked.
+// @Tags users
+// @Accept json
+// @Param input body dto.ChangePasswordInput true "Passwords"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/password [post]
+// @Security ApiKeyAuth
+func (uh *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ChangePasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, ok := uh.me(w, r)
+	if !ok {
+		return
+	}
+	if err := u.ComparePassword(input.CurrentPassword); err != nil {
+		problem.Write(w, r, problem.Invalid("current_password", errWrongPassword))
+		return
+	}
+	err := u.SetPassword(input.NewPassword)
+	if errors.Is(err, entity.ErrWeakPassword) {
+		problem.Write(w, r, problem.Invalid("new_password", err))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	err = uh.UserDB.ForTenant(u.OrganizationID.String()).UpdatePassword(u.ID.String(), u.Password)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	session, _ := claims["sid"].(string)
+	if err := uh.TokenDB.RevokeUser(u.ID.String(), session); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword godoc
+// @Summary Ask for a password reset
+// @Description Mail a single-use token to reset the password of the user with the email, voiding the tokens mailed before. The response is the same, and as quick, whether or not a user has the email, so as not to tell who is registered. Too many requests for an email or from a client are answered 429 for a time.
+// @Tags users
+// @Accept json
+// @Param input body dto.ForgotPasswordInput true "Email"
+// @Success 202
+// @Failure 400 {object} problem.Problem
+// @Failure 429 {object} problem.Problem "Too many requests"
+// @Failure 500 {object} problem.Problem
+// @Router /users/password/forgot [post]
+func (uh *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ForgotPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	retryAfter, err := uh.throttleReset(entity.NormalizeEmail(input.Email), r)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		writeRetryAfter(w, r, retryAfter, entity.ErrResetThrottled)
+		return
+	}
+	u, err := uh.UserDB.FindByEmail(input.Email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, err)
+		return
+	}
+	// The token is stored and mailed after responding, so that known
+	// emails are not answered more slowly than unknown ones.
+	if err == nil {
+		go uh.sendResetToken(u)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendResetToken issues a password reset token to the user and mails it.
+// Failures are only logged, as reporting them would tell that the user
+// exists: the user can ask again.
+func (uh *UserHandler) sendResetToken(u *entity.User) {
+	token, tokenString, err := entity.NewPasswordResetToken(u.ID, uh.resetTTL())
+	if err != nil {
+		log.Printf("could not issue a password reset token to user %s: %v", u.ID, err)
+		return
+	}
+	if err := uh.TokenDB.CreatePasswordResetToken(token); err != nil {
+		log.Printf("could not store the password reset token of user %s: %v", u.ID, err)
+		return
+	}
+	if err := uh.Mailer.Send(uh.resetMessage(u, tokenString)); err != nil {
+		log.Printf("could not mail the password reset token of user %s: %v", u.ID, err)
+	}
+}
+
+// ResetPassword godoc
+// @Summary Reset a password
+// @Description Replace the password of a user with a token mailed on forgot. The token can only be used once, and every login of the user is revoked.
+// @Tags users
+// @Accept json
+// @Param input body dto.ResetPasswordInput true "Token and password"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/password/reset [post]
+func (uh *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ResetPasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	token, err := uh.TokenDB.FindPasswordResetToken(entity.HashToken(input.Token))
+	if errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, entity.ErrInvalidResetToken)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := token.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, err := uh.UserDB.FindByID(token.UserID.String())
+	if errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, entity.ErrInvalidResetToken)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := u.SetPassword(input.Password); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.TokenDB.ResetPassword(token, u.Password); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (uh *UserHandler) resetTTL() time.Duration {
+	return time.Duration(uh.ResetExpiresIn) * time.Minute
+}
+
+// resetMessage returns the mail carrying a password reset token, as a link
+// to ResetURL if there is one.
+func (uh *UserHandler) resetMessage(u *entity.User, token string) mail.Message {
+	reset := token
+	if uh.ResetURL != "" {
+		reset = uh.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+	return mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Someone, hopefully you, asked to reset your password. Use this token within %d minutes to choose a new one:\n\n"+
+			"%s\n\n"+
+			"If it was not you, ignore this email: your password is unchanged.\n",
+			u.Name, uh.ResetExpiresIn, reset),
+	}
+}
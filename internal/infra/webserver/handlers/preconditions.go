@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+)
+
+// productETag is the entity tag of the current version of the product.
+func productETag(p *entity.Product) string {
+	return `"` + strconv.Itoa(p.Version) + `"`
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header value,
+// either "*" or a comma separated list of entity tags, matches tag. Weak
+// tags are only accepted when weak is set, as RFC 9110 requires strong
+// comparison for If-Match and weak comparison for If-None-Match.
+func matchesETag(header, tag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
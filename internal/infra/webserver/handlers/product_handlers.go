@@ -2,17 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ThalesLoreto/product-api/internal/dto"
 	"github.com/ThalesLoreto/product-api/internal/entity"
 	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
 	"github.com/go-chi/chi/v5"
 )
 
 type ProductHandler struct {
 	ProductDB database.ProductInterface
+	// RequireIfMatch rejects updates and deletions without an If-Match
+	// header with 428 Precondition Required.
+	RequireIfMatch bool
 }
 
 func NewProductHandler(db database.ProductInterface) *ProductHandler {
@@ -21,6 +30,17 @@ func NewProductHandler(db database.ProductInterface) *ProductHandler {
 	}
 }
 
+// productDB returns the repository confined to the organization of the
+// request, or writes the response and returns false if it has none.
+func (ph *ProductHandler) productDB(w http.ResponseWriter, r *http.Request) (database.ProductInterface, bool) {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	return ph.ProductDB.ForTenant(tenant), true
+}
+
 // CreateProduct godoc
 // @Summary Create a product
 // @Description Create a product
@@ -29,25 +49,50 @@ func NewProductHandler(db database.ProductInterface) *ProductHandler {
 // @Produce json
 // @Param input body dto.CreateProductInput true "Product Data"
 // @Success 201 {string} string
-// @Failure 400 {string} string
-// @Failure 500 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /products [post]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
 func (ph *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
+	ownerID, _, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
 	var product dto.CreateProductInput
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err = json.NewDecoder(r.Body).Decode(&product)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
-	p, err := entity.NewProduct(product.Name, product.Price)
+	price, err := pkgEntity.NewMoney(product.Price.Amount, product.Price.Currency)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
-	err = ph.ProductDB.Create(p)
+	p, err := entity.NewProduct(product.Name, price)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
+		return
+	}
+	p.OwnerID = &ownerID
+	for _, categoryID := range product.CategoryIDs {
+		id, err := pkgEntity.ParseID(categoryID)
+		if err != nil {
+			problem.Error(w, r, database.ErrCategoryNotFound)
+			return
+		}
+		p.Categories = append(p.Categories, entity.Category{ID: id})
+	}
+	err = productDB.Create(p)
+	if err != nil {
+		problem.Error(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -55,38 +100,88 @@ func (ph *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request)
 
 // GetAllProducts godoc
 // @Summary Get all products
-// @Description Get all products
+// @Description Get all products, optionally filtered and sorted
 // @Tags products
 // @Accept json
 // @Produce json
+// @Param cursor query string false "Opaque cursor from next_cursor or prev_cursor"
 // @Param page query string false "Page"
 // @Param limit query string false "Limit"
-// @Param sort query string false "Sort"
-// @Success 200 {array} entity.Product
-// @Failure 500 {string} string
+// @Param sort query string false "Comma separated fields among name, price and created_at, prefixed with - for descending order"
+// @Param name query string false "Name contains"
+// @Param price_min query int false "Minimum price in minor units"
+// @Param price_max query int false "Maximum price in minor units"
+// @Param currency query string false "Price currency, required with price_min and price_max"
+// @Param created_after query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_before query string false "Created before (RFC 3339 or YYYY-MM-DD)"
+// @Param category query string false "Category ID"
+// @Param include_descendants query bool false "Include products of subcategories"
+// @Param include_deleted query bool false "Include deleted products, for admins only"
+// @Success 200 {object} dto.ProductPageOutput
+// @Header 200 {string} Link "RFC 8288 links to the next and previous pages"
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /products [get]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
 func (ph *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
-	page := r.URL.Query().Get("page")
-	limit := r.URL.Query().Get("limit")
-	sort := r.URL.Query().Get("sort")
-
-	pageInt, err := strconv.Atoi(page)
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
+	query, err := parseProductQuery(r.URL.Query())
 	if err != nil {
-		pageInt = 0
+		problem.Error(w, r, err)
+		return
+	}
+	if query.IncludeDeleted && !ph.seesDeleted(w, r) {
+		return
+	}
+	ph.writePage(w, r, productDB, query)
+}
+
+// GetMyProducts godoc
+// @Summary Get my products
+// @Description Get the products created by the current user, accepting the same parameters as GET /products
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param cursor query string false "Opaque cursor from next_cursor or prev_cursor"
+// @Param page query string false "Page"
+// @Param limit query string false "Limit"
+// @Param sort query string false "Comma separated fields among name, price and created_at, prefixed with - for descending order"
+// @Param name query string false "Name contains"
+// @Param include_deleted query bool false "Include deleted products, for admins only"
+// @Success 200 {object} dto.ProductPageOutput
+// @Header 200 {string} Link "RFC 8288 links to the next and previous pages"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/products [get]
+// @Security ApiKeyAuth
+func (ph *ProductHandler) GetMyProducts(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
 	}
-	limitInt, err := strconv.Atoi(limit)
+	ownerID, _, err := currentUser(r)
 	if err != nil {
-		limitInt = 0
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
 	}
-	products, err := ph.ProductDB.FindAll(pageInt, limitInt, sort)
+	query, err := parseProductQuery(r.URL.Query())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(products)
+	if query.IncludeDeleted && !ph.seesDeleted(w, r) {
+		return
+	}
+	query.OwnerID = ownerID.String()
+	ph.writePage(w, r, productDB, query)
 }
 
 // GetProduct godoc
@@ -96,20 +191,45 @@ func (ph *ProductHandler) GetAllProducts(w http.ResponseWriter, r *http.Request)
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param include_deleted query bool false "Also find the product if it was deleted, for admins only"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} entity.Product
-// @Failure 400 {string} string
-// @Failure 404 {string} string
+// @Header 200 {string} ETag "Version of the product"
+// @Success 304 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
 // @Router /products/{id} [get]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
 func (ph *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Status(w, r, http.StatusBadRequest)
 		return
 	}
-	p, err := ph.ProductDB.FindByID(id)
+	var p *entity.Product
+	var err error
+	if r.URL.Query().Get("include_deleted") == "true" {
+		if !ph.seesDeleted(w, r) {
+			return
+		}
+		p, err = productDB.FindByIDUnscoped(id)
+	} else {
+		p, err = productDB.FindByID(id)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		problem.Error(w, r, err)
+		return
+	}
+	etag := productETag(p)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && matchesETag(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -118,60 +238,401 @@ func (ph *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateProduct godoc
-// @Summary Update a product
-// @Description Update a product
+// @Summary Replace a product
+// @Description Replace the name, price and categories of a product, omitted categories being removed
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
 // @Param input body dto.UpdateProductInput true "Product Data"
-// @Success 200 {string} string
-// @Failure 400 {string} string
-// @Failure 500 {string} string
+// @Param If-Match header string false "ETag of the version being updated"
+// @Success 200 {object} entity.Product
+// @Header 200 {string} ETag "New version of the product"
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 428 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /products/{id} [put]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
 func (ph *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Status(w, r, http.StatusBadRequest)
 		return
 	}
-	var fields dto.UpdateProductInput
-	err := json.NewDecoder(r.Body).Decode(&fields)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	p, ok := ph.modifiable(w, r, productDB, id, false)
+	if !ok {
 		return
 	}
-	err = ph.ProductDB.Update(id, fields)
+	version, ok := ph.ifMatch(w, r, p)
+	if !ok {
+		return
+	}
+	var input dto.UpdateProductInput
+	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	ph.replace(w, r, productDB, p, version, input)
+}
+
+// PatchProduct godoc
+// @Summary Update a product
+// @Description Update some fields of a product with a JSON merge patch (RFC 7386), null removing the categories
+// @Tags products
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param input body dto.UpdateProductInput true "Merge patch of the product data"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Success 200 {object} entity.Product
+// @Header 200 {string} ETag "New version of the product"
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 415 {object} problem.Problem
+// @Failure 428 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/{id} [patch]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (ph *ProductHandler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
+	if !isMergePatch(r) {
+		w.Header().Set("Accept-Patch", MergePatchContentType)
+		problem.Write(w, r, problem.New(http.StatusUnsupportedMediaType, "the request body must be "+MergePatchContentType))
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	p, ok := ph.modifiable(w, r, productDB, id, false)
+	if !ok {
+		return
+	}
+	version, ok := ph.ifMatch(w, r, p)
+	if !ok {
+		return
+	}
+	var patch json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	var input dto.UpdateProductInput
+	if err := applyMergePatch(productInput(p), patch, &input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	ph.replace(w, r, productDB, p, version, input)
 }
 
 // DeleteProduct godoc
 // @Summary Delete a product
-// @Description Delete a product
+// @Description Soft delete a product, which can be restored until it is purged
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 {string} string
-// @Failure 400 {string} string
-// @Failure 500 {string} string
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 428 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /products/{id} [delete]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
 func (ph *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	p, ok := ph.modifiable(w, r, productDB, id, false)
+	if !ok {
+		return
+	}
+	version, ok := ph.ifMatch(w, r, p)
+	if !ok {
+		return
+	}
+	err := productDB.Delete(id, version)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RestoreProduct godoc
+// @Summary Restore a product
+// @Description Restore a deleted product that has not been purged yet
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} entity.Product
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/{id}/restore [post]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (ph *ProductHandler) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	productDB, ok := ph.productDB(w, r)
+	if !ok {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	if _, ok := ph.modifiable(w, r, productDB, id, true); !ok {
+		return
+	}
+	p, err := productDB.Restore(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writePage responds with the page of products matching the query.
+func (ph *ProductHandler) writePage(w http.ResponseWriter, r *http.Request, productDB database.ProductInterface, query database.ProductQuery) {
+	page, err := productDB.FindPage(query)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if links := pageLinks(r.URL, page); links != "" {
+		w.Header().Set("Link", links)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.ProductPageOutput{
+		Items:      page.Items,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Total:      page.Total,
+	})
+}
+
+// seesDeleted checks that the current user may see deleted products, which
+// only admins may. When they may not it writes the response and returns
+// false.
+func (ph *ProductHandler) seesDeleted(w http.ResponseWriter, r *http.Request) bool {
+	_, role, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return false
+	}
+	if !role.Includes(entity.RoleAdmin) {
+		problem.Write(w, r, problem.New(http.StatusForbidden, "only admins may see deleted products"))
+		return false
+	}
+	return true
+}
+
+// modifiable loads the product a request modifies and checks that the
+// current user may modify it. When the request must not proceed it writes
+// the response and returns false.
+func (ph *ProductHandler) modifiable(w http.ResponseWriter, r *http.Request, productDB database.ProductInterface, id string, unscoped bool) (*entity.Product, bool) {
+	userID, role, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	var p *entity.Product
+	if unscoped {
+		p, err = productDB.FindByIDUnscoped(id)
+	} else {
+		p, err = productDB.FindByID(id)
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return nil, false
+	}
+	if !p.ModifiableBy(userID, role) {
+		problem.Write(w, r, problem.New(http.StatusForbidden, "only the owner of the product or an admin may modify it"))
+		return nil, false
+	}
+	return p, true
+}
+
+// ifMatch evaluates the If-Match header of a request modifying the product
+// and returns the version the modification is conditional on, 0 meaning
+// unconditional. When the request must not proceed it writes the response
+// and returns false.
+func (ph *ProductHandler) ifMatch(w http.ResponseWriter, r *http.Request, p *entity.Product) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if ph.RequireIfMatch {
+			problem.Write(w, r, problem.New(http.StatusPreconditionRequired, "the If-Match header is required"))
+			return 0, false
+		}
+		return 0, true
+	}
+	if !matchesETag(header, productETag(p), false) {
+		w.Header().Set("ETag", productETag(p))
+		problem.Error(w, r, database.ErrVersionMismatch)
+		return 0, false
+	}
+	return p.Version, true
+}
+
+// replace validates the product with the fields of input in place of its
+// own and stores them, writing the updated product as the response.
+func (ph *ProductHandler) replace(w http.ResponseWriter, r *http.Request, productDB database.ProductInterface, p *entity.Product, version int, input dto.UpdateProductInput) {
+	price, err := pkgEntity.NewMoney(input.Price.Amount, input.Price.Currency)
+	if err != nil {
+		problem.Error(w, r, err)
 		return
 	}
-	err := ph.ProductDB.Delete(id)
+	updated := *p
+	updated.Name = input.Name
+	updated.Price = price
+	if err := updated.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	id := p.ID.String()
+	// A map rather than the product so that every field is written, zero
+	// values included.
+	err = productDB.Replace(id, version, map[string]interface{}{
+		"name":           updated.Name,
+		"price_amount":   updated.Price.Amount,
+		"price_currency": updated.Price.Currency,
+	}, input.CategoryIDs)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
+	saved, err := productDB.FindByID(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(saved))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// productInput returns the modifiable fields of the product, the document
+// a merge patch applies to.
+func productInput(p *entity.Product) dto.UpdateProductInput {
+	categoryIDs := make([]string, 0, len(p.Categories))
+	for _, category := range p.Categories {
+		categoryIDs = append(categoryIDs, category.ID.String())
+	}
+	return dto.UpdateProductInput{Name: p.Name, Price: p.Price, CategoryIDs: categoryIDs}
+}
+
+func parseProductQuery(values url.Values) (database.ProductQuery, error) {
+	query := database.ProductQuery{
+		NameContains:       values.Get("name"),
+		Currency:           values.Get("currency"),
+		CategoryID:         values.Get("category"),
+		IncludeDescendants: values.Get("include_descendants") == "true",
+		IncludeDeleted:     values.Get("include_deleted") == "true",
+		Cursor:             values.Get("cursor"),
+	}
+	// Amounts are only comparable within a currency, which must be known
+	// rather than match nothing.
+	if query.Currency != "" && !pkgEntity.IsCurrency(strings.ToUpper(query.Currency)) {
+		return query, problem.Invalid("currency", fmt.Errorf("%w: %q", pkgEntity.ErrUnknownCurrency, query.Currency))
+	}
+	var err error
+	if query.Page, err = strconv.Atoi(values.Get("page")); err != nil {
+		query.Page = 0
+	}
+	if query.Limit, err = strconv.Atoi(values.Get("limit")); err != nil {
+		query.Limit = 0
+	}
+	if query.Sort, err = database.ParseSort(values.Get("sort")); err != nil {
+		return query, err
+	}
+	if query.PriceMin, err = parseInt64Param(values, "price_min"); err != nil {
+		return query, err
+	}
+	if query.PriceMax, err = parseInt64Param(values, "price_max"); err != nil {
+		return query, err
+	}
+	if query.CreatedAfter, err = parseTimeParam(values, "created_after"); err != nil {
+		return query, err
+	}
+	if query.CreatedBefore, err = parseTimeParam(values, "created_before"); err != nil {
+		return query, err
+	}
+	return query, query.Validate()
+}
+
+func parseInt64Param(values url.Values, name string) (*int64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, problem.Invalid(name, fmt.Errorf("invalid %s: %q", name, value))
+	}
+	return &n, nil
+}
+
+func parseTimeParam(values url.Values, name string) (*time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, problem.Invalid(name, fmt.Errorf("invalid %s: %q", name, value))
+}
+
+// pageLinks builds an RFC 8288 Link header pointing at the next and previous
+// pages, keeping every other query parameter of the request.
+func pageLinks(u *url.URL, page *database.ProductPage) string {
+	link := func(cursor, rel string) string {
+		values := u.Query()
+		values.Del("page")
+		values.Set("cursor", cursor)
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, values.Encode(), rel)
+	}
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, link(page.NextCursor, "next"))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, link(page.PrevCursor, "prev"))
+	}
+	return strings.Join(links, ", ")
 }
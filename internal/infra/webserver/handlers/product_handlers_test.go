@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// productDBStub serves a single product, failing its lookups with findErr
+// and its modifications with writeErr. Other methods are not implemented.
+type productDBStub struct {
+	database.ProductInterface
+	product  *entity.Product
+	findErr  error
+	writeErr error
+}
+
+func (s *productDBStub) ForTenant(string) database.ProductInterface { return s }
+
+func (s *productDBStub) FindByID(string) (*entity.Product, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return s.product, nil
+}
+
+func (s *productDBStub) FindByIDUnscoped(id string) (*entity.Product, error) {
+	return s.FindByID(id)
+}
+
+func (s *productDBStub) Update(string, int, interface{}) error { return s.writeErr }
+
+func (s *productDBStub) Replace(string, int, interface{}, []string) error { return s.writeErr }
+
+func (s *productDBStub) Delete(string, int) error { return s.writeErr }
+
+func (s *productDBStub) Restore(id string) (*entity.Product, error) {
+	if s.writeErr != nil {
+		return nil, s.writeErr
+	}
+	return s.FindByID(id)
+}
+
+const replacement = `{"name":"Renamed","price":{"amount":2000,"currency":"USD"}}`
+
+// newAuthenticatedRequest returns a request for the product made by an
+// editor of the default organization, as the router would pass it on.
+func newAuthenticatedRequest(t *testing.T, method string, p *entity.Product, body string) *http.Request {
+	r := httptest.NewRequest(method, "/products/"+p.ID.String(), strings.NewReader(body))
+	if method == http.MethodPatch {
+		r.Header.Set("Content-Type", MergePatchContentType)
+	}
+	return authenticate(t, withURLParam(r, "id", p.ID.String()), p.OwnerID.String(), entity.RoleEditor)
+}
+
+// authenticate returns the request with the access token of the user, of
+// the given role in the default organization, as the router would pass it
+// on.
+func authenticate(t *testing.T, r *http.Request, userID string, role entity.Role) *http.Request {
+	token := jwt.New()
+	claims := map[string]interface{}{
+		"sub":    userID,
+		"role":   string(role),
+		"tenant": entity.DefaultOrganizationID.String(),
+	}
+	for k, v := range claims {
+		if err := token.Set(k, v); err != nil {
+			t.Fatalf("could not set claim %s: %v", k, err)
+		}
+	}
+	return r.WithContext(jwtauth.NewContext(r.Context(), token, nil))
+}
+
+// withURLParam returns the request with the URL parameter chi would have
+// routed it with.
+func withURLParam(r *http.Request, name, value string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add(name, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+// errDriver is an error of the database driver, which must not reach the
+// clients.
+var errDriver = errors.New("near \"SELEC\": syntax error")
+
+// assertStatus checks the status of the response and that error responses
+// are problem details, without the text of errDriver.
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+	if status >= http.StatusBadRequest {
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		assert.NotContains(t, w.Body.String(), "SELEC", "driver errors must not be exposed")
+	}
+}
+
+func newOwnedProduct(t *testing.T) *entity.Product {
+	price, _ := pkgEntity.NewMoney(1000, "USD")
+	p, err := entity.NewProduct("Product", price)
+	if err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+	ownerID := pkgEntity.NewID()
+	p.OwnerID = &ownerID
+	return p
+}
+
+func TestProductHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(*ProductHandler) http.HandlerFunc
+		method   string
+		body     string
+		findErr  error
+		writeErr error
+		status   int
+	}{
+		{"get found", getProduct, http.MethodGet, "", nil, nil, http.StatusOK},
+		{"get not found", getProduct, http.MethodGet, "", database.ErrNotFound, nil, http.StatusNotFound},
+		{"get unavailable", getProduct, http.MethodGet, "", database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"get failure", getProduct, http.MethodGet, "", errDriver, nil, http.StatusInternalServerError},
+
+		{"update", updateProduct, http.MethodPut, replacement, nil, nil, http.StatusOK},
+		{"update lookup not found", updateProduct, http.MethodPut, replacement, database.ErrNotFound, nil, http.StatusNotFound},
+		{"update lookup unavailable", updateProduct, http.MethodPut, replacement, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"update not found", updateProduct, http.MethodPut, replacement, nil, database.ErrNotFound, http.StatusNotFound},
+		{"update conflict", updateProduct, http.MethodPut, replacement, nil, database.ErrConflict, http.StatusConflict},
+		{"update version mismatch", updateProduct, http.MethodPut, replacement, nil, database.ErrVersionMismatch, http.StatusPreconditionFailed},
+		{"update unavailable", updateProduct, http.MethodPut, replacement, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"update failure", updateProduct, http.MethodPut, replacement, nil, errDriver, http.StatusInternalServerError},
+
+		{"update without price", updateProduct, http.MethodPut, `{"name":"Renamed"}`, nil, nil, http.StatusBadRequest},
+		{"update negative price", updateProduct, http.MethodPut, `{"name":"Renamed","price":{"amount":-1,"currency":"USD"}}`, nil, nil, http.StatusBadRequest},
+		{"update unknown currency", updateProduct, http.MethodPut, `{"name":"Renamed","price":{"amount":2000,"currency":"XYZ"}}`, nil, nil, http.StatusBadRequest},
+
+		{"patch", patchProduct, http.MethodPatch, `{"name":"Renamed"}`, nil, nil, http.StatusOK},
+		{"patch price amount", patchProduct, http.MethodPatch, `{"price":{"amount":2500}}`, nil, nil, http.StatusOK},
+		{"patch removing name", patchProduct, http.MethodPatch, `{"name":null}`, nil, nil, http.StatusBadRequest},
+		{"patch negative price", patchProduct, http.MethodPatch, `{"price":{"amount":-1}}`, nil, nil, http.StatusBadRequest},
+		{"patch unknown currency", patchProduct, http.MethodPatch, `{"price":{"currency":"XYZ"}}`, nil, nil, http.StatusBadRequest},
+		{"patch not an object", patchProduct, http.MethodPatch, `["name"]`, nil, nil, http.StatusBadRequest},
+		{"patch not found", patchProduct, http.MethodPatch, `{"name":"Renamed"}`, database.ErrNotFound, nil, http.StatusNotFound},
+		{"patch version mismatch", patchProduct, http.MethodPatch, `{"name":"Renamed"}`, nil, database.ErrVersionMismatch, http.StatusPreconditionFailed},
+		{"patch unavailable", patchProduct, http.MethodPatch, `{"name":"Renamed"}`, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+
+		{"delete", deleteProduct, http.MethodDelete, "", nil, nil, http.StatusOK},
+		{"delete lookup not found", deleteProduct, http.MethodDelete, "", database.ErrNotFound, nil, http.StatusNotFound},
+		{"delete not found", deleteProduct, http.MethodDelete, "", nil, database.ErrNotFound, http.StatusNotFound},
+		{"delete version mismatch", deleteProduct, http.MethodDelete, "", nil, database.ErrVersionMismatch, http.StatusPreconditionFailed},
+		{"delete unavailable", deleteProduct, http.MethodDelete, "", nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"delete failure", deleteProduct, http.MethodDelete, "", nil, errDriver, http.StatusInternalServerError},
+
+		{"restore", restoreProduct, http.MethodPost, "", nil, nil, http.StatusOK},
+		{"restore not found", restoreProduct, http.MethodPost, "", database.ErrNotFound, nil, http.StatusNotFound},
+		{"restore unavailable", restoreProduct, http.MethodPost, "", nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"restore failure", restoreProduct, http.MethodPost, "", nil, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newOwnedProduct(t)
+			h := NewProductHandler(&productDBStub{product: p, findErr: tt.findErr, writeErr: tt.writeErr})
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, newAuthenticatedRequest(t, tt.method, p, tt.body))
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func getProduct(h *ProductHandler) http.HandlerFunc     { return h.GetProduct }
+func updateProduct(h *ProductHandler) http.HandlerFunc  { return h.UpdateProduct }
+func patchProduct(h *ProductHandler) http.HandlerFunc   { return h.PatchProduct }
+func deleteProduct(h *ProductHandler) http.HandlerFunc  { return h.DeleteProduct }
+func restoreProduct(h *ProductHandler) http.HandlerFunc { return h.RestoreProduct }
+
+func TestParseProductQuery_Currency(t *testing.T) {
+	query, err := parseProductQuery(url.Values{"currency": {"eur"}, "price_min": {"100"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "eur", query.Currency)
+
+	_, err = parseProductQuery(url.Values{"currency": {"XYZ"}, "price_min": {"100"}})
+	p := problem.From(err)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, "currency", p.Errors[0].Field)
+}
+
+func TestProductHandler_PatchRequiresMergePatch(t *testing.T) {
+	p := newOwnedProduct(t)
+	h := NewProductHandler(&productDBStub{product: p})
+	r := newAuthenticatedRequest(t, http.MethodPatch, p, `{"name":"Renamed"}`)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.PatchProduct(w, r)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, MergePatchContentType, w.Header().Get("Accept-Patch"))
+}
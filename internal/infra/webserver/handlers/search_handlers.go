@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+type SearchHandler struct {
+	ProductSearch database.ProductSearchInterface
+}
+
+func NewSearchHandler(search database.ProductSearchInterface) *SearchHandler {
+	return &SearchHandler{
+		ProductSearch: search,
+	}
+}
+
+// SearchProducts godoc
+// @Summary Search products
+// @Description Full-text search over product names with prefix matching, ranked by relevance
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param q query string true "Search terms"
+// @Param page query string false "Page"
+// @Param limit query string false "Limit"
+// @Success 200 {array} entity.ProductSearchResult
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/search [get]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (sh *SearchHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		problem.Write(w, r, problem.Invalid("q", errors.New("q is required")))
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 0
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 0
+	}
+	results, err := sh.ProductSearch.ForTenant(tenant).Search(q, page, limit)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
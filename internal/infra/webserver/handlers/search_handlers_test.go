@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// searchStub finds nothing, failing every search with err.
+type searchStub struct {
+	database.ProductSearchInterface
+	err error
+}
+
+func (s *searchStub) ForTenant(string) database.ProductSearchInterface { return s }
+
+func (s *searchStub) Search(string, int, int) ([]entity.ProductSearchResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []entity.ProductSearchResult{}, nil
+}
+
+func TestSearchHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name            string
+		target          string
+		unauthenticated bool
+		err             error
+		status          int
+	}{
+		{"search", "/products/search?q=pro", false, nil, http.StatusOK},
+		{"search paged", "/products/search?q=pro&page=2&limit=10", false, nil, http.StatusOK},
+		{"search without terms", "/products/search", false, nil, http.StatusBadRequest},
+		{"search without tenant", "/products/search?q=pro", true, nil, http.StatusUnauthorized},
+		{"search unavailable", "/products/search?q=pro", false, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"search failure", "/products/search?q=pro", false, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSearchHandler(&searchStub{err: tt.err})
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if !tt.unauthenticated {
+				r = authenticate(t, r, pkgEntity.NewID().String(), entity.RoleViewer)
+			}
+			w := httptest.NewRecorder()
+			h.SearchProducts(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+)
+
+type StockHandler struct {
+	StockDB database.StockInterface
+	// ProductDB looks up the products whose stock is moved, to check that
+	// the current user may modify them.
+	ProductDB database.ProductInterface
+}
+
+func NewStockHandler(db database.StockInterface, productDB database.ProductInterface) *StockHandler {
+	return &StockHandler{
+		StockDB:   db,
+		ProductDB: productDB,
+	}
+}
+
+// stockDB returns the repository confined to the organization of the
+// request, or writes the response and returns false if it has none.
+func (sh *StockHandler) stockDB(w http.ResponseWriter, r *http.Request) (database.StockInterface, bool) {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	return sh.StockDB.ForTenant(tenant), true
+}
+
+// modifiable checks that the current user may modify the product, as
+// ProductHandler does before updating it. When the request must not proceed
+// it writes the response and returns false.
+func (sh *StockHandler) modifiable(w http.ResponseWriter, r *http.Request, productID string) bool {
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return false
+	}
+	userID, role, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return false
+	}
+	p, err := sh.ProductDB.ForTenant(tenant).FindByID(productID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return false
+	}
+	if !p.ModifiableBy(userID, role) {
+		problem.Write(w, r, problem.New(http.StatusForbidden, "only the owner of the product or an admin may modify it"))
+		return false
+	}
+	return true
+}
+
+// GetStock godoc
+// @Summary Get the stock level of a product
+// @Description Get the on-hand quantity of a product
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} entity.StockLevel
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/{id}/stock [get]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (sh *StockHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	stockDB, ok := sh.stockDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	level, err := stockDB.FindLevel(id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(level)
+}
+
+// CreateStockMovement godoc
+// @Summary Record a stock movement
+// @Description Record a receipt, adjustment or sale and update the on-hand quantity. Only the owner of the product or an admin may record it.
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param input body dto.CreateStockMovementInput true "Movement Data"
+// @Success 201 {object} entity.StockLevel
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/{id}/stock/movements [post]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (sh *StockHandler) CreateStockMovement(w http.ResponseWriter, r *http.Request) {
+	stockDB, ok := sh.stockDB(w, r)
+	if !ok {
+		return
+	}
+	productID, err := pkgEntity.ParseID(chi.URLParam(r, "id"))
+	if err != nil {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	if !sh.modifiable(w, r, productID.String()) {
+		return
+	}
+	var movement dto.CreateStockMovementInput
+	err = json.NewDecoder(r.Body).Decode(&movement)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	m, err := entity.NewStockMovement(productID, entity.MovementType(movement.Type), movement.Quantity, movement.Note)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	level, err := stockDB.Record(m)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(level)
+}
+
+// GetStockMovements godoc
+// @Summary Get the stock ledger of a product
+// @Description Get the stock movements of a product, oldest first
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param page query string false "Page"
+// @Param limit query string false "Limit"
+// @Success 200 {array} entity.StockMovement
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /products/{id}/stock/movements [get]
+// @Security ApiKeyAuth
+// @Security APIKeyHeader
+func (sh *StockHandler) GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	stockDB, ok := sh.stockDB(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 0
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 0
+	}
+	movements, err := stockDB.FindMovements(id, page, limit)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(movements)
+}
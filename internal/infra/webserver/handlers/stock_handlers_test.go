@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+// stockDBStub serves an empty stock, failing its lookups with findErr and
+// the movements recorded with writeErr. Other methods are not implemented.
+type stockDBStub struct {
+	database.StockInterface
+	findErr  error
+	writeErr error
+}
+
+func (s *stockDBStub) ForTenant(string) database.StockInterface { return s }
+
+func (s *stockDBStub) FindLevel(string) (*entity.StockLevel, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return &entity.StockLevel{}, nil
+}
+
+func (s *stockDBStub) FindMovements(string, int, int) ([]entity.StockMovement, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return []entity.StockMovement{}, nil
+}
+
+func (s *stockDBStub) Record(*entity.StockMovement) (*entity.StockLevel, error) {
+	if s.writeErr != nil {
+		return nil, s.writeErr
+	}
+	return &entity.StockLevel{}, nil
+}
+
+const receipt = `{"type":"receipt","quantity":5}`
+
+func TestStockHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(*StockHandler) http.HandlerFunc
+		id         string
+		body       string
+		stranger   bool
+		productErr error
+		findErr    error
+		writeErr   error
+		status     int
+	}{
+		{"get", getStock, "", "", false, nil, nil, nil, http.StatusOK},
+		{"get not found", getStock, "", "", false, nil, database.ErrNotFound, nil, http.StatusNotFound},
+		{"get unavailable", getStock, "", "", false, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"get failure", getStock, "", "", false, nil, errDriver, nil, http.StatusInternalServerError},
+
+		{"movements", getStockMovements, "", "", false, nil, nil, nil, http.StatusOK},
+		{"movements unavailable", getStockMovements, "", "", false, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"movements failure", getStockMovements, "", "", false, nil, errDriver, nil, http.StatusInternalServerError},
+
+		{"create", createStockMovement, "", receipt, false, nil, nil, nil, http.StatusCreated},
+		{"create invalid id", createStockMovement, "not-an-id", receipt, false, nil, nil, nil, http.StatusBadRequest},
+		{"create invalid type", createStockMovement, "", `{"type":"theft","quantity":5}`, false, nil, nil, nil, http.StatusBadRequest},
+		{"create without quantity", createStockMovement, "", `{"type":"receipt"}`, false, nil, nil, nil, http.StatusBadRequest},
+		{"create not json", createStockMovement, "", `{`, false, nil, nil, nil, http.StatusBadRequest},
+		{"create product of another user", createStockMovement, "", receipt, true, nil, nil, nil, http.StatusForbidden},
+		{"create product not found", createStockMovement, "", receipt, false, database.ErrNotFound, nil, nil, http.StatusNotFound},
+		{"create product unavailable", createStockMovement, "", receipt, false, database.ErrUnavailable, nil, nil, http.StatusServiceUnavailable},
+		{"create insufficient stock", createStockMovement, "", receipt, false, nil, nil, entity.ErrInsufficientStock, http.StatusConflict},
+		{"create unavailable", createStockMovement, "", receipt, false, nil, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"create failure", createStockMovement, "", receipt, false, nil, nil, errDriver, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newOwnedProduct(t)
+			h := NewStockHandler(
+				&stockDBStub{findErr: tt.findErr, writeErr: tt.writeErr},
+				&productDBStub{product: p, findErr: tt.productErr},
+			)
+			id, userID := p.ID.String(), p.OwnerID.String()
+			if tt.id != "" {
+				id = tt.id
+			}
+			if tt.stranger {
+				userID = pkgEntity.NewID().String()
+			}
+			r := httptest.NewRequest(http.MethodPost, "/products/"+id+"/stock", strings.NewReader(tt.body))
+			r = authenticate(t, withURLParam(r, "id", id), userID, entity.RoleEditor)
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func getStock(h *StockHandler) http.HandlerFunc            { return h.GetStock }
+func getStockMovements(h *StockHandler) http.HandlerFunc   { return h.GetStockMovements }
+func createStockMovement(h *StockHandler) http.HandlerFunc { return h.CreateStockMovement }
@@ -2,66 +2,318 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/ThalesLoreto/product-api/internal/dto"
 	"github.com/ThalesLoreto/product-api/internal/entity"
 	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 )
 
+var errEmailTaken = errors.New("the email is already registered")
+
 type UserHandler struct {
-	UserDB       database.UserInterface
-	Jwt          *jwtauth.JWTAuth
-	JwtExpiresIn int
+	UserDB           database.UserInterface
+	TokenDB          database.TokenInterface
+	Jwt              *keys.Set
+	JwtExpiresIn     int
+	RefreshExpiresIn int
+	// Mailer delivers the password reset tokens, which expire after
+	// ResetExpiresIn minutes. The mail links to ResetURL with the token
+	// as query parameter if it is set, and only gives the token otherwise.
+	Mailer         mail.Mailer
+	ResetExpiresIn int
+	ResetURL       string
+	// Verifier mails new users a link to verify their email, if set. Users
+	// who did not verify it cannot log in if RequireVerifiedEmail.
+	Verifier             *EmailVerifier
+	RequireVerifiedEmail bool
+	// Throttle counts failed logins, if set, to lock out an email as
+	// AccountLockout says and a client IP address as ClientLockout says.
+	// Lockouts are recorded to Audit. It counts password reset requests
+	// apart, to hold off an email as ResetAccountLockout says and a client
+	// IP address as ResetClientLockout says.
+	Throttle            database.LoginThrottleInterface
+	Audit               database.AuditInterface
+	AccountLockout      entity.LockoutPolicy
+	ClientLockout       entity.LockoutPolicy
+	ResetAccountLockout entity.LockoutPolicy
+	ResetClientLockout  entity.LockoutPolicy
+	// MFA stores the TOTP secrets and recovery codes of the users, if set,
+	// letting them require a second factor on login. Authenticator apps name
+	// their accounts after MFAIssuer. Their logins answer a challenge token
+	// signed with MFASecret and valid for MFAExpiresIn, to be given back
+	// along with a code.
+	MFA          database.MFAInterface
+	MFAIssuer    string
+	MFASecret    []byte
+	MFAExpiresIn time.Duration
 }
 
-func NewUserHandler(db database.UserInterface, jwt *jwtauth.JWTAuth, expiresIn int) *UserHandler {
+func NewUserHandler(db database.UserInterface, tokenDB database.TokenInterface, jwt *keys.Set, expiresIn, refreshExpiresIn int) *UserHandler {
 	return &UserHandler{
-		UserDB:       db,
-		Jwt:          jwt,
-		JwtExpiresIn: expiresIn,
+		UserDB:           db,
+		TokenDB:          tokenDB,
+		Jwt:              jwt,
+		JwtExpiresIn:     expiresIn,
+		RefreshExpiresIn: refreshExpiresIn,
 	}
 }
 
-// CreateUser godoc
+// Login godoc
 // @Summary Login user
-// @Description Login user
+// @Description Login user. Unknown emails and wrong passwords are both answered 401. After too many failed logins for an email or from a client, logins are locked out for a time that doubles on each further failure. Users with two-factor authentication get a challenge token to give back with a code to /users/login/mfa instead of the tokens.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param input body dto.LoginUserInput true "User Credentials"
 // @Success 200 {object} dto.LoginUserOutput
-// @Failure 400 {string} string
-// @Failure 404 {string} string
-// @Failure 401 {string} string
+// @Success 202 {object} dto.MFAChallengeOutput "Second factor required"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem "Email not verified"
+// @Failure 429 {object} problem.Problem "Locked out"
 // @Router /users/login [post]
 func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var user dto.LoginUserInput
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Error(w, r, err)
+		return
+	}
+	email := entity.NormalizeEmail(user.Email)
+	retryAfter, err := uh.lockedOut(email, r)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		writeLockedOut(w, r, retryAfter)
+		return
+	}
+	u, err := uh.UserDB.FindByEmail(email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, err)
+		return
+	}
+	// Unknown emails are told apart neither by the response nor by its
+	// duration.
+	if u != nil {
+		err = u.ComparePassword(user.Password)
+	} else {
+		err = entity.CompareUnknownUserPassword(user.Password)
+	}
+	if err != nil {
+		if err := uh.failLogin(email, u, r); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	// The failed logins of users with a second factor are only forgotten
+	// once they give it, lest the password alone lift their lockout.
+	if !uh.mfaRequired(u) {
+		if err := uh.resetLogin(email); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+	}
+	if uh.RequireVerifiedEmail && !u.EmailVerified() {
+		problem.Error(w, r, entity.ErrEmailNotVerified)
+		return
+	}
+	if uh.mfaRequired(u) {
+		writeMFAChallenge(w, entity.NewMFAChallenge(u, uh.MFASecret, uh.MFAExpiresIn))
+		return
+	}
+	uh.login(w, r, u)
+}
+
+// login responds with the tokens of a new session of the user.
+func (uh *UserHandler) login(w http.ResponseWriter, r *http.Request, u *entity.User) {
+	refreshToken, refreshTokenString, err := entity.NewRefreshToken(u.ID, nil, uh.refreshTTL())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.TokenDB.CreateRefreshToken(refreshToken); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	uh.writeTokens(w, r, u, refreshToken, refreshTokenString)
+}
+
+// RefreshToken godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. The refresh token can only be used once: using it again revokes every token issued since the login.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body dto.RefreshTokenInput true "Refresh Token"
+// @Success 200 {object} dto.LoginUserOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/token/refresh [post]
+func (uh *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var input dto.RefreshTokenInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.RefreshToken == "" {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	current, err := uh.TokenDB.FindRefreshToken(entity.HashToken(input.RefreshToken))
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	if err := current.Validate(); err != nil {
+		uh.rejectRefreshToken(w, r, current, err)
+		return
+	}
+	// The user is read again so that role changes apply from the next
+	// refresh on.
+	u, err := uh.UserDB.FindByID(current.UserID.String())
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	next, nextString, err := entity.NewRefreshToken(current.UserID, &current.FamilyID, uh.refreshTTL())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := uh.TokenDB.Rotate(current, next); err != nil {
+		uh.rejectRefreshToken(w, r, current, err)
+		return
+	}
+	uh.writeTokens(w, r, u, next, nextString)
+}
+
+// Logout godoc
+// @Summary Logout user
+// @Description Revoke the access token of the request and every refresh token issued since the login
+// @Tags users
+// @Success 204
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/logout [post]
+// @Security ApiKeyAuth
+func (uh *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	token, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return
+	}
+	if familyID, ok := claims["sid"].(string); ok {
+		if err := uh.TokenDB.RevokeFamily(familyID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+	}
+	if jti := token.JwtID(); jti != "" {
+		if err := uh.TokenDB.RevokeAccessToken(jti, token.Expiration()); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateUserRole godoc
+// @Summary Update user role
+// @Description Change the role of a user of the same organization. The new role applies to the access tokens issued from then on.
+// @Tags users
+// @Accept json
+// @Param id path string true "User ID"
+// @Param input body dto.UpdateUserRoleInput true "Role"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/{id}/role [put]
+// @Security ApiKeyAuth
+func (uh *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := pkgEntity.ParseID(id); err != nil {
+		problem.Status(w, r, http.StatusBadRequest)
+		return
+	}
+	var input dto.UpdateUserRoleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	role, err := entity.ParseRole(input.Role)
+	if err != nil {
+		problem.Error(w, r, err)
 		return
 	}
-	u, err := uh.UserDB.FindByEmail(user.Email)
+	tenant, err := currentTenant(r)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		problem.Status(w, r, http.StatusUnauthorized)
 		return
 	}
-	err = u.ComparePassword(user.Password)
+	err = uh.UserDB.ForTenant(tenant).UpdateRole(id, role)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		problem.Error(w, r, err)
 		return
 	}
-	_, tokenString, _ := uh.Jwt.Encode(map[string]interface{}{
-		"sub": u.ID.String(),
-		"exp": jwtauth.ExpireIn(time.Duration(uh.JwtExpiresIn) * time.Minute),
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (uh *UserHandler) refreshTTL() time.Duration {
+	return time.Duration(uh.RefreshExpiresIn) * time.Minute
+}
+
+// writeTokens responds with the refresh token and a new access token of
+// the same session. The access token carries the refresh token family as
+// its session ID so that logging out can revoke the family, and the role and
+// organization of the user for authorization.
+func (uh *UserHandler) writeTokens(w http.ResponseWriter, r *http.Request, u *entity.User, refreshToken *entity.RefreshToken, refreshTokenString string) {
+	_, tokenString, err := uh.Jwt.Encode(map[string]interface{}{
+		"sub":    u.ID.String(),
+		"role":   string(u.Role),
+		"tenant": u.OrganizationID.String(),
+		"jti":    pkgEntity.NewID().String(),
+		"sid":    refreshToken.FamilyID.String(),
+		"exp":    jwtauth.ExpireIn(time.Duration(uh.JwtExpiresIn) * time.Minute),
 	})
-	accessToken := dto.LoginUserOutput{AccessToken: tokenString}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	output := dto.LoginUserOutput{AccessToken: tokenString, RefreshToken: refreshTokenString}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(accessToken)
+	json.NewEncoder(w).Encode(output)
+}
+
+// rejectRefreshToken responds 401 to an unusable refresh token. A token
+// that was already rotated is presented again either by a thief or by its
+// owner after it was stolen, so its whole family is revoked.
+func (uh *UserHandler) rejectRefreshToken(w http.ResponseWriter, r *http.Request, token *entity.RefreshToken, err error) {
+	if errors.Is(err, entity.ErrRefreshTokenReused) {
+		if err := uh.TokenDB.RevokeFamily(token.FamilyID.String()); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+	} else if !errors.Is(err, entity.ErrInvalidRefreshToken) {
+		problem.Status(w, r, http.StatusInternalServerError)
+		return
+	}
+	problem.Status(w, r, http.StatusUnauthorized)
 }
 
 // CreateUser godoc
@@ -71,26 +323,130 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param user body dto.CreateUserInput true "User data"
-// @Success 201 {string} string "User created"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Success 201 {object} dto.UserOutput
+// @Failure 400 {object} problem.Problem "Bad request"
+// @Failure 409 {object} problem.Problem "Email already registered"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Router /users [post]
 func (uh *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.CreateUserInput
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 	u, err := entity.NewUser(user.Name, user.Email, user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 	err = uh.UserDB.Create(u)
+	if errors.Is(err, database.ErrConflict) {
+		problem.Write(w, r, problem.Conflict("email", errEmailTaken))
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	uh.Verifier.sendNow(u)
+	writeUser(w, http.StatusCreated, u)
+}
+
+// GetMe godoc
+// @Summary Get the current user
+// @Description Get the profile of the user the access token was issued to
+// @Tags users
+// @Produce json
+// @Success 200 {object} dto.UserOutput
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me [get]
+// @Security ApiKeyAuth
+func (uh *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	u, ok := uh.me(w, r)
+	if !ok {
+		return
+	}
+	writeUser(w, http.StatusOK, u)
+}
+
+// UpdateMe godoc
+// @Summary Update the current user
+// @Description Replace the name and email of the user the access token was issued to. A new email has to be verified again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param input body dto.UpdateUserInput true "User data"
+// @Success 200 {object} dto.UserOutput
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me [put]
+// @Security ApiKeyAuth
+func (uh *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var input dto.UpdateUserInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, ok := uh.me(w, r)
+	if !ok {
+		return
+	}
+	u.Name = strings.TrimSpace(input.Name)
+	emailChanged := u.Email != entity.NormalizeEmail(input.Email)
+	if emailChanged {
+		u.Email = entity.NormalizeEmail(input.Email)
+		u.EmailVerifiedAt = nil
+	}
+	if err := u.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	err := uh.UserDB.ForTenant(u.OrganizationID.String()).Update(u)
+	if errors.Is(err, database.ErrConflict) {
+		problem.Write(w, r, problem.Conflict("email", errEmailTaken))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+	if emailChanged {
+		uh.Verifier.sendNow(u)
+	}
+	writeUser(w, http.StatusOK, u)
+}
+
+// me returns the user the access token of the request was issued to, having
+// responded with an error if there is none.
+func (uh *UserHandler) me(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	id, _, err := currentUser(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	tenant, err := currentTenant(r)
+	if err != nil {
+		problem.Status(w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+	u, err := uh.UserDB.ForTenant(tenant).FindByID(id.String())
+	if err != nil {
+		problem.Error(w, r, err)
+		return nil, false
+	}
+	return u, true
+}
+
+// writeUser responds with the user, encoded as a dto.UserOutput so that its
+// password hash never leaves the server.
+func writeUser(w http.ResponseWriter, status int, u *entity.User) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.NewUserOutput(u))
 }
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
+)
+
+// userDBStub serves a single user, failing its lookups with findErr and
+// its modifications with writeErr. Other methods are not implemented.
+type userDBStub struct {
+	database.UserInterface
+	user     *entity.User
+	findErr  error
+	writeErr error
+}
+
+func (s *userDBStub) ForTenant(string) database.UserInterface { return s }
+
+func (s *userDBStub) FindByID(string) (*entity.User, error) {
+	if s.findErr != nil {
+		return nil, s.findErr
+	}
+	return s.user, nil
+}
+
+func (s *userDBStub) FindByEmail(string) (*entity.User, error) { return s.FindByID("") }
+
+func (s *userDBStub) Create(*entity.User) error { return s.writeErr }
+
+func (s *userDBStub) Update(*entity.User) error { return s.writeErr }
+
+func (s *userDBStub) UpdateRole(string, entity.Role) error { return s.writeErr }
+
+func (s *userDBStub) UpdatePassword(string, string) error { return s.writeErr }
+
+// tokenDBStub serves valid tokens of a single user, failing every call
+// with err. Other methods are not implemented.
+type tokenDBStub struct {
+	database.TokenInterface
+	user *entity.User
+	err  error
+}
+
+func (s *tokenDBStub) CreateRefreshToken(*entity.RefreshToken) error { return s.err }
+
+func (s *tokenDBStub) FindRefreshToken(string) (*entity.RefreshToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	token, _, err := entity.NewRefreshToken(s.user.ID, nil, time.Hour)
+	return token, err
+}
+
+func (s *tokenDBStub) Rotate(*entity.RefreshToken, *entity.RefreshToken) error { return s.err }
+
+func (s *tokenDBStub) RevokeUser(string, string) error { return s.err }
+
+func (s *tokenDBStub) CreatePasswordResetToken(*entity.PasswordResetToken) error { return s.err }
+
+func (s *tokenDBStub) FindPasswordResetToken(string) (*entity.PasswordResetToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	token, _, err := entity.NewPasswordResetToken(s.user.ID, time.Hour)
+	return token, err
+}
+
+func (s *tokenDBStub) ResetPassword(*entity.PasswordResetToken, string) error { return s.err }
+
+const (
+	newUser   = `{"name":"Jane","email":"jane@j.com","password":"s3cure-pass"}`
+	passwords = `{"current_password":"s3cure-pass","new_password":"an0ther-pass"}`
+	reset     = `{"token":"token","password":"an0ther-pass"}`
+)
+
+func TestUserHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(*UserHandler) http.HandlerFunc
+		body     string
+		findErr  error
+		writeErr error
+		tokenErr error
+		status   int
+	}{
+		{"login", login, `{"email":"j@j.com","password":"s3cure-pass"}`, nil, nil, nil, http.StatusOK},
+		{"login wrong password", login, `{"email":"j@j.com","password":"wrong-pass"}`, nil, nil, nil, http.StatusUnauthorized},
+		{"login unknown email", login, `{"email":"j@j.com","password":"s3cure-pass"}`, database.ErrNotFound, nil, nil, http.StatusUnauthorized},
+		{"login unavailable", login, `{"email":"j@j.com","password":"s3cure-pass"}`, database.ErrUnavailable, nil, nil, http.StatusServiceUnavailable},
+		{"login failure", login, `{"email":"j@j.com","password":"s3cure-pass"}`, errDriver, nil, nil, http.StatusInternalServerError},
+		{"login session unavailable", login, `{"email":"j@j.com","password":"s3cure-pass"}`, nil, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+		{"login not json", login, `{`, nil, nil, nil, http.StatusBadRequest},
+
+		{"refresh", refresh, `{"refresh_token":"token"}`, nil, nil, nil, http.StatusOK},
+		{"refresh without token", refresh, `{}`, nil, nil, nil, http.StatusBadRequest},
+		{"refresh unknown token", refresh, `{"refresh_token":"token"}`, nil, nil, database.ErrNotFound, http.StatusUnauthorized},
+		{"refresh deleted user", refresh, `{"refresh_token":"token"}`, database.ErrNotFound, nil, nil, http.StatusUnauthorized},
+
+		{"create", createUser, newUser, nil, nil, nil, http.StatusCreated},
+		{"create weak password", createUser, `{"name":"Jane","email":"jane@j.com","password":"short"}`, nil, nil, nil, http.StatusBadRequest},
+		{"create invalid email", createUser, `{"name":"Jane","email":"jane","password":"s3cure-pass"}`, nil, nil, nil, http.StatusBadRequest},
+		{"create email taken", createUser, newUser, nil, database.ErrConflict, nil, http.StatusConflict},
+		{"create unavailable", createUser, newUser, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"create failure", createUser, newUser, nil, errDriver, nil, http.StatusInternalServerError},
+
+		{"get me", getMe, "", nil, nil, nil, http.StatusOK},
+		{"get me not found", getMe, "", database.ErrNotFound, nil, nil, http.StatusNotFound},
+		{"get me unavailable", getMe, "", database.ErrUnavailable, nil, nil, http.StatusServiceUnavailable},
+
+		{"update me", updateMe, `{"name":"John","email":"j@j.com"}`, nil, nil, nil, http.StatusOK},
+		{"update me without name", updateMe, `{"name":" ","email":"j@j.com"}`, nil, nil, nil, http.StatusBadRequest},
+		{"update me not found", updateMe, `{"name":"John","email":"j@j.com"}`, database.ErrNotFound, nil, nil, http.StatusNotFound},
+		{"update me email taken", updateMe, `{"name":"John","email":"jane@j.com"}`, nil, database.ErrConflict, nil, http.StatusConflict},
+		{"update me unavailable", updateMe, `{"name":"John","email":"j@j.com"}`, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+
+		{"update role", updateRole, `{"role":"editor"}`, nil, nil, nil, http.StatusNoContent},
+		{"update unknown role", updateRole, `{"role":"owner"}`, nil, nil, nil, http.StatusBadRequest},
+		{"update role not found", updateRole, `{"role":"editor"}`, nil, database.ErrNotFound, nil, http.StatusNotFound},
+		{"update role unavailable", updateRole, `{"role":"editor"}`, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+
+		{"change password", changePassword, passwords, nil, nil, nil, http.StatusNoContent},
+		{"change password wrong current", changePassword, `{"current_password":"wrong-pass","new_password":"an0ther-pass"}`, nil, nil, nil, http.StatusBadRequest},
+		{"change password weak", changePassword, `{"current_password":"s3cure-pass","new_password":"short"}`, nil, nil, nil, http.StatusBadRequest},
+		{"change password unavailable", changePassword, passwords, nil, database.ErrUnavailable, nil, http.StatusServiceUnavailable},
+		{"change password revocation failure", changePassword, passwords, nil, nil, errDriver, http.StatusInternalServerError},
+
+		{"forgot", forgotPassword, `{"email":"j@j.com"}`, nil, nil, nil, http.StatusAccepted},
+		{"forgot unknown email", forgotPassword, `{"email":"j@j.com"}`, database.ErrNotFound, nil, nil, http.StatusAccepted},
+		{"forgot unavailable", forgotPassword, `{"email":"j@j.com"}`, database.ErrUnavailable, nil, nil, http.StatusServiceUnavailable},
+		// Failures to issue the token are not told apart from success.
+		{"forgot token failure", forgotPassword, `{"email":"j@j.com"}`, nil, nil, errDriver, http.StatusAccepted},
+
+		{"reset", resetPassword, reset, nil, nil, nil, http.StatusNoContent},
+		{"reset weak password", resetPassword, `{"token":"token","password":"short"}`, nil, nil, nil, http.StatusBadRequest},
+		{"reset unknown token", resetPassword, reset, nil, nil, database.ErrNotFound, http.StatusBadRequest},
+		{"reset used token", resetPassword, reset, nil, nil, entity.ErrInvalidResetToken, http.StatusBadRequest},
+		{"reset deleted user", resetPassword, reset, database.ErrNotFound, nil, nil, http.StatusBadRequest},
+		{"reset unavailable", resetPassword, reset, nil, nil, database.ErrUnavailable, http.StatusServiceUnavailable},
+	}
+	john, err := entity.NewUser("John", "j@j.com", "s3cure-pass")
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+	set, err := keys.NewSet(time.Hour, keys.NewHMAC([]byte("secret")))
+	if err != nil {
+		t.Fatalf("could not create key set: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A copy, as handlers modify the user they are served.
+			u := new(entity.User)
+			*u = *john
+			h := NewUserHandler(
+				&userDBStub{user: u, findErr: tt.findErr, writeErr: tt.writeErr},
+				&tokenDBStub{user: u, err: tt.tokenErr},
+				set, 5, 60,
+			)
+			h.Mailer = &mail.MemoryMailer{}
+			r := httptest.NewRequest(http.MethodPost, "/users/"+u.ID.String(), strings.NewReader(tt.body))
+			r = authenticate(t, withURLParam(r, "id", u.ID.String()), u.ID.String(), entity.RoleAdmin)
+			w := httptest.NewRecorder()
+			tt.handler(h)(w, r)
+
+			assertStatus(t, w, tt.status)
+		})
+	}
+}
+
+func login(h *UserHandler) http.HandlerFunc          { return h.Login }
+func refresh(h *UserHandler) http.HandlerFunc        { return h.RefreshToken }
+func createUser(h *UserHandler) http.HandlerFunc     { return h.CreateUser }
+func getMe(h *UserHandler) http.HandlerFunc          { return h.GetMe }
+func updateMe(h *UserHandler) http.HandlerFunc       { return h.UpdateMe }
+func updateRole(h *UserHandler) http.HandlerFunc     { return h.UpdateUserRole }
+func changePassword(h *UserHandler) http.HandlerFunc { return h.ChangePassword }
+func forgotPassword(h *UserHandler) http.HandlerFunc { return h.ForgotPassword }
+func resetPassword(h *UserHandler) http.HandlerFunc  { return h.ResetPassword }
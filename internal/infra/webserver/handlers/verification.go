@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/dto"
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/mail"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+)
+
+// EmailVerifier mails users a link to URL, with a token of
+// entity.NewVerificationToken signed with Secret and valid for TTL as query
+// parameter, to prove that they own their email. Users can have it resent
+// once every ResendInterval.
+type EmailVerifier struct {
+	UserDB         database.UserInterface
+	Mailer         mail.Mailer
+	Secret         []byte
+	TTL            time.Duration
+	ResendInterval time.Duration
+	URL            string
+}
+
+// send mails a verification link to the user unless one was sent after
+// notBefore. Failures are only logged: the user can ask for a resend.
+func (v *EmailVerifier) send(u *entity.User, notBefore time.Time) {
+	if v == nil {
+		return
+	}
+	sent, err := v.UserDB.MarkVerificationSent(u.ID.String(), notBefore)
+	if err != nil {
+		log.Printf("could not record the verification mail of user %s: %v", u.ID, err)
+		return
+	}
+	if !sent {
+		return
+	}
+	if err := v.Mailer.Send(v.message(u)); err != nil {
+		log.Printf("could not mail the verification link of user %s: %v", u.ID, err)
+	}
+}
+
+// sendNow mails a verification link to a user whose email was just set.
+func (v *EmailVerifier) sendNow(u *entity.User) {
+	v.send(u, time.Now())
+}
+
+// resend mails a verification link again unless the last one is too recent.
+func (v *EmailVerifier) resend(u *entity.User) {
+	if v == nil {
+		return
+	}
+	v.send(u, time.Now().Add(-v.ResendInterval))
+}
+
+func (v *EmailVerifier) message(u *entity.User) mail.Message {
+	link := v.URL + "?token=" + url.QueryEscape(entity.NewVerificationToken(u, v.Secret, v.TTL))
+	return mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Open this link within %g hours to verify that this email is yours:\n\n"+
+			"%s\n\n"+
+			"If you did not sign up, ignore this email.\n",
+			u.Name, v.TTL.Hours(), link),
+	}
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email
+// @Description Record that a user owns their email, with the token of the link mailed to them
+// @Tags users
+// @Param token query string true "Verification token"
+// @Success 204
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/verify [get]
+func (uh *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if uh.Verifier == nil {
+		problem.Status(w, r, http.StatusNotFound)
+		return
+	}
+	id, email, err := entity.ParseVerificationToken(r.URL.Query().Get("token"), uh.Verifier.Secret)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, err := uh.UserDB.FindByID(id.String())
+	if errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, entity.ErrInvalidVerificationToken)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	// The token is void once the user changes the email it was sent to.
+	if u.Email != email {
+		problem.Error(w, r, entity.ErrInvalidVerificationToken)
+		return
+	}
+	if !u.EmailVerified() {
+		if err := uh.UserDB.VerifyEmail(u.ID.String()); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary Resend the email verification link
+// @Description Mail the verification link again to the user with the email, unless it is verified or was mailed recently. The response is the same in every case, so as not to tell who is registered.
+// @Tags users
+// @Accept json
+// @Param input body dto.ResendVerificationInput true "Email"
+// @Success 202
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/verify/resend [post]
+func (uh *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var input dto.ResendVerificationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	u, err := uh.UserDB.FindByEmail(input.Email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		problem.Error(w, r, err)
+		return
+	}
+	if err == nil && !u.EmailVerified() {
+		uh.Verifier.resend(u)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// APIKeyHeader is the header programs send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyTouchInterval is how often the last use of an API key is recorded,
+// so that busy keys are not written on every request.
+const APIKeyTouchInterval = time.Minute
+
+// APIKey authenticates the requests that carry an API key instead of an
+// access token. It stores in the context a token with the claims of an
+// access token of the owner of the key, with their current role and
+// organization, and a scope claim listing the scopes of the key. It must
+// run after Verifier and before Authenticator, which rejects the requests
+// that carry neither.
+func APIKey(apiKeyDB database.APIKeyInterface, userDB database.UserInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plain := r.Header.Get(APIKeyHeader)
+			if plain == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if jwtauth.TokenFromHeader(r) != "" {
+				problem.Write(w, r, problem.New(http.StatusBadRequest, "send either an access token or an API key"))
+				return
+			}
+			key, u, err := findAPIKey(apiKeyDB, userDB, plain)
+			if err != nil {
+				problem.Error(w, r, err)
+				return
+			}
+			if err := apiKeyDB.Touch(key.ID.String(), time.Now().Add(-APIKeyTouchInterval)); err != nil {
+				log.Printf("could not record the use of API key %s: %v", key.ID, err)
+			}
+			token := jwt.New()
+			for name, value := range map[string]interface{}{
+				"sub":    u.ID.String(),
+				"role":   string(u.Role),
+				"tenant": u.OrganizationID.String(),
+				"scope":  key.Scopes,
+			} {
+				if err := token.Set(name, value); err != nil {
+					problem.Error(w, r, err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, nil)))
+		})
+	}
+}
+
+// findAPIKey returns the usable API key of the plain text key and its
+// owner. Unknown, revoked and expired keys, and keys of deleted users, are
+// all entity.ErrInvalidAPIKey.
+func findAPIKey(apiKeyDB database.APIKeyInterface, userDB database.UserInterface, plain string) (*entity.APIKey, *entity.User, error) {
+	key, err := apiKeyDB.FindByHash(entity.HashToken(plain))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, entity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := key.Validate(); err != nil {
+		return nil, nil, err
+	}
+	u, err := userDB.FindByID(key.UserID.String())
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, entity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, u, nil
+}
+
+// RequireScope rejects with 403 the requests authenticated by an API key
+// that does not grant the scope. Access tokens have no scope claim and are
+// only limited by RequireRole. Like RequireRole it must run after
+// Authenticator.
+func RequireScope(scope entity.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				problem.Status(w, r, http.StatusUnauthorized)
+				return
+			}
+			if granted, ok := claims["scope"].(string); ok {
+				if !hasScope(granted, scope) {
+					problem.Write(w, r, problem.New(http.StatusForbidden, "the "+string(scope)+" scope is required"))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasScope(granted string, scope entity.Scope) bool {
+	for _, s := range strings.Fields(granted) {
+		if entity.Scope(s) == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/infra/keys"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// Verifier decodes the access token of the request, from the Authorization
+// header or else the jwt cookie, with the keys of the set and stores it in
+// the context along with its error, like jwtauth.Verifier. jwtauth.JWTAuth
+// only verifies with a single key.
+func Verifier(set *keys.Set) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := verify(set, r)
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, err)))
+		})
+	}
+}
+
+func verify(set *keys.Set, r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+	token, err := set.Decode(tokenString)
+	if err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	if err := jwt.Validate(token, set.ValidateOptions()...); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+// Authenticator rejects with 401 the requests that carry no valid access
+// token, like jwtauth.Authenticator but answering with a problem. It must run
+// after Verifier.
+func Authenticator(set *keys.Set) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				problem.Write(w, r, problem.New(http.StatusUnauthorized, err.Error()))
+				return
+			}
+			if token == nil || jwt.Validate(token, set.ValidateOptions()...) != nil {
+				problem.Status(w, r, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
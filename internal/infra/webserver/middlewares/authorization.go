@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// RequireRole rejects with 403 the requests whose access token does not
+// grant the role. Tokens without a role claim are treated as a viewer's.
+// Like Denylist it must run after Authenticator.
+func RequireRole(role entity.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				problem.Status(w, r, http.StatusUnauthorized)
+				return
+			}
+			granted := entity.RoleViewer
+			if claim, ok := claims["role"].(string); ok {
+				granted = entity.Role(claim)
+			}
+			if !granted.Includes(role) {
+				problem.Write(w, r, problem.New(http.StatusForbidden, "the "+string(role)+" role is required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/ThalesLoreto/product-api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// Denylist rejects access tokens revoked by a logout. It must run after
+// Verifier and Authenticator, which reject missing, invalid and expired
+// tokens.
+func Denylist(tokenDB database.TokenInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil {
+				problem.Status(w, r, http.StatusUnauthorized)
+				return
+			}
+			// Tokens issued before revocation existed have no jti and stay
+			// valid until they expire.
+			if jti := token.JwtID(); jti != "" {
+				revoked, err := tokenDB.IsAccessTokenRevoked(jti)
+				if err != nil {
+					problem.Error(w, r, err)
+					return
+				}
+				if revoked {
+					problem.Status(w, r, http.StatusUnauthorized)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	pkgEntity "github.com/ThalesLoreto/product-api/pkg/entity"
+)
+
+const (
+	TypeValidation        = "/problems/validation-error"
+	TypeInsufficientStock = "/problems/insufficient-stock"
+	TypeVersionMismatch   = "/problems/version-mismatch"
+	TypeCategoryInUse     = "/problems/category-has-subcategories"
+	TypeInvalidToken      = "/problems/invalid-token"
+	TypeConflict          = "/problems/conflict"
+	TypeEmailNotVerified  = "/problems/email-not-verified"
+	TypeLoginLocked       = "/problems/login-locked"
+	TypeResetThrottled    = "/problems/password-reset-throttled"
+)
+
+// mapping describes the problem a known error is reported as. Errors about
+// a single request field name it. Opaque errors are described by the
+// message of the mapped error alone, as the rest of theirs comes from the
+// database driver.
+type mapping struct {
+	err    error
+	status int
+	field  string
+	typ    string
+	title  string
+	opaque bool
+}
+
+var mappings = []mapping{
+	{err: database.ErrNotFound, status: http.StatusNotFound, opaque: true},
+
+	{err: entity.ErrIdRequired, field: "id"},
+	{err: entity.ErrInvalidID, field: "id"},
+	{err: entity.ErrNameRequired, field: "name"},
+	{err: entity.ErrPriceRequired, field: "price.amount"},
+	{err: entity.ErrInvalidPrice, field: "price.amount"},
+	{err: entity.ErrInvalidCurrency, field: "price.currency"},
+	{err: pkgEntity.ErrUnknownCurrency, field: "price.currency"},
+	{err: entity.ErrInvalidParent, field: "parent_id"},
+	{err: entity.ErrProductIdRequired, field: "product_id"},
+	{err: entity.ErrInvalidMovement, field: "type"},
+	{err: entity.ErrQuantityRequired, field: "quantity"},
+	{err: entity.ErrInvalidQuantity, field: "quantity"},
+	{err: entity.ErrInvalidRole, field: "role"},
+	{err: entity.ErrEmailRequired, field: "email"},
+	{err: entity.ErrInvalidEmail, field: "email"},
+	{err: entity.ErrWeakPassword, field: "password"},
+	{err: entity.ErrInvalidResetToken, field: "token"},
+	{err: entity.ErrInvalidVerificationToken, field: "token"},
+	{err: entity.ErrInvalidMFACode, field: "code"},
+	{err: entity.ErrScopesRequired, field: "scopes"},
+	{err: entity.ErrInvalidScope, field: "scopes"},
+	{err: entity.ErrInvalidExpiry, field: "expires_at"},
+	{err: database.ErrCategoryNotFound, field: "category_ids"},
+	{err: database.ErrCategoryCycle, field: "parent_id"},
+	{err: database.ErrInvalidCursor, field: "cursor"},
+	{err: database.ErrInvalidSort, field: "sort"},
+	{err: database.ErrCurrencyRequired, field: "currency"},
+	{err: database.ErrInvalidPriceRange, field: "price_min"},
+	{err: database.ErrInvalidCreatedRange, field: "created_after"},
+
+	{err: entity.ErrInsufficientStock, status: http.StatusConflict, typ: TypeInsufficientStock, title: "Insufficient Stock"},
+	{err: database.ErrCategoryHasChildren, status: http.StatusConflict, typ: TypeCategoryInUse, title: "Category Has Subcategories"},
+	{err: database.ErrVersionMismatch, status: http.StatusPreconditionFailed, typ: TypeVersionMismatch, title: "Version Mismatch"},
+	{err: entity.ErrInvalidRefreshToken, status: http.StatusUnauthorized, typ: TypeInvalidToken, title: "Invalid Token"},
+	{err: entity.ErrRefreshTokenReused, status: http.StatusUnauthorized, typ: TypeInvalidToken, title: "Invalid Token"},
+	{err: entity.ErrEmailNotVerified, status: http.StatusForbidden, typ: TypeEmailNotVerified, title: "Email Not Verified"},
+	{err: entity.ErrLoginLocked, status: http.StatusTooManyRequests, typ: TypeLoginLocked, title: "Login Locked"},
+	{err: entity.ErrResetThrottled, status: http.StatusTooManyRequests, typ: TypeResetThrottled, title: "Password Reset Throttled"},
+	{err: entity.ErrInvalidMFAToken, status: http.StatusUnauthorized, typ: TypeInvalidToken, title: "Invalid Token"},
+	{err: entity.ErrTOTPEnabled, status: http.StatusConflict, typ: TypeConflict, title: "Conflict"},
+	{err: entity.ErrTOTPNotEnrolled, status: http.StatusConflict, typ: TypeConflict, title: "Conflict"},
+	{err: entity.ErrInvalidAPIKey, status: http.StatusUnauthorized, typ: TypeInvalidToken, title: "Invalid Token"},
+
+	{err: database.ErrConflict, status: http.StatusConflict, typ: TypeConflict, title: "Conflict", opaque: true},
+	{err: database.ErrUnavailable, status: http.StatusServiceUnavailable, opaque: true},
+}
+
+// From returns the problem an error is reported as: the error itself if it
+// is a Problem, a validation problem for invalid input, the status matching
+// known domain and database errors, and 500 for anything else.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	if p := fromJoined(err); p != nil {
+		return p
+	}
+	for _, m := range mappings {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+		if m.field != "" {
+			return Invalid(m.field, err)
+		}
+		detail := err.Error()
+		if m.opaque {
+			detail = m.err.Error()
+		}
+		p := New(m.status, detail)
+		if m.typ != "" {
+			p.Type, p.Title = m.typ, m.title
+		}
+		return p
+	}
+	if p := fromDecodeError(err); p != nil {
+		return p
+	}
+	return New(http.StatusInternalServerError, "")
+}
+
+// fromJoined reports errors joined by errors.Join, as entities do to
+// report every invalid field at once, as a single validation problem.
+// Joined errors that are not all about a field are reported as the first
+// one that is not.
+func fromJoined(err error) *Problem {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var fields []FieldError
+	for _, e := range joined.Unwrap() {
+		p := From(e)
+		if p.Type != TypeValidation {
+			return p
+		}
+		fields = append(fields, p.Errors...)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Message
+	}
+	return &Problem{
+		Type:   TypeValidation,
+		Title:  "Validation Error",
+		Status: http.StatusBadRequest,
+		Detail: strings.Join(messages, "; "),
+		Errors: fields,
+	}
+}
+
+// fromDecodeError reports a request body that is not the expected JSON.
+func fromDecodeError(err error) *Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return New(http.StatusBadRequest, "the request body is empty or truncated")
+	case errors.As(err, &syntaxErr):
+		return New(http.StatusBadRequest, "the request body is not valid JSON: "+err.Error())
+	case errors.As(err, &typeErr):
+		return Invalid(typeErr.Field, err)
+	}
+	return nil
+}
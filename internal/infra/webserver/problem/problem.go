@@ -0,0 +1,114 @@
+// Package problem writes errors as RFC 7807 problem details, the body of
+// every error response of the API.
+package problem
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const (
+	ContentType = "application/problem+json"
+	// TypeBlank is the type of problems that carry no more meaning than
+	// their HTTP status.
+	TypeBlank = "about:blank"
+)
+
+// FieldError points at the request field that made it invalid, using the
+// JSON name of the field or query parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// New returns a problem that is only described by its status and detail.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Invalid returns a validation problem about a single request field.
+func Invalid(field string, err error) *Problem {
+	return &Problem{
+		Type:   TypeValidation,
+		Title:  "Validation Error",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+		Errors: []FieldError{{Field: field, Message: err.Error()}},
+	}
+}
+
+// Conflict returns a problem about a request field whose value is taken
+// by another resource, such as an email already registered.
+func Conflict(field string, err error) *Problem {
+	return &Problem{
+		Type:   TypeConflict,
+		Title:  "Conflict",
+		Status: http.StatusConflict,
+		Detail: err.Error(),
+		Errors: []FieldError{{Field: field, Message: err.Error()}},
+	}
+}
+
+// Nest returns a copy of the problem whose field errors are about the
+// members of the object field of the request.
+func (p *Problem) Nest(field string) *Problem {
+	nested := *p
+	nested.Errors = make([]FieldError, len(p.Errors))
+	for i, e := range p.Errors {
+		nested.Errors[i] = FieldError{Field: field + "." + e.Field, Message: e.Message}
+	}
+	return &nested
+}
+
+// Error makes a problem usable as an error, so that helpers can return one
+// for handlers to write as is.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// Write writes the problem as the response, identifying the request as its
+// instance unless it already names one.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		copied := *p
+		copied.Instance = r.URL.Path
+		p = &copied
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Status writes a problem described by its status alone.
+func Status(w http.ResponseWriter, r *http.Request, status int) {
+	Write(w, r, New(status, ""))
+}
+
+// Error writes the problem err maps to, see From. Errors that map to no
+// problem are logged and reported without detail, as their message may
+// expose internals such as SQL.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	p := From(err)
+	if p.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	Write(w, r, p)
+}
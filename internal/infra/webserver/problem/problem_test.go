@@ -0,0 +1,123 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ThalesLoreto/product-api/internal/entity"
+	"github.com/ThalesLoreto/product-api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFrom(t *testing.T) {
+	var syntaxErr, typeErr error
+	syntaxErr = json.Unmarshal([]byte("{"), &struct{}{})
+	typeErr = json.Unmarshal([]byte(`{"quantity":"one"}`), &struct {
+		Quantity int `json:"quantity"`
+	}{})
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		typ    string
+		field  string
+	}{
+		{"not found", fmt.Errorf("%w: %w", database.ErrNotFound, gorm.ErrRecordNotFound), http.StatusNotFound, TypeBlank, ""},
+		{"duplicate", fmt.Errorf("%w: UNIQUE constraint failed: users.email", database.ErrConflict), http.StatusConflict, TypeConflict, ""},
+		{"unavailable", fmt.Errorf("%w: dial tcp: connection refused", database.ErrUnavailable), http.StatusServiceUnavailable, TypeBlank, ""},
+		{"validation", entity.ErrNameRequired, http.StatusBadRequest, TypeValidation, "name"},
+		{"wrapped validation", fmt.Errorf("create: %w", entity.ErrInvalidPrice), http.StatusBadRequest, TypeValidation, "price.amount"},
+		{"query parameter", database.ErrInvalidSort, http.StatusBadRequest, TypeValidation, "sort"},
+		{"conflict", entity.ErrInsufficientStock, http.StatusConflict, TypeInsufficientStock, ""},
+		{"precondition", database.ErrVersionMismatch, http.StatusPreconditionFailed, TypeVersionMismatch, ""},
+		{"invalid token", entity.ErrRefreshTokenReused, http.StatusUnauthorized, TypeInvalidToken, ""},
+		{"invalid reset token", entity.ErrInvalidResetToken, http.StatusBadRequest, TypeValidation, "token"},
+		{"unverified email", entity.ErrEmailNotVerified, http.StatusForbidden, TypeEmailNotVerified, ""},
+		{"locked out", entity.ErrLoginLocked, http.StatusTooManyRequests, TypeLoginLocked, ""},
+		{"invalid mfa code", entity.ErrInvalidMFACode, http.StatusBadRequest, TypeValidation, "code"},
+		{"invalid mfa token", entity.ErrInvalidMFAToken, http.StatusUnauthorized, TypeInvalidToken, ""},
+		{"invalid scope", entity.ErrInvalidScope, http.StatusBadRequest, TypeValidation, "scopes"},
+		{"invalid api key", entity.ErrInvalidAPIKey, http.StatusUnauthorized, TypeInvalidToken, ""},
+		{"totp enabled", entity.ErrTOTPEnabled, http.StatusConflict, TypeConflict, ""},
+		{"problem", New(http.StatusForbidden, "no"), http.StatusForbidden, TypeBlank, ""},
+		{"malformed body", syntaxErr, http.StatusBadRequest, TypeBlank, ""},
+		{"mistyped field", typeErr, http.StatusBadRequest, TypeValidation, "quantity"},
+		{"unknown", errors.New("near \"FROM\": syntax error"), http.StatusInternalServerError, TypeBlank, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := From(tt.err)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.typ, p.Type)
+			assert.NotEmpty(t, p.Title)
+			if tt.field == "" {
+				assert.Empty(t, p.Errors)
+			} else if assert.Len(t, p.Errors, 1) {
+				assert.Equal(t, tt.field, p.Errors[0].Field)
+			}
+		})
+	}
+}
+
+func TestFrom_JoinedErrors(t *testing.T) {
+	p := From(errors.Join(entity.ErrNameRequired, entity.ErrInvalidEmail, fmt.Errorf("%w: too short", entity.ErrWeakPassword)))
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, TypeValidation, p.Type)
+	assert.Equal(t, []string{"name", "email", "password"}, fields(p))
+
+	p = From(errors.Join(entity.ErrNameRequired, errors.New("disk full")))
+	assert.Equal(t, http.StatusInternalServerError, p.Status, "errors not about fields are not reported as invalid input")
+}
+
+func TestProblem_Nest(t *testing.T) {
+	p := From(errors.Join(entity.ErrInvalidEmail, entity.ErrWeakPassword))
+	nested := p.Nest("admin")
+	assert.Equal(t, []string{"admin.email", "admin.password"}, fields(nested))
+	assert.Equal(t, []string{"email", "password"}, fields(p), "Nest must not modify the problem")
+}
+
+func TestConflict(t *testing.T) {
+	p := Conflict("email", errors.New("the email is already registered"))
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, TypeConflict, p.Type)
+	assert.Equal(t, []string{"email"}, fields(p))
+}
+
+func fields(p *Problem) []string {
+	names := make([]string, len(p.Errors))
+	for i, e := range p.Errors {
+		names[i] = e.Field
+	}
+	return names
+}
+
+func TestFrom_HidesInternalErrors(t *testing.T) {
+	p := From(errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+	assert.Empty(t, p.Detail)
+
+	p = From(fmt.Errorf("%w: UNIQUE constraint failed: users.email", database.ErrConflict))
+	assert.Equal(t, database.ErrConflict.Error(), p.Detail)
+}
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	w := httptest.NewRecorder()
+	p := New(http.StatusNotFound, "")
+	Write(w, r, p)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var body Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, "/products/1", body.Instance)
+	assert.Equal(t, "Not Found", body.Title)
+	assert.Empty(t, p.Instance, "Write must not modify the problem")
+	assert.False(t, strings.Contains(w.Body.String(), `"errors"`))
+}
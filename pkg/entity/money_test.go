@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMoney(t *testing.T) {
+	m, err := NewMoney(1234, "usd")
+	assert.NoError(t, err)
+	assert.Equal(t, Money{Amount: 1234, Currency: "USD"}, m)
+
+	_, err = NewMoney(1234, "XYZ")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "12.34 USD", Money{Amount: 1234, Currency: "USD"}.String())
+	assert.Equal(t, "-0.05 EUR", Money{Amount: -5, Currency: "EUR"}.String())
+	assert.Equal(t, "500 JPY", Money{Amount: 500, Currency: "JPY"}.String())
+	assert.Equal(t, "1.500 KWD", Money{Amount: 1500, Currency: "KWD"}.String())
+}